@@ -2,65 +2,152 @@ package api
 
 import (
 	"log"
+	"shopping-list/db"
+	"shopping-list/handlers"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
-// Register conditionally registers the API routes if API_TOKEN is set
+// Register registers the API routes. Requests authenticate with a token
+// minted on the API tokens page or with the legacy API_TOKEN.
 func Register(app *fiber.App) {
-	if !IsAPIEnabled() {
-		log.Println("REST API is disabled (API_TOKEN not set)")
-		// Register catch-all handler that returns 503 for all API requests
-		app.All("/api/v1/*", func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
-				Error:   "api_disabled",
-				Message: "API is not enabled on this server",
-			})
-		})
-		return
+	if IsAPIEnabled() {
+		log.Println("REST API legacy API_TOKEN is enabled")
 	}
 
-	log.Println("REST API is enabled")
+	// OpenAPI document, public so client generators can fetch it
+	app.Get("/api/v1/openapi.json", GetOpenAPI)
 
-	// Create API group with version prefix and token auth middleware
-	v1 := app.Group("/api/v1", TokenAuthMiddleware)
+	// Live updates over WebSocket, registered ahead of the group so a ticket
+	// can stand in for the Authorization header browsers can't send
+	app.Get("/api/v1/ws", WebSocketAuth, requireScope(db.ScopeItemsRead), webSocketAccess, websocket.New(handlers.WebSocketHandler))
+
+	// Create API group with version prefix, token auth middleware and
+	// Idempotency-Key replays for POSTs
+	v1 := app.Group("/api/v1", TokenAuthMiddleware, idempotency(IdempotencyWindow()))
+
+	// Restrict :id routes to the caller's household and the token's list
+	listAccess := householdAccess(ownsList, "List not found")
+	sectionAccess := householdAccess(ownsSection, "Section not found")
+	itemAccess := householdAccess(ownsItem, "Item not found")
+	webhookAccess := householdAccess(ownsWebhook, "Webhook not found")
+	templateAccess := householdAccess(handlers.OwnsTemplate, "Template not found")
+	scheduleAccess := householdAccess(handlers.OwnsTemplateSchedule, "Schedule not found")
+
+	// Optimistic concurrency: If-Match must match the row's ETag
+	listIfMatch := ifMatch(listVersion)
+	sectionIfMatch := ifMatch(sectionVersion)
+	itemIfMatch := ifMatch(itemVersion)
+	templateIfMatch := ifMatch(templateVersion)
+
+	// Scope checks
+	listsRead := requireScope(db.ScopeListsRead)
+	listsWrite := requireScope(db.ScopeListsWrite)
+	sectionsRead := requireScope(db.ScopeSectionsRead)
+	sectionsWrite := requireScope(db.ScopeSectionsWrite)
+	itemsRead := requireScope(db.ScopeItemsRead)
+	itemsWrite := requireScope(db.ScopeItemsWrite)
+	historyRead := requireScope(db.ScopeHistoryRead)
+	historyWrite := requireScope(db.ScopeHistoryWrite)
+	templatesRead := requireScope(db.ScopeTemplatesRead)
+	templatesWrite := requireScope(db.ScopeTemplatesWrite)
+	tokensManage := requireScope(db.ScopeTokensManage)
+	settingsWrite := requireScope(db.ScopeSettingsWrite)
+	webhooksManage := requireScope(db.ScopeWebhooksManage)
 
 	// Lists endpoints
-	v1.Get("/lists", GetLists)
-	v1.Get("/lists/:id", GetList)
-	v1.Post("/lists", CreateList)
-	v1.Put("/lists/:id", UpdateList)
-	v1.Delete("/lists/:id", DeleteList)
-	v1.Get("/lists/:id/sections", GetListSections)
-	v1.Post("/lists/:id/move-up", MoveListUp)
-	v1.Post("/lists/:id/move-down", MoveListDown)
+	v1.Get("/lists", listsRead, GetLists)
+	v1.Get("/lists/:id", listsRead, listAccess, GetList)
+	v1.Post("/lists", listsWrite, CreateList)
+	v1.Put("/lists/:id", listsWrite, listAccess, listIfMatch, UpdateList)
+	v1.Patch("/lists/:id", listsWrite, listAccess, listIfMatch, PatchList)
+	v1.Delete("/lists/:id", listsWrite, listAccess, listIfMatch, DeleteList)
+	v1.Get("/lists/:id/sections", listsRead, listAccess, GetListSections)
+	v1.Post("/lists/:id/move-up", listsWrite, listAccess, MoveListUp)
+	v1.Post("/lists/:id/move-down", listsWrite, listAccess, MoveListDown)
 
 	// Sections endpoints
-	v1.Get("/sections/:id", GetSection)
-	v1.Post("/sections", CreateSection)
-	v1.Put("/sections/:id", UpdateSection)
-	v1.Delete("/sections/:id", DeleteSection)
-	v1.Get("/sections/:id/items", GetSectionItems)
-	v1.Post("/sections/:id/move-up", MoveSectionUp)
-	v1.Post("/sections/:id/move-down", MoveSectionDown)
+	v1.Get("/sections/:id", sectionsRead, sectionAccess, GetSection)
+	v1.Post("/sections", sectionsWrite, CreateSection)
+	v1.Put("/sections/:id", sectionsWrite, sectionAccess, sectionIfMatch, UpdateSection)
+	v1.Patch("/sections/:id", sectionsWrite, sectionAccess, sectionIfMatch, PatchSection)
+	v1.Delete("/sections/:id", sectionsWrite, sectionAccess, sectionIfMatch, DeleteSection)
+	v1.Get("/sections/:id/items", sectionsRead, sectionAccess, GetSectionItems)
+	v1.Post("/sections/:id/move-up", sectionsWrite, sectionAccess, MoveSectionUp)
+	v1.Post("/sections/:id/move-down", sectionsWrite, sectionAccess, MoveSectionDown)
 
 	// Items endpoints
-	v1.Get("/items/:id", GetItem)
-	v1.Post("/items", CreateItem)
-	v1.Put("/items/:id", UpdateItem)
-	v1.Delete("/items/:id", DeleteItem)
-	v1.Post("/items/:id/toggle", ToggleItemCompleted)
-	v1.Post("/items/:id/uncertain", ToggleItemUncertain)
-	v1.Post("/items/:id/move", MoveItem)
-	v1.Post("/items/:id/move-up", MoveItemUp)
-	v1.Post("/items/:id/move-down", MoveItemDown)
+	v1.Get("/items", itemsRead, GetItems)
+	v1.Get("/items/:id", itemsRead, itemAccess, GetItem)
+	v1.Post("/items", itemsWrite, CreateItem)
+	v1.Put("/items/:id", itemsWrite, itemAccess, itemIfMatch, UpdateItem)
+	v1.Patch("/items/:id", itemsWrite, itemAccess, itemIfMatch, PatchItem)
+	v1.Delete("/items/:id", itemsWrite, itemAccess, itemIfMatch, DeleteItem)
+	v1.Post("/items/:id/toggle", itemsWrite, itemAccess, ToggleItemCompleted)
+	v1.Post("/items/:id/uncertain", itemsWrite, itemAccess, ToggleItemUncertain)
+	v1.Post("/items/:id/move", itemsWrite, itemAccess, MoveItem)
+	v1.Post("/items/:id/move-up", itemsWrite, itemAccess, MoveItemUp)
+	v1.Post("/items/:id/move-down", itemsWrite, itemAccess, MoveItemDown)
 
 	// Batch endpoint
-	v1.Post("/batch", BatchCreate)
+	v1.Post("/batch", itemsWrite, BatchCreate)
+
+	// Search endpoint
+	v1.Get("/search", itemsRead, Search)
+
+	// History endpoints (suggestions); history spans the household's lists,
+	// so a list-restricted token can't use them
+	history := v1.Group("/history", householdWide)
+	history.Get("", historyRead, GetHistory)
+	history.Post("", historyWrite, CreateHistory)
+	history.Delete("/:id", historyWrite, DeleteHistory)
+	history.Post("/batch-delete", historyWrite, BatchDeleteHistory)
+
+	// Template endpoints; templates span the household, so a list-restricted
+	// token can't use them
+	templates := v1.Group("/templates", householdWide)
+	templates.Get("", templatesRead, GetTemplates)
+	templates.Post("", templatesWrite, CreateTemplate)
+	templates.Post("/from-list", templatesWrite, listsRead, CreateTemplateFromList)
+	templates.Get("/:id", templatesRead, templateAccess, GetTemplate)
+	templates.Put("/:id", templatesWrite, templateAccess, templateIfMatch, UpdateTemplate)
+	templates.Patch("/:id", templatesWrite, templateAccess, templateIfMatch, PatchTemplate)
+	templates.Delete("/:id", templatesWrite, templateAccess, templateIfMatch, DeleteTemplate)
+	templates.Post("/:id/items", templatesWrite, templateAccess, CreateTemplateItem)
+	templates.Put("/:id/items/:itemId", templatesWrite, templateAccess, templateItemAccess, UpdateTemplateItem)
+	templates.Patch("/:id/items/:itemId", templatesWrite, templateAccess, templateItemAccess, PatchTemplateItem)
+	templates.Delete("/:id/items/:itemId", templatesWrite, templateAccess, templateItemAccess, DeleteTemplateItem)
+	templates.Post("/:id/apply", templatesRead, itemsWrite, templateAccess, ApplyTemplate)
+
+	// Template schedule endpoints; a schedule writes to its list, so changing
+	// one takes items:write too
+	schedules := v1.Group("/schedules", householdWide)
+	schedules.Get("", templatesRead, GetSchedules)
+	schedules.Post("", templatesWrite, itemsWrite, CreateSchedule)
+	schedules.Get("/:id", templatesRead, scheduleAccess, GetSchedule)
+	schedules.Put("/:id", templatesWrite, itemsWrite, scheduleAccess, UpdateSchedule)
+	schedules.Delete("/:id", templatesWrite, scheduleAccess, DeleteSchedule)
+	schedules.Post("/:id/run", templatesWrite, itemsWrite, scheduleAccess, RunSchedule)
+	schedules.Get("/:id/runs", templatesRead, scheduleAccess, GetScheduleRuns)
+
+	// Token endpoints
+	v1.Get("/tokens", tokensManage, GetTokens)
+	v1.Post("/tokens", tokensManage, CreateToken)
+	v1.Delete("/tokens/:id", tokensManage, DeleteToken)
+
+	// Webhook endpoints
+	v1.Get("/webhooks", webhooksManage, GetWebhooks)
+	v1.Post("/webhooks", webhooksManage, CreateWebhook)
+	v1.Get("/webhooks/:id", webhooksManage, webhookAccess, GetWebhook)
+	v1.Put("/webhooks/:id", webhooksManage, webhookAccess, UpdateWebhook)
+	v1.Delete("/webhooks/:id", webhooksManage, webhookAccess, DeleteWebhook)
+	v1.Get("/webhooks/:id/deliveries", webhooksManage, webhookAccess, GetWebhookDeliveries)
+
+	// Settings endpoints
+	v1.Put("/settings/password", settingsWrite, ChangePassword)
 
-	// History endpoints (suggestions)
-	v1.Get("/history", GetHistory)
-	v1.Post("/history", CreateHistory)
-	v1.Delete("/history/:id", DeleteHistory)
-	v1.Post("/history/batch-delete", BatchDeleteHistory)
+	// Live updates (Server-Sent Events, and tickets for /api/v1/ws)
+	v1.Get("/events", itemsRead, GetEvents)
+	v1.Post("/ws/ticket", itemsRead, CreateWebSocketTicket)
 }
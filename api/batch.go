@@ -35,6 +35,19 @@ func BatchCreate(c *fiber.Ctx) error {
 
 // batchCreateNewList creates a new list with sections and items
 func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
+	if !hasScope(c, db.ScopeListsWrite) {
+		return insufficientScope(c, db.ScopeListsWrite)
+	}
+	if !hasScope(c, db.ScopeSectionsWrite) {
+		return insufficientScope(c, db.ScopeSectionsWrite)
+	}
+	if restrictedListID(c) != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "API token is restricted to a single list",
+		})
+	}
+
 	if req.List.Name == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error:   "validation_error",
@@ -76,6 +89,12 @@ func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
 					Message: "Item name exceeds maximum length of 200 characters",
 				})
 			}
+			if msg := validateQuantity(item.Quantity, item.Unit); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+					Error:   "validation_error",
+					Message: msg,
+				})
+			}
 			if len(item.Description) > MaxDescriptionLength {
 				return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 					Error:   "validation_error",
@@ -97,7 +116,7 @@ func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
 
 	// Create list
 	icon := NormalizeIcon(req.List.Icon)
-	list, err := db.CreateListTx(tx, req.List.Name, icon)
+	list, err := db.CreateListTx(tx, handlers.HouseholdID(c), req.List.Name, icon)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "create_failed",
@@ -120,7 +139,14 @@ func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
 
 		var sectionItems []db.Item
 		for itemOrder, itemInput := range sectionInput.Items {
-			item, err := db.CreateItemTx(tx, section.ID, itemInput.Name, itemInput.Description, itemOrder)
+			item, _, err := db.AddItemTx(tx, section.ID, itemInput.Name, itemInput.Description, itemInput.Quantity, itemInput.Unit, itemOrder, 0)
+			if err == db.ErrDuplicateItem {
+				return c.Status(fiber.StatusConflict).JSON(DuplicateItemResponse{
+					Error:   "duplicate_item",
+					Message: "An open item with this name is already on the list: " + itemInput.Name,
+					Item:    item,
+				})
+			}
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 					Error:   "create_failed",
@@ -131,7 +157,7 @@ func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
 			items = append(items, *item)
 
 			// Save to item history
-			db.SaveItemHistoryTx(tx, itemInput.Name, section.ID)
+			db.SaveItemHistoryTx(tx, handlers.HouseholdID(c), itemInput.Name, section.ID)
 		}
 
 		section.Items = sectionItems
@@ -149,22 +175,29 @@ func batchCreateNewList(c *fiber.Ctx, req BatchCreateRequest) error {
 	// Get list with stats
 	list.Stats = db.GetListStats(list.ID)
 
-	// Broadcast WebSocket update
-	handlers.BroadcastUpdate("batch_created", map[string]interface{}{
-		"list_id": list.ID,
-	})
-
-	return c.Status(fiber.StatusCreated).JSON(BatchCreateResponse{
+	response := BatchCreateResponse{
 		List:     list,
 		Sections: sections,
 		Items:    items,
-	})
+	}
+
+	// Broadcast WebSocket update
+	handlers.BroadcastIndexUpdate(c, list.ID, handlers.EventBatchCreated, response)
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
 // batchAddToList adds sections and items to an existing list
 func batchAddToList(c *fiber.Ctx, req BatchCreateRequest) error {
+	if !hasScope(c, db.ScopeSectionsWrite) {
+		return insufficientScope(c, db.ScopeSectionsWrite)
+	}
+
 	// Check if list exists
 	_, err := db.GetListByID(req.ListID)
+	if err == nil && !ownsList(c, req.ListID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -205,6 +238,12 @@ func batchAddToList(c *fiber.Ctx, req BatchCreateRequest) error {
 					Message: "Item name exceeds maximum length of 200 characters",
 				})
 			}
+			if msg := validateQuantity(item.Quantity, item.Unit); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+					Error:   "validation_error",
+					Message: msg,
+				})
+			}
 		}
 	}
 
@@ -236,7 +275,14 @@ func batchAddToList(c *fiber.Ctx, req BatchCreateRequest) error {
 
 		var sectionItems []db.Item
 		for itemOrder, itemInput := range sectionInput.Items {
-			item, err := db.CreateItemTx(tx, section.ID, itemInput.Name, itemInput.Description, itemOrder)
+			item, _, err := db.AddItemTx(tx, section.ID, itemInput.Name, itemInput.Description, itemInput.Quantity, itemInput.Unit, itemOrder, 0)
+			if err == db.ErrDuplicateItem {
+				return c.Status(fiber.StatusConflict).JSON(DuplicateItemResponse{
+					Error:   "duplicate_item",
+					Message: "An open item with this name is already on the list: " + itemInput.Name,
+					Item:    item,
+				})
+			}
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 					Error:   "create_failed",
@@ -246,7 +292,7 @@ func batchAddToList(c *fiber.Ctx, req BatchCreateRequest) error {
 			sectionItems = append(sectionItems, *item)
 			items = append(items, *item)
 
-			db.SaveItemHistoryTx(tx, itemInput.Name, section.ID)
+			db.SaveItemHistoryTx(tx, handlers.HouseholdID(c), itemInput.Name, section.ID)
 		}
 
 		section.Items = sectionItems
@@ -261,21 +307,24 @@ func batchAddToList(c *fiber.Ctx, req BatchCreateRequest) error {
 		})
 	}
 
-	// Broadcast WebSocket update
-	handlers.BroadcastUpdate("batch_created", map[string]interface{}{
-		"list_id": req.ListID,
-	})
-
-	return c.Status(fiber.StatusCreated).JSON(BatchCreateResponse{
+	response := BatchCreateResponse{
 		Sections: sections,
 		Items:    items,
-	})
+	}
+
+	// Broadcast WebSocket update
+	handlers.BroadcastUpdate(c, req.ListID, handlers.EventBatchCreated, response)
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
 // batchAddToSection adds items to an existing section
 func batchAddToSection(c *fiber.Ctx, req BatchCreateRequest) error {
 	// Check if section exists
 	_, err := db.GetSectionByID(req.SectionID)
+	if err == nil && !ownsSection(c, req.SectionID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -303,6 +352,12 @@ func batchAddToSection(c *fiber.Ctx, req BatchCreateRequest) error {
 				Message: "Item name exceeds maximum length of 200 characters",
 			})
 		}
+		if msg := validateQuantity(item.Quantity, item.Unit); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "validation_error",
+				Message: msg,
+			})
+		}
 	}
 
 	// Start transaction
@@ -322,7 +377,14 @@ func batchAddToSection(c *fiber.Ctx, req BatchCreateRequest) error {
 
 	// Create items
 	for i, itemInput := range req.Items {
-		item, err := db.CreateItemTx(tx, req.SectionID, itemInput.Name, itemInput.Description, baseItemOrder+i)
+		item, _, err := db.AddItemTx(tx, req.SectionID, itemInput.Name, itemInput.Description, itemInput.Quantity, itemInput.Unit, baseItemOrder+i, 0)
+		if err == db.ErrDuplicateItem {
+			return c.Status(fiber.StatusConflict).JSON(DuplicateItemResponse{
+				Error:   "duplicate_item",
+				Message: "An open item with this name is already on the list: " + itemInput.Name,
+				Item:    item,
+			})
+		}
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 				Error:   "create_failed",
@@ -331,7 +393,7 @@ func batchAddToSection(c *fiber.Ctx, req BatchCreateRequest) error {
 		}
 		items = append(items, *item)
 
-		db.SaveItemHistoryTx(tx, itemInput.Name, req.SectionID)
+		db.SaveItemHistoryTx(tx, handlers.HouseholdID(c), itemInput.Name, req.SectionID)
 	}
 
 	// Commit transaction
@@ -342,12 +404,12 @@ func batchAddToSection(c *fiber.Ctx, req BatchCreateRequest) error {
 		})
 	}
 
+	response := BatchCreateResponse{
+		Items: items,
+	}
+
 	// Broadcast WebSocket update
-	handlers.BroadcastUpdate("batch_created", map[string]interface{}{
-		"section_id": req.SectionID,
-	})
+	handlers.BroadcastSectionUpdate(c, req.SectionID, handlers.EventBatchCreated, response)
 
-	return c.Status(fiber.StatusCreated).JSON(BatchCreateResponse{
-		Items: items,
-	})
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"shopping-list/db"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ifMatch builds route middleware for optimistic concurrency: when the
+// request carries If-Match, it answers 412 unless one of the listed ETags
+// (or "*") matches the current version of the :id row. A missing row is
+// left to the handler's 404. The handler's write checks the tags again in
+// its transaction, for writes that land in between.
+func ifMatch(version func(int64) (string, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tags := ifMatchTags(c)
+		if len(tags) == 0 {
+			return c.Next()
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Next()
+		}
+		current, err := version(int64(id))
+		if err != nil || db.ETagMatches(tags, current) {
+			return c.Next()
+		}
+		return preconditionFailed(c, current)
+	}
+}
+
+// maxPatchAttempts bounds how often a PATCH without If-Match is merged again
+// because another write changed the row after it was read
+const maxPatchAttempts = 3
+
+// writeTags returns the ETags a write must match: the request's If-Match or,
+// for a PATCH without one, base, the version the patch was merged into. A
+// write that changed a field the patch leaves alone then fails the check
+// instead of being reverted, and the handler merges again (retry is true).
+func writeTags(c *fiber.Ctx, patch bool, base string) (tags []string, retry bool) {
+	if tags := ifMatchTags(c); len(tags) > 0 || !patch {
+		return tags, false
+	}
+	return []string{base}, true
+}
+
+// ifMatchTags returns the ETags of the request's If-Match header
+func ifMatchTags(c *fiber.Ctx) []string {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return nil
+	}
+	tags := strings.Split(header, ",")
+	for i := range tags {
+		tags[i] = strings.TrimSpace(tags[i])
+	}
+	return tags
+}
+
+// preconditionFailed answers 412 with the row's current ETag
+func preconditionFailed(c *fiber.Ctx, current string) error {
+	if current != "" {
+		c.Set(fiber.HeaderETag, current)
+	}
+	return c.Status(fiber.StatusPreconditionFailed).JSON(ErrorResponse{
+		Error:   "precondition_failed",
+		Message: "The resource was modified since the given ETag",
+	})
+}
+
+// writeConflict answers 412 for a conditional write that lost to another
+// write after the middleware's check
+func writeConflict(c *fiber.Ctx, version func(int64) (string, error)) error {
+	id, _ := c.ParamsInt("id")
+	current, _ := version(int64(id))
+	return preconditionFailed(c, current)
+}
+
+func listVersion(id int64) (string, error) {
+	list, err := db.GetListByID(id)
+	if err != nil {
+		return "", err
+	}
+	return list.ETag(), nil
+}
+
+func sectionVersion(id int64) (string, error) {
+	section, err := db.GetSectionByID(id)
+	if err != nil {
+		return "", err
+	}
+	return section.ETag(), nil
+}
+
+func itemVersion(id int64) (string, error) {
+	item, err := db.GetItemByID(id)
+	if err != nil {
+		return "", err
+	}
+	return item.ETag(), nil
+}
+
+func templateVersion(id int64) (string, error) {
+	template, err := db.GetTemplateByID(id)
+	if err != nil {
+		return "", err
+	}
+	return template.ETag(), nil
+}
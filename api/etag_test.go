@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"shopping-list/db"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWriteTags(t *testing.T) {
+	tests := []struct {
+		ifMatch   string
+		patch     bool
+		wantTags  []string
+		wantRetry bool
+	}{
+		{"", false, nil, false},               // PUT without If-Match writes unconditionally
+		{"", true, []string{"base"}, true},    // PATCH without If-Match writes over what it merged into
+		{`"a"`, true, []string{`"a"`}, false}, // the client's If-Match wins
+		{`"a"`, false, []string{`"a"`}, false},
+	}
+	for _, tt := range tests {
+		var tags []string
+		var retry bool
+		app := fiber.New()
+		app.Put("/", func(c *fiber.Ctx) error {
+			tags, retry = writeTags(c, tt.patch, "base")
+			return nil
+		})
+		req := httptest.NewRequest("PUT", "/", nil)
+		if tt.ifMatch != "" {
+			req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+		}
+		if _, err := app.Test(req); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tags, tt.wantTags) || retry != tt.wantRetry {
+			t.Errorf("If-Match %q, patch %v: tags %q retry %v, want %q %v", tt.ifMatch, tt.patch, tags, retry, tt.wantTags, tt.wantRetry)
+		}
+	}
+}
+
+func TestPatchItemKeepsOtherFields(t *testing.T) {
+	setupTestDB(t)
+	list, err := db.CreateList(db.DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := db.CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := db.CreateItem(section.ID, "Milk", "oat", 2, "l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	Register(app)
+
+	token := mintToken(t, 0, 0, db.ScopeItemsWrite)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+	if status := apiCall(t, app, "PATCH", path, token, `{"completed": true}`); status != fiber.StatusOK {
+		t.Fatalf("PATCH: %d, want 200", status)
+	}
+	got, err := db.GetItemByID(item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Completed || got.Name != "Milk" || got.Description != "oat" || got.Quantity != 2 || got.Unit != "l" {
+		t.Errorf("item after PATCH = %+v", got)
+	}
+}
+
+func TestPatchListAndTemplateWithoutIfMatch(t *testing.T) {
+	setupTestDB(t)
+	list, err := db.CreateList(db.DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := db.CreateTemplate(db.DefaultHouseholdID, "Basics", "Weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	Register(app)
+
+	token := mintToken(t, 0, 0, db.ScopeListsWrite, db.ScopeTemplatesWrite)
+	if status := apiCall(t, app, "PATCH", "/api/v1/lists/"+strconv.FormatInt(list.ID, 10), token, `{"icon": "🥕"}`); status != fiber.StatusOK {
+		t.Errorf("PATCH list: %d, want 200", status)
+	}
+	if status := apiCall(t, app, "PATCH", "/api/v1/templates/"+strconv.FormatInt(template.ID, 10), token, `{"name": "Staples"}`); status != fiber.StatusOK {
+		t.Errorf("PATCH template: %d, want 200", status)
+	}
+	if got, _ := db.GetTemplateByID(template.ID); got.Description != "Weekly" {
+		t.Errorf("template description = %q, want it kept", got.Description)
+	}
+}
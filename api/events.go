@@ -0,0 +1,44 @@
+package api
+
+import (
+	"shopping-list/db"
+	"shopping-list/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetEvents streams live updates as Server-Sent Events. Query: ?lists=1,2
+// for list events, ?index=true for list-index events (lists, templates,
+// history). A token restricted to one list can only follow that list.
+func GetEvents(c *fiber.Ctx) error {
+	lists, index, err := handlers.ParseEventSubscription(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+
+	if index {
+		if restrictedListID(c) != 0 {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Error:   "insufficient_scope",
+				Message: "API token is restricted to a single list",
+			})
+		}
+		if !hasScope(c, db.ScopeListsRead) {
+			return insufficientScope(c, db.ScopeListsRead)
+		}
+	}
+	for _, listID := range lists {
+		// Another household, or outside the token's list
+		if !ownsList(c, listID) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "List not found",
+			})
+		}
+	}
+
+	return handlers.StreamEvents(c, handlers.HouseholdID(c), lists, index)
+}
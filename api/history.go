@@ -1,14 +1,17 @@
 package api
 
 import (
+	"database/sql"
 	"shopping-list/db"
+	"shopping-list/handlers"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // HistoryResponse wraps multiple history items
 type HistoryResponse struct {
-	Items []db.HistoryItem `json:"items"`
+	Items      []db.HistoryItem `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
 }
 
 // CreateHistoryRequest for adding a new history entry
@@ -17,26 +20,40 @@ type CreateHistoryRequest struct {
 	SectionID int64  `json:"section_id,omitempty"`
 }
 
+// CreateHistoryResponse confirms a new history entry
+type CreateHistoryResponse struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
 // BatchDeleteHistoryRequest for deleting multiple history entries
 type BatchDeleteHistoryRequest struct {
 	IDs []int64 `json:"ids"`
 }
 
-// GetHistory returns all history items
+// BatchDeleteHistoryResponse reports how many history entries were deleted
+type BatchDeleteHistoryResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// GetHistory returns the household's history, most used first, filtered by
+// ?updated_since= and paged by ?sort=, ?limit= (default 100) and ?cursor=
 func GetHistory(c *fiber.Ctx) error {
-	items, err := db.GetItemHistoryList()
+	updatedSince, err := parseUpdatedSince(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error:   "db_error",
-			Message: "Failed to fetch history",
-		})
+		return validationError(c, err)
+	}
+	page, err := parsePage(c, DefaultHistoryLimit)
+	if err != nil {
+		return validationError(c, err)
 	}
 
-	if items == nil {
-		items = []db.HistoryItem{}
+	items, next, err := db.FindItemHistory(handlers.HouseholdID(c), updatedSince, page)
+	if err != nil {
+		return collectionError(c, err, "Failed to fetch history")
 	}
 
-	return c.JSON(HistoryResponse{Items: items})
+	return c.JSON(HistoryResponse{Items: items, NextCursor: next})
 }
 
 // CreateHistory adds a new item to history
@@ -66,6 +83,9 @@ func CreateHistory(c *fiber.Ctx) error {
 	// If section_id provided, verify it exists
 	if req.SectionID != 0 {
 		_, err := db.GetSectionByID(req.SectionID)
+		if err == nil && !ownsSection(c, req.SectionID) {
+			err = sql.ErrNoRows // Another household, or outside the token's list
+		}
 		if err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 				Error:   "not_found",
@@ -74,16 +94,20 @@ func CreateHistory(c *fiber.Ctx) error {
 		}
 	}
 
-	if err := db.SaveItemHistory(req.Name, req.SectionID); err != nil {
+	if err := db.SaveItemHistory(handlers.HouseholdID(c), req.Name, req.SectionID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "create_failed",
 			Message: "Failed to save history",
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "History entry created",
-		"name":    req.Name,
+	if entry, err := db.GetItemHistoryByName(handlers.HouseholdID(c), req.Name); err == nil {
+		handlers.BroadcastIndexUpdate(c, 0, handlers.EventHistoryCreated, entry)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateHistoryResponse{
+		Message: "History entry created",
+		Name:    req.Name,
 	})
 }
 
@@ -97,13 +121,15 @@ func DeleteHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.DeleteItemHistory(int64(id)); err != nil {
+	if err := db.DeleteItemHistory(handlers.HouseholdID(c), int64(id)); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 			Error:   "not_found",
 			Message: "History entry not found",
 		})
 	}
 
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventHistoryDeleted, fiber.Map{"ids": []int64{int64(id)}})
+
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
@@ -124,7 +150,7 @@ func BatchDeleteHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	deleted, err := db.DeleteItemHistoryBatch(req.IDs)
+	deleted, err := db.DeleteItemHistoryBatch(handlers.HouseholdID(c), req.IDs)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "delete_failed",
@@ -132,7 +158,9 @@ func BatchDeleteHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"deleted": deleted,
-	})
+	if deleted > 0 {
+		handlers.BroadcastIndexUpdate(c, 0, handlers.EventHistoryDeleted, fiber.Map{"ids": req.IDs})
+	}
+
+	return c.JSON(BatchDeleteHistoryResponse{Deleted: deleted})
 }
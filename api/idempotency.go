@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Idempotency-Key handling
+const (
+	HeaderIdempotencyKey          = "Idempotency-Key"
+	HeaderIdempotentReplayed      = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength       = 255
+	DefaultIdempotencyWindowHours = 24
+)
+
+// IdempotencyWindow returns how long a response to a keyed POST is replayed,
+// from IDEMPOTENCY_WINDOW_HOURS (default 24)
+func IdempotencyWindow() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_WINDOW_HOURS"))
+	if err != nil || hours < 1 {
+		hours = DefaultIdempotencyWindowHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// idempotency builds middleware that makes POSTs carrying an Idempotency-Key
+// safe to retry: the first response for a key is stored for window and
+// replayed to repeats of the same request by the same token. Reusing a key
+// for a different request answers 422, and a repeat arriving while the first
+// is still being handled answers 409, for up to
+// db.IdempotencyReservationTimeout. Server errors and panics aren't stored,
+// so the request can be retried with the same key.
+func idempotency(window time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if c.Method() != fiber.MethodPost || key == "" {
+			return c.Next()
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "validation_error",
+				Message: "Idempotency-Key too long (max 255 characters)",
+			})
+		}
+
+		householdID := handlers.HouseholdID(c)
+		var tokenID int64
+		if token := currentToken(c); token != nil {
+			tokenID = token.ID
+		}
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+		hash.Write(c.Body())
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := db.ReserveIdempotencyKey(householdID, tokenID, key, fingerprint, window)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+				Error:   "db_error",
+				Message: "Failed to check Idempotency-Key",
+			})
+		}
+		if stored != nil {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{
+					Error:   "idempotency_key_reused",
+					Message: "Idempotency-Key was already used for a different request",
+				})
+			case stored.Status == 0:
+				return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+					Error:   "idempotency_in_progress",
+					Message: "A request with this Idempotency-Key is still being handled",
+				})
+			}
+			c.Set(HeaderIdempotentReplayed, "true")
+			if stored.ContentType != "" {
+				c.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			return c.Status(stored.Status).Send(stored.Body)
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				db.ReleaseIdempotencyKey(householdID, tokenID, key)
+				panic(r)
+			}
+		}()
+		err = c.Next()
+		status := c.Response().StatusCode()
+		if err != nil || status >= fiber.StatusInternalServerError {
+			if err := db.ReleaseIdempotencyKey(householdID, tokenID, key); err != nil {
+				log.Printf("[API] Failed to release Idempotency-Key: %v", err)
+			}
+			return err
+		}
+
+		contentType := string(c.Response().Header.ContentType())
+		if err := db.SaveIdempotentResponse(householdID, tokenID, key, status, contentType, c.Response().Body()); err != nil {
+			log.Printf("[API] Failed to store Idempotency-Key response: %v", err)
+			db.ReleaseIdempotencyKey(householdID, tokenID, key)
+		}
+		return nil
+	}
+}
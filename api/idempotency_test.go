@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"shopping-list/db"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	db.Init()
+	t.Cleanup(db.Close)
+
+	calls := 0
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Post("/things", idempotency(time.Hour), func(c *fiber.Ctx) error {
+		calls++
+		if calls == 1 {
+			panic("handler crashed")
+		}
+		return c.Status(fiber.StatusCreated).SendString("created")
+	})
+
+	post := func() int {
+		req := httptest.NewRequest("POST", "/things", nil)
+		req.Header.Set(HeaderIdempotencyKey, "key-1")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := post(); status != fiber.StatusInternalServerError {
+		t.Fatalf("crashing request: %d, want 500", status)
+	}
+	if status := post(); status != fiber.StatusCreated {
+		t.Fatalf("retry after the crash: %d, want 201", status)
+	}
+	if status := post(); status != fiber.StatusCreated || calls != 2 {
+		t.Fatalf("replay: %d after %d calls, want the stored 201 after 2", status, calls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"shopping-list/db"
 	"shopping-list/handlers"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +14,64 @@ const (
 	MaxDescriptionLength = 500
 )
 
+// GetItems returns the household's items across lists, filtered by
+// ?list_id=, ?section_id=, ?completed=, ?uncertain= and ?updated_since= and
+// paged by ?sort=, ?limit= and ?cursor=
+func GetItems(c *fiber.Ctx) error {
+	filter, err := parseItemFilter(c)
+	if err != nil {
+		return validationError(c, err)
+	}
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return validationError(c, err)
+	}
+
+	if raw := c.Query("list_id"); raw != "" {
+		listID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "invalid_id",
+				Message: "Invalid list ID",
+			})
+		}
+		if !ownsList(c, listID) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "List not found",
+			})
+		}
+		filter.ListID = listID
+	}
+	if raw := c.Query("section_id"); raw != "" {
+		sectionID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "invalid_id",
+				Message: "Invalid section ID",
+			})
+		}
+		if !ownsSection(c, sectionID) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Section not found",
+			})
+		}
+		filter.SectionID = sectionID
+	}
+
+	// A list-restricted token only sees its own list
+	if restricted := restrictedListID(c); restricted != 0 {
+		filter.ListID = restricted
+	}
+
+	items, next, err := db.FindItems(filter, page)
+	if err != nil {
+		return collectionError(c, err, "Failed to fetch items")
+	}
+	return c.JSON(ItemsResponse{Items: items, NextCursor: next})
+}
+
 // GetItem returns a single item by ID
 func GetItem(c *fiber.Ctx) error {
 	id, err := c.ParamsInt("id")
@@ -37,6 +96,7 @@ func GetItem(c *fiber.Ctx) error {
 		})
 	}
 
+	c.Set(fiber.HeaderETag, item.ETag())
 	return c.JSON(item)
 }
 
@@ -78,8 +138,25 @@ func CreateItem(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateQuantity(req.Quantity, req.Unit); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	if req.RecurDays < 0 || req.RecurDays > handlers.MaxRecurDays {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "recur_days must be between 0 and 365",
+		})
+	}
+
 	// Check if section exists
 	_, err := db.GetSectionByID(req.SectionID)
+	if err == nil && !ownsSection(c, req.SectionID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -93,7 +170,14 @@ func CreateItem(c *fiber.Ctx) error {
 		})
 	}
 
-	item, err := db.CreateItem(req.SectionID, req.Name, req.Description)
+	item, merged, err := db.AddItem(req.SectionID, req.Name, req.Description, req.Quantity, req.Unit, req.RecurDays)
+	if err == db.ErrDuplicateItem {
+		return c.Status(fiber.StatusConflict).JSON(DuplicateItemResponse{
+			Error:   "duplicate_item",
+			Message: "An open item with this name is already on the list",
+			Item:    item,
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "create_failed",
@@ -102,14 +186,31 @@ func CreateItem(c *fiber.Ctx) error {
 	}
 
 	// Save to item history for suggestions
-	db.SaveItemHistory(req.Name, req.SectionID)
+	db.SaveItemHistory(handlers.HouseholdID(c), req.Name, req.SectionID)
 
-	handlers.BroadcastUpdate("item_created", item)
+	if merged {
+		handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemUpdated, item)
+		return c.JSON(item)
+	}
+
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemCreated, item)
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
-// UpdateItem updates an item
+// UpdateItem replaces an item's name, description, quantity, flags and
+// recurrence. Omitted fields are cleared: no description or quantity, not
+// completed, not uncertain, not recurring.
 func UpdateItem(c *fiber.Ctx) error {
+	return updateItem(c, false, 1)
+}
+
+// PatchItem updates an item with a JSON Merge Patch; null clears a field
+func PatchItem(c *fiber.Ctx) error {
+	return updateItem(c, true, 1)
+}
+
+// updateItem applies a PUT or PATCH; attempt counts the merges of a PATCH
+func updateItem(c *fiber.Ctx, patch bool, attempt int) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
@@ -118,14 +219,6 @@ func UpdateItem(c *fiber.Ctx) error {
 		})
 	}
 
-	var req UpdateItemRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-			Error:   "invalid_json",
-			Message: "Failed to parse request body",
-		})
-	}
-
 	// Get existing item
 	existing, err := db.GetItemByID(int64(id))
 	if err != nil {
@@ -141,30 +234,71 @@ func UpdateItem(c *fiber.Ctx) error {
 		})
 	}
 
-	name := req.Name
-	if name == "" {
-		name = existing.Name
+	var req UpdateItemRequest
+	if patch {
+		req = UpdateItemRequest{
+			Name:        existing.Name,
+			Description: existing.Description,
+			Quantity:    existing.Quantity,
+			Unit:        existing.Unit,
+			Completed:   existing.Completed,
+			Uncertain:   existing.Uncertain,
+			RecurDays:   existing.RecurDays,
+		}
+		err = parseMergePatch(c, &req)
+	} else {
+		err = c.BodyParser(&req)
 	}
-	description := req.Description
-	if description == "" && req.Name != "" {
-		description = existing.Description
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
 	}
 
-	if len(name) > MaxItemNameLength {
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "Name is required",
+		})
+	}
+
+	if len(req.Name) > MaxItemNameLength {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error:   "validation_error",
 			Message: "Name exceeds maximum length of 200 characters",
 		})
 	}
 
-	if len(description) > MaxDescriptionLength {
+	if len(req.Description) > MaxDescriptionLength {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error:   "validation_error",
 			Message: "Description exceeds maximum length of 500 characters",
 		})
 	}
 
-	item, err := db.UpdateItem(int64(id), name, description)
+	if msg := validateQuantity(req.Quantity, req.Unit); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	if req.RecurDays < 0 || req.RecurDays > handlers.MaxRecurDays {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "recur_days must be between 0 and 365",
+		})
+	}
+
+	tags, retry := writeTags(c, patch, existing.ETag())
+	item, err := db.ReplaceItemIfMatch(int64(id), req.Name, req.Description, req.Quantity, req.Unit, req.Completed, req.Uncertain, req.RecurDays, tags)
+	if err == db.ErrPreconditionFailed {
+		if retry && attempt < maxPatchAttempts {
+			return updateItem(c, patch, attempt+1) // changed since it was read, merge again
+		}
+		return writeConflict(c, itemVersion)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "update_failed",
@@ -172,7 +306,8 @@ func UpdateItem(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("item_updated", item)
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemUpdated, item)
+	c.Set(fiber.HeaderETag, item.ETag())
 	return c.JSON(item)
 }
 
@@ -187,7 +322,7 @@ func DeleteItem(c *fiber.Ctx) error {
 	}
 
 	// Check if item exists
-	_, err = db.GetItemByID(int64(id))
+	item, err := db.GetItemByID(int64(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -201,14 +336,18 @@ func DeleteItem(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.DeleteItem(int64(id)); err != nil {
+	err = db.DeleteItemIfMatch(int64(id), ifMatchTags(c))
+	if err == db.ErrPreconditionFailed {
+		return writeConflict(c, itemVersion)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "delete_failed",
 			Message: "Failed to delete item",
 		})
 	}
 
-	handlers.BroadcastUpdate("item_deleted", map[string]int64{"id": int64(id)})
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemDeleted, map[string]int64{"id": int64(id), "section_id": item.SectionID})
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
@@ -245,7 +384,7 @@ func ToggleItemCompleted(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("item_toggled", item)
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemToggled, item)
 	return c.JSON(item)
 }
 
@@ -282,7 +421,7 @@ func ToggleItemUncertain(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("item_updated", item)
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemUpdated, item)
 	return c.JSON(item)
 }
 
@@ -328,6 +467,9 @@ func MoveItem(c *fiber.Ctx) error {
 
 	// Check if target section exists
 	_, err = db.GetSectionByID(req.SectionID)
+	if err == nil && !ownsSection(c, req.SectionID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -349,7 +491,7 @@ func MoveItem(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("item_moved", item)
+	handlers.BroadcastSectionUpdate(c, item.SectionID, handlers.EventItemMoved, item)
 	return c.JSON(item)
 }
 
@@ -385,7 +527,7 @@ func MoveItemUp(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("items_reordered", map[string]int64{"section_id": item.SectionID})
+	handlers.BroadcastItemsReordered(c, item.SectionID)
 
 	updatedItem, _ := db.GetItemByID(int64(id))
 	return c.JSON(updatedItem)
@@ -423,8 +565,19 @@ func MoveItemDown(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("items_reordered", map[string]int64{"section_id": item.SectionID})
+	handlers.BroadcastItemsReordered(c, item.SectionID)
 
 	updatedItem, _ := db.GetItemByID(int64(id))
 	return c.JSON(updatedItem)
 }
+
+// validateQuantity returns a validation message for an invalid quantity/unit pair, empty if valid
+func validateQuantity(quantity float64, unit string) string {
+	if !handlers.ValidQuantity(quantity) {
+		return "Quantity must be between 0 and 100000"
+	}
+	if len(unit) > handlers.MaxUnitLength {
+		return "Unit exceeds maximum length of 20 characters"
+	}
+	return ""
+}
@@ -13,16 +13,24 @@ const (
 	MaxIconLength     = 20
 )
 
-// GetLists returns all lists
+// GetLists returns the household's lists, filtered by ?updated_since= and
+// paged by ?sort=, ?limit= and ?cursor=
 func GetLists(c *fiber.Ctx) error {
-	lists, err := db.GetAllLists()
+	updatedSince, err := parseUpdatedSince(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error:   "db_error",
-			Message: "Failed to fetch lists",
-		})
+		return validationError(c, err)
+	}
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return validationError(c, err)
 	}
-	return c.JSON(ListsResponse{Lists: lists})
+
+	// A list-restricted token only sees its own list
+	lists, next, err := db.FindLists(handlers.HouseholdID(c), restrictedListID(c), updatedSince, page)
+	if err != nil {
+		return collectionError(c, err, "Failed to fetch lists")
+	}
+	return c.JSON(ListsResponse{Lists: lists, NextCursor: next})
 }
 
 // GetList returns a single list by ID
@@ -49,11 +57,19 @@ func GetList(c *fiber.Ctx) error {
 		})
 	}
 
+	c.Set(fiber.HeaderETag, list.ETag())
 	return c.JSON(list)
 }
 
 // CreateList creates a new list
 func CreateList(c *fiber.Ctx) error {
+	if restrictedListID(c) != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "API token is restricted to a single list",
+		})
+	}
+
 	var req CreateListRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
@@ -83,8 +99,15 @@ func CreateList(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.DuplicateMode != "" && !db.IsValidDuplicateMode(req.DuplicateMode) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "duplicate_mode must be 'merge' or 'reject'",
+		})
+	}
+
 	icon := NormalizeIcon(req.Icon)
-	list, err := db.CreateList(req.Name, icon)
+	list, err := db.CreateList(handlers.HouseholdID(c), req.Name, icon)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "create_failed",
@@ -92,12 +115,34 @@ func CreateList(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("list_created", list)
+	if req.DuplicateMode != "" {
+		list, err = db.SetListDuplicateMode(list.ID, req.DuplicateMode)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+				Error:   "create_failed",
+				Message: "Failed to set duplicate mode",
+			})
+		}
+	}
+
+	handlers.BroadcastList(c, handlers.EventListCreated, list)
 	return c.Status(fiber.StatusCreated).JSON(list)
 }
 
-// UpdateList updates a list
+// UpdateList replaces a list's name, icon and duplicate mode. Omitted
+// fields get their defaults: the 🛒 icon and the merge mode.
 func UpdateList(c *fiber.Ctx) error {
+	return updateList(c, false, 1)
+}
+
+// PatchList updates a list with a JSON Merge Patch; null resets a field to
+// its default
+func PatchList(c *fiber.Ctx) error {
+	return updateList(c, true, 1)
+}
+
+// updateList applies a PUT or PATCH; attempt counts the merges of a PATCH
+func updateList(c *fiber.Ctx, patch bool, attempt int) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
@@ -106,15 +151,6 @@ func UpdateList(c *fiber.Ctx) error {
 		})
 	}
 
-	var req UpdateListRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-			Error:   "invalid_json",
-			Message: "Failed to parse request body",
-		})
-	}
-
-	// Get existing list to check if it exists and for default values
 	existing, err := db.GetListByID(int64(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -129,25 +165,65 @@ func UpdateList(c *fiber.Ctx) error {
 		})
 	}
 
-	name := req.Name
-	if name == "" {
-		name = existing.Name
-	}
-	icon := req.Icon
-	if icon == "" {
-		icon = existing.Icon
+	var req UpdateListRequest
+	if patch {
+		req = UpdateListRequest{Name: existing.Name, Icon: existing.Icon, DuplicateMode: existing.DuplicateMode}
+		err = parseMergePatch(c, &req)
 	} else {
-		icon = NormalizeIcon(icon)
+		err = c.BodyParser(&req)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "Name is required",
+		})
 	}
 
-	if len(name) > MaxListNameLength {
+	if len(req.Name) > MaxListNameLength {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error:   "validation_error",
 			Message: "Name exceeds maximum length of 100 characters",
 		})
 	}
 
-	list, err := db.UpdateList(int64(id), name, icon)
+	if len(req.Icon) > MaxIconLength {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "Icon exceeds maximum length of 20 characters",
+		})
+	}
+
+	if req.DuplicateMode != "" && !db.IsValidDuplicateMode(req.DuplicateMode) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "duplicate_mode must be 'merge' or 'reject'",
+		})
+	}
+
+	icon := NormalizeIcon(req.Icon)
+	if icon == "" {
+		icon = DefaultIcon
+	}
+	duplicateMode := req.DuplicateMode
+	if duplicateMode == "" {
+		duplicateMode = db.DuplicateModeMerge
+	}
+
+	tags, retry := writeTags(c, patch, existing.ETag())
+	list, err := db.UpdateListIfMatch(int64(id), req.Name, icon, duplicateMode, tags)
+	if err == db.ErrPreconditionFailed {
+		if retry && attempt < maxPatchAttempts {
+			return updateList(c, patch, attempt+1) // changed since it was read, merge again
+		}
+		return writeConflict(c, listVersion)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "update_failed",
@@ -155,7 +231,8 @@ func UpdateList(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("list_updated", list)
+	handlers.BroadcastList(c, handlers.EventListUpdated, list)
+	c.Set(fiber.HeaderETag, list.ETag())
 	return c.JSON(list)
 }
 
@@ -184,18 +261,23 @@ func DeleteList(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.DeleteList(int64(id)); err != nil {
+	err = db.DeleteListIfMatch(int64(id), ifMatchTags(c))
+	if err == db.ErrPreconditionFailed {
+		return writeConflict(c, listVersion)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "delete_failed",
 			Message: "Failed to delete list",
 		})
 	}
 
-	handlers.BroadcastUpdate("list_deleted", map[string]int64{"id": int64(id)})
+	handlers.BroadcastIndexUpdate(c, int64(id), handlers.EventListDeleted, map[string]int64{"id": int64(id)})
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
-// GetListSections returns all sections for a list
+// GetListSections returns the sections of a list, filtered by
+// ?updated_since= and paged by ?sort=, ?limit= and ?cursor=
 func GetListSections(c *fiber.Ctx) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
@@ -220,15 +302,21 @@ func GetListSections(c *fiber.Ctx) error {
 		})
 	}
 
-	sections, err := db.GetSectionsByList(int64(id))
+	updatedSince, err := parseUpdatedSince(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error:   "db_error",
-			Message: "Failed to fetch sections",
-		})
+		return validationError(c, err)
+	}
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return validationError(c, err)
+	}
+
+	sections, next, err := db.FindSections(int64(id), updatedSince, page)
+	if err != nil {
+		return collectionError(c, err, "Failed to fetch sections")
 	}
 
-	return c.JSON(SectionsResponse{Sections: sections})
+	return c.JSON(SectionsResponse{Sections: sections, NextCursor: next})
 }
 
 // MoveListUp moves a list up in sort order
@@ -263,7 +351,7 @@ func MoveListUp(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("lists_reordered", nil)
+	handlers.BroadcastListsReordered(c)
 
 	list, _ := db.GetListByID(int64(id))
 	return c.JSON(list)
@@ -301,7 +389,7 @@ func MoveListDown(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("lists_reordered", nil)
+	handlers.BroadcastListsReordered(c)
 
 	list, _ := db.GetListByID(int64(id))
 	return c.JSON(list)
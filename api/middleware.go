@@ -1,12 +1,21 @@
 package api
 
 import (
+	"crypto/subtle"
+	"database/sql"
+	"log"
 	"os"
+	"shopping-list/db"
+	"shopping-list/handlers"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// LocalsAPIToken is the fiber.Ctx locals key holding the caller's *db.APIToken.
+// It is unset for the legacy API_TOKEN, which has full access.
+const LocalsAPIToken = "api_token"
+
 // GetAPIToken returns the API token from environment, empty if not set
 func GetAPIToken() string {
 	return os.Getenv("API_TOKEN")
@@ -17,16 +26,10 @@ func IsAPIEnabled() bool {
 	return GetAPIToken() != ""
 }
 
-// TokenAuthMiddleware validates Bearer token in Authorization header
+// TokenAuthMiddleware validates Bearer token in Authorization header. The
+// token is either the legacy API_TOKEN from the environment, which acts on the
+// default household with every scope, or one of the tokens in api_tokens.
 func TokenAuthMiddleware(c *fiber.Ctx) error {
-	expectedToken := GetAPIToken()
-	if expectedToken == "" {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
-			Error:   "api_disabled",
-			Message: "API is not enabled on this server",
-		})
-	}
-
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
@@ -44,12 +47,159 @@ func TokenAuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
-	if parts[1] != expectedToken {
+	if legacy := GetAPIToken(); legacy != "" && subtle.ConstantTimeCompare([]byte(parts[1]), []byte(legacy)) == 1 {
+		c.Locals(handlers.LocalsHouseholdID, db.DefaultHouseholdID)
+		c.Locals(handlers.LocalsActor, handlers.EventActor{Type: handlers.ActorToken, Name: "API_TOKEN"})
+		return c.Next()
+	}
+
+	token, err := db.GetAPITokenByHash(handlers.HashAPIToken(parts[1]))
+	if err == sql.ErrNoRows {
 		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
 			Error:   "invalid_token",
 			Message: "Invalid API token",
 		})
 	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to verify API token",
+		})
+	}
+	if token.Expired() {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+			Error:   "token_expired",
+			Message: "API token has expired",
+		})
+	}
+
+	if err := db.TouchAPIToken(token.ID); err != nil {
+		log.Printf("[API] Failed to record use of token %d: %v", token.ID, err)
+	}
+
+	c.Locals(LocalsAPIToken, token)
+	c.Locals(handlers.LocalsHouseholdID, token.HouseholdID)
+	c.Locals(handlers.LocalsActor, handlers.EventActor{Type: handlers.ActorToken, ID: token.ID, Name: token.Name})
+	return c.Next()
+}
+
+// currentToken returns the caller's token, nil for the legacy API_TOKEN
+func currentToken(c *fiber.Ctx) *db.APIToken {
+	token, _ := c.Locals(LocalsAPIToken).(*db.APIToken)
+	return token
+}
+
+// hasScope reports whether the caller was granted scope
+func hasScope(c *fiber.Ctx, scope string) bool {
+	token := currentToken(c)
+	return token == nil || token.HasScope(scope)
+}
+
+// isAdminCaller reports whether the caller acts as an admin: the legacy
+// API_TOKEN does, a token only while the user who minted it is an admin
+func isAdminCaller(c *fiber.Ctx) bool {
+	token := currentToken(c)
+	return token == nil || handlers.TokenCreatorIsAdmin(token.CreatedBy)
+}
+
+// adminRequired answers 403 for a caller that isn't acting as an admin
+func adminRequired(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+		Error:   "admin_required",
+		Message: "API token must be minted by an admin",
+	})
+}
+
+// restrictedListID returns the list the caller's token is limited to, 0 if none
+func restrictedListID(c *fiber.Ctx) int64 {
+	if token := currentToken(c); token != nil {
+		return token.ListID
+	}
+	return 0
+}
+
+// insufficientScope answers 403 for a caller lacking scope
+func insufficientScope(c *fiber.Ctx, scope string) error {
+	return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+		Error:   "insufficient_scope",
+		Message: "API token lacks the " + scope + " scope",
+	})
+}
+
+// requireScope builds route middleware that answers 403 unless the caller's
+// token was granted scope
+func requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !hasScope(c, scope) {
+			return insufficientScope(c, scope)
+		}
+		return c.Next()
+	}
+}
+
+// ownsList reports whether the list is visible to the caller: it belongs to
+// their household and, for a list-restricted token, is that list
+func ownsList(c *fiber.Ctx, id int64) bool {
+	if !handlers.OwnsList(c, id) {
+		return false
+	}
+	restricted := restrictedListID(c)
+	return restricted == 0 || restricted == id
+}
+
+// ownsSection is ownsList for a section's list
+func ownsSection(c *fiber.Ctx, id int64) bool {
+	if !handlers.OwnsSection(c, id) {
+		return false
+	}
+	if restricted := restrictedListID(c); restricted != 0 {
+		listID, err := db.SectionListID(id)
+		return err == nil && listID == restricted
+	}
+	return true
+}
+
+// ownsItem is ownsList for an item's list
+func ownsItem(c *fiber.Ctx, id int64) bool {
+	if !handlers.OwnsItem(c, id) {
+		return false
+	}
+	if restricted := restrictedListID(c); restricted != 0 {
+		listID, err := db.ItemListID(id)
+		return err == nil && listID == restricted
+	}
+	return true
+}
 
+// householdWide is route middleware for data spanning the household, such as
+// templates: it answers 403 to a list-restricted token
+func householdWide(c *fiber.Ctx) error {
+	if restrictedListID(c) != 0 {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "list_restricted",
+			Message: "API token is restricted to a single list",
+		})
+	}
 	return c.Next()
 }
+
+// householdAccess builds route middleware that answers 404 when the :id row
+// isn't visible to the caller (another household, or outside the token's list)
+func householdAccess(owns func(*fiber.Ctx, int64) bool, notFound string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "invalid_id",
+				Message: "Invalid ID",
+			})
+		}
+		if !owns(c, int64(id)) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: notFound,
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,501 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiOperation documents one /api/v1 route in the OpenAPI document. Request
+// and response bodies are zero values of the types the handler decodes and
+// encodes; their schemas are generated from the json tags.
+type apiOperation struct {
+	Method     string
+	Path       string // fiber path relative to /api/v1, e.g. /lists/:id
+	Tag        string
+	Summary    string
+	Scope      string      // required token scopes, space-separated; "" for none
+	Query      []apiParam  // query parameters
+	Request    interface{} // JSON body, nil if none
+	MergePatch bool        // Request is sent as a JSON Merge Patch, every field optional
+	Versioned  bool        // GET answers an ETag; writes accept If-Match and answer 412 on a mismatch
+	Status     int         // success status
+	Response   interface{} // JSON body of the success response, nil if none
+
+	// For endpoints that don't answer JSON
+	ContentType string
+	Public      bool // no token needed
+}
+
+// apiParam is a query parameter
+type apiParam struct {
+	Name        string
+	Type        string // integer, string or boolean
+	Description string
+	Required    bool
+}
+
+// Query parameters shared by the paged collections
+var (
+	updatedSinceParam = apiParam{Name: "updated_since", Type: "string", Description: "Only rows changed at or after this time, as unix seconds or RFC 3339"}
+	completedParam    = apiParam{Name: "completed", Type: "boolean", Description: "Only completed (true) or open (false) items"}
+	uncertainParam    = apiParam{Name: "uncertain", Type: "boolean", Description: "Only uncertain (true) or certain (false) items"}
+)
+
+// pageParams documents ?sort=, ?limit= and ?cursor= of a paged collection
+func pageParams(sorts, defaultSort, defaultLimit string, filters ...apiParam) []apiParam {
+	return append(filters,
+		apiParam{Name: "sort", Type: "string", Description: "One of " + sorts + ", prefixed with - for descending (default " + defaultSort + ")"},
+		apiParam{Name: "limit", Type: "integer", Description: "Rows per page, 1 to 500 (default " + defaultLimit + ")"},
+		apiParam{Name: "cursor", Type: "string", Description: "next_cursor of the previous page, with the same sort"},
+	)
+}
+
+// apiOperations lists every route registered by Register.
+// CheckOpenAPICoverage fails when they drift apart.
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/openapi.json", Tag: "Meta", Summary: "This OpenAPI document", Status: 200, Response: map[string]interface{}{}, Public: true},
+
+	{Method: "GET", Path: "/lists", Tag: "Lists", Summary: "List all lists", Scope: db.ScopeListsRead,
+		Query: pageParams("sort_order, name, updated_at, created_at", "sort_order", "all", updatedSinceParam), Status: 200, Response: ListsResponse{}},
+	{Method: "GET", Path: "/lists/:id", Tag: "Lists", Summary: "Get a list", Scope: db.ScopeListsRead, Versioned: true, Status: 200, Response: db.List{}},
+	{Method: "POST", Path: "/lists", Tag: "Lists", Summary: "Create a list", Scope: db.ScopeListsWrite, Request: CreateListRequest{}, Status: 201, Response: db.List{}},
+	{Method: "PUT", Path: "/lists/:id", Tag: "Lists", Summary: "Replace a list; omitted fields get their defaults", Scope: db.ScopeListsWrite, Request: UpdateListRequest{}, Versioned: true, Status: 200, Response: db.List{}},
+	{Method: "PATCH", Path: "/lists/:id", Tag: "Lists", Summary: "Update a list with a JSON Merge Patch", Scope: db.ScopeListsWrite, Request: UpdateListRequest{}, MergePatch: true, Versioned: true, Status: 200, Response: db.List{}},
+	{Method: "DELETE", Path: "/lists/:id", Tag: "Lists", Summary: "Delete a list with its sections and items", Scope: db.ScopeListsWrite, Versioned: true, Status: 204},
+	{Method: "GET", Path: "/lists/:id/sections", Tag: "Lists", Summary: "List a list's sections with their items", Scope: db.ScopeListsRead,
+		Query: pageParams("sort_order, name, updated_at, created_at", "sort_order", "all", updatedSinceParam), Status: 200, Response: SectionsResponse{}},
+	{Method: "POST", Path: "/lists/:id/move-up", Tag: "Lists", Summary: "Move a list up", Scope: db.ScopeListsWrite, Status: 200, Response: db.List{}},
+	{Method: "POST", Path: "/lists/:id/move-down", Tag: "Lists", Summary: "Move a list down", Scope: db.ScopeListsWrite, Status: 200, Response: db.List{}},
+
+	{Method: "GET", Path: "/sections/:id", Tag: "Sections", Summary: "Get a section", Scope: db.ScopeSectionsRead, Versioned: true, Status: 200, Response: db.Section{}},
+	{Method: "POST", Path: "/sections", Tag: "Sections", Summary: "Create a section", Scope: db.ScopeSectionsWrite, Request: CreateSectionRequest{}, Status: 201, Response: db.Section{}},
+	{Method: "PUT", Path: "/sections/:id", Tag: "Sections", Summary: "Replace a section", Scope: db.ScopeSectionsWrite, Request: UpdateSectionRequest{}, Versioned: true, Status: 200, Response: db.Section{}},
+	{Method: "PATCH", Path: "/sections/:id", Tag: "Sections", Summary: "Update a section with a JSON Merge Patch", Scope: db.ScopeSectionsWrite, Request: UpdateSectionRequest{}, MergePatch: true, Versioned: true, Status: 200, Response: db.Section{}},
+	{Method: "DELETE", Path: "/sections/:id", Tag: "Sections", Summary: "Delete a section with its items", Scope: db.ScopeSectionsWrite, Versioned: true, Status: 204},
+	{Method: "GET", Path: "/sections/:id/items", Tag: "Sections", Summary: "List a section's items", Scope: db.ScopeSectionsRead,
+		Query: pageParams("position, name, updated_at, created_at", "position", "all", completedParam, uncertainParam, updatedSinceParam), Status: 200, Response: ItemsResponse{}},
+	{Method: "POST", Path: "/sections/:id/move-up", Tag: "Sections", Summary: "Move a section up", Scope: db.ScopeSectionsWrite, Status: 200, Response: db.Section{}},
+	{Method: "POST", Path: "/sections/:id/move-down", Tag: "Sections", Summary: "Move a section down", Scope: db.ScopeSectionsWrite, Status: 200, Response: db.Section{}},
+
+	{Method: "GET", Path: "/items", Tag: "Items", Summary: "List items across lists", Scope: db.ScopeItemsRead,
+		Query: pageParams("position, name, updated_at, created_at", "position", "all",
+			apiParam{Name: "list_id", Type: "integer", Description: "Only items of this list"},
+			apiParam{Name: "section_id", Type: "integer", Description: "Only items of this section"},
+			completedParam, uncertainParam, updatedSinceParam), Status: 200, Response: ItemsResponse{}},
+	{Method: "GET", Path: "/items/:id", Tag: "Items", Summary: "Get an item", Scope: db.ScopeItemsRead, Versioned: true, Status: 200, Response: db.Item{}},
+	{Method: "POST", Path: "/items", Tag: "Items", Summary: "Create an item; answers 200 when merged into an open duplicate and 409 with the duplicate when the list rejects it", Scope: db.ScopeItemsWrite, Request: CreateItemRequest{}, Status: 201, Response: db.Item{}},
+	{Method: "PUT", Path: "/items/:id", Tag: "Items", Summary: "Replace an item; omitted fields are cleared", Scope: db.ScopeItemsWrite, Request: UpdateItemRequest{}, Versioned: true, Status: 200, Response: db.Item{}},
+	{Method: "PATCH", Path: "/items/:id", Tag: "Items", Summary: "Update an item with a JSON Merge Patch", Scope: db.ScopeItemsWrite, Request: UpdateItemRequest{}, MergePatch: true, Versioned: true, Status: 200, Response: db.Item{}},
+	{Method: "DELETE", Path: "/items/:id", Tag: "Items", Summary: "Delete an item", Scope: db.ScopeItemsWrite, Versioned: true, Status: 204},
+	{Method: "POST", Path: "/items/:id/toggle", Tag: "Items", Summary: "Toggle an item's completed flag", Scope: db.ScopeItemsWrite, Status: 200, Response: db.Item{}},
+	{Method: "POST", Path: "/items/:id/uncertain", Tag: "Items", Summary: "Toggle an item's uncertain flag", Scope: db.ScopeItemsWrite, Status: 200, Response: db.Item{}},
+	{Method: "POST", Path: "/items/:id/move", Tag: "Items", Summary: "Move an item to another section", Scope: db.ScopeItemsWrite, Request: MoveItemRequest{}, Status: 200, Response: db.Item{}},
+	{Method: "POST", Path: "/items/:id/move-up", Tag: "Items", Summary: "Move an item up", Scope: db.ScopeItemsWrite, Status: 200, Response: db.Item{}},
+	{Method: "POST", Path: "/items/:id/move-down", Tag: "Items", Summary: "Move an item down", Scope: db.ScopeItemsWrite, Status: 200, Response: db.Item{}},
+
+	{Method: "POST", Path: "/batch", Tag: "Batch", Summary: "Create a list, sections or items in one request", Scope: db.ScopeItemsWrite, Request: BatchCreateRequest{}, Status: 201, Response: BatchCreateResponse{}},
+
+	{Method: "GET", Path: "/search", Tag: "Search", Summary: "Search items, sections, lists and templates, grouped by list", Scope: db.ScopeItemsRead, Query: []apiParam{
+		{Name: "q", Type: "string", Description: "Words to find; each matches the start of a word (or anywhere, without FTS5)", Required: true},
+		{Name: "limit", Type: "integer", Description: "Matches of each kind to return, 1 to 100 (default 20)"},
+	}, Status: 200, Response: db.SearchResults{}},
+
+	{Method: "GET", Path: "/history", Tag: "History", Summary: "List the item history used for suggestions; not available to list-restricted tokens", Scope: db.ScopeHistoryRead,
+		Query: pageParams("usage_count, name, last_used_at", "-usage_count", "100",
+			apiParam{Name: "updated_since", Type: "string", Description: "Only entries used at or after this time, as unix seconds or RFC 3339"}), Status: 200, Response: HistoryResponse{}},
+	{Method: "POST", Path: "/history", Tag: "History", Summary: "Add a history entry", Scope: db.ScopeHistoryWrite, Request: CreateHistoryRequest{}, Status: 201, Response: CreateHistoryResponse{}},
+	{Method: "DELETE", Path: "/history/:id", Tag: "History", Summary: "Delete a history entry", Scope: db.ScopeHistoryWrite, Status: 204},
+	{Method: "POST", Path: "/history/batch-delete", Tag: "History", Summary: "Delete several history entries", Scope: db.ScopeHistoryWrite, Request: BatchDeleteHistoryRequest{}, Status: 200, Response: BatchDeleteHistoryResponse{}},
+
+	{Method: "GET", Path: "/templates", Tag: "Templates", Summary: "List templates with their items; not available to list-restricted tokens", Scope: db.ScopeTemplatesRead, Status: 200, Response: TemplatesResponse{}},
+	{Method: "POST", Path: "/templates", Tag: "Templates", Summary: "Create an empty template", Scope: db.ScopeTemplatesWrite, Request: TemplateRequest{}, Status: 201, Response: db.Template{}},
+	{Method: "POST", Path: "/templates/from-list", Tag: "Templates", Summary: "Save a list's open items as a new template", Scope: db.ScopeTemplatesWrite + " " + db.ScopeListsRead, Request: CreateTemplateFromListRequest{}, Status: 201, Response: db.Template{}},
+	{Method: "GET", Path: "/templates/:id", Tag: "Templates", Summary: "Get a template with its items", Scope: db.ScopeTemplatesRead, Versioned: true, Status: 200, Response: db.Template{}},
+	{Method: "PUT", Path: "/templates/:id", Tag: "Templates", Summary: "Replace a template's name and description", Scope: db.ScopeTemplatesWrite, Request: TemplateRequest{}, Versioned: true, Status: 200, Response: db.Template{}},
+	{Method: "PATCH", Path: "/templates/:id", Tag: "Templates", Summary: "Update a template with a JSON Merge Patch", Scope: db.ScopeTemplatesWrite, Request: TemplateRequest{}, MergePatch: true, Versioned: true, Status: 200, Response: db.Template{}},
+	{Method: "DELETE", Path: "/templates/:id", Tag: "Templates", Summary: "Delete a template with its items", Scope: db.ScopeTemplatesWrite, Versioned: true, Status: 204},
+	{Method: "POST", Path: "/templates/:id/items", Tag: "Templates", Summary: "Add an item to a template", Scope: db.ScopeTemplatesWrite, Request: TemplateItemRequest{}, Status: 201, Response: db.TemplateItem{}},
+	{Method: "PUT", Path: "/templates/:id/items/:itemId", Tag: "Templates", Summary: "Replace a template item; omitted fields are cleared", Scope: db.ScopeTemplatesWrite, Request: TemplateItemRequest{}, Status: 200, Response: db.TemplateItem{}},
+	{Method: "PATCH", Path: "/templates/:id/items/:itemId", Tag: "Templates", Summary: "Update a template item with a JSON Merge Patch", Scope: db.ScopeTemplatesWrite, Request: TemplateItemRequest{}, MergePatch: true, Status: 200, Response: db.TemplateItem{}},
+	{Method: "DELETE", Path: "/templates/:id/items/:itemId", Tag: "Templates", Summary: "Remove an item from a template", Scope: db.ScopeTemplatesWrite, Status: 204},
+	{Method: "POST", Path: "/templates/:id/apply", Tag: "Templates", Summary: "Add a template's items, or the picked ones, to a list with quantities scaled by multiplier, creating missing sections and merging items already on it", Scope: db.ScopeTemplatesRead + " " + db.ScopeItemsWrite, Request: ApplyTemplateRequest{}, Status: 200, Response: ApplyTemplateResponse{}},
+
+	{Method: "GET", Path: "/schedules", Tag: "Schedules", Summary: "List template schedules", Scope: db.ScopeTemplatesRead, Status: 200, Response: SchedulesResponse{}},
+	{Method: "POST", Path: "/schedules", Tag: "Schedules", Summary: "Apply a template to a list every N days or on weekdays, skipping items already open on it", Scope: db.ScopeTemplatesWrite + " " + db.ScopeItemsWrite, Request: ScheduleRequest{}, Status: 201, Response: db.TemplateSchedule{}},
+	{Method: "GET", Path: "/schedules/:id", Tag: "Schedules", Summary: "Get a template schedule", Scope: db.ScopeTemplatesRead, Status: 200, Response: db.TemplateSchedule{}},
+	{Method: "PUT", Path: "/schedules/:id", Tag: "Schedules", Summary: "Replace a template schedule; its next run is recomputed", Scope: db.ScopeTemplatesWrite + " " + db.ScopeItemsWrite, Request: ScheduleRequest{}, Status: 200, Response: db.TemplateSchedule{}},
+	{Method: "DELETE", Path: "/schedules/:id", Tag: "Schedules", Summary: "Delete a template schedule and its run history", Scope: db.ScopeTemplatesWrite, Status: 204},
+	{Method: "POST", Path: "/schedules/:id/run", Tag: "Schedules", Summary: "Run a template schedule now, keeping its next scheduled run", Scope: db.ScopeTemplatesWrite + " " + db.ScopeItemsWrite, Status: 200, Response: db.ScheduleRun{}},
+	{Method: "GET", Path: "/schedules/:id/runs", Tag: "Schedules", Summary: "List a template schedule's recent runs", Scope: db.ScopeTemplatesRead, Query: []apiParam{
+		{Name: "limit", Type: "integer", Description: "Runs to return, 1 to 100 (default 20)"},
+	}, Status: 200, Response: ScheduleRunsResponse{}},
+
+	{Method: "GET", Path: "/tokens", Tag: "Tokens", Summary: "List API tokens", Scope: db.ScopeTokensManage, Status: 200, Response: TokensResponse{}},
+	{Method: "POST", Path: "/tokens", Tag: "Tokens", Summary: "Mint an API token; the secret is only returned here. Granting settings:write takes a token minted by an admin", Scope: db.ScopeTokensManage, Request: CreateTokenRequest{}, Status: 201, Response: CreateTokenResponse{}},
+	{Method: "DELETE", Path: "/tokens/:id", Tag: "Tokens", Summary: "Revoke an API token", Scope: db.ScopeTokensManage, Status: 204},
+
+	{Method: "GET", Path: "/webhooks", Tag: "Webhooks", Summary: "List webhooks", Scope: db.ScopeWebhooksManage, Status: 200, Response: WebhooksResponse{}},
+	{Method: "POST", Path: "/webhooks", Tag: "Webhooks", Summary: "Register a webhook; the signing secret is only returned here", Scope: db.ScopeWebhooksManage, Request: WebhookRequest{}, Status: 201, Response: CreateWebhookResponse{}},
+	{Method: "GET", Path: "/webhooks/:id", Tag: "Webhooks", Summary: "Get a webhook", Scope: db.ScopeWebhooksManage, Status: 200, Response: db.Webhook{}},
+	{Method: "PUT", Path: "/webhooks/:id", Tag: "Webhooks", Summary: "Update a webhook", Scope: db.ScopeWebhooksManage, Request: WebhookRequest{}, Status: 200, Response: db.Webhook{}},
+	{Method: "DELETE", Path: "/webhooks/:id", Tag: "Webhooks", Summary: "Delete a webhook and its delivery log", Scope: db.ScopeWebhooksManage, Status: 204},
+	{Method: "GET", Path: "/webhooks/:id/deliveries", Tag: "Webhooks", Summary: "List a webhook's recent deliveries", Scope: db.ScopeWebhooksManage, Query: []apiParam{
+		{Name: "limit", Type: "integer", Description: "Deliveries to return, 1 to 200 (default 50)"},
+	}, Status: 200, Response: WebhookDeliveriesResponse{}},
+
+	{Method: "PUT", Path: "/settings/password", Tag: "Settings", Summary: "Change the shared app password; admins only, rate limited like logins", Scope: db.ScopeSettingsWrite, Request: ChangePasswordRequest{}, Status: 200, Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/events", Tag: "Live updates", Summary: "Stream events as Server-Sent Events; each data line is an Event", Scope: db.ScopeItemsRead, Query: []apiParam{
+		{Name: "lists", Type: "string", Description: "Comma-separated list IDs to follow"},
+		{Name: "index", Type: "boolean", Description: "Follow list-index events (needs lists:read)"},
+		{Name: "last_event_id", Type: "string", Description: "Resume after this event, like the Last-Event-ID header"},
+	}, Status: 200, ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/ws", Tag: "Live updates", Summary: "WebSocket carrying Event messages; authenticate with a Bearer token or ?ticket=", Scope: db.ScopeItemsRead, Query: []apiParam{
+		{Name: "ticket", Type: "string", Description: "Ticket from POST /ws/ticket, instead of the Authorization header"},
+	}, Status: 101},
+	{Method: "POST", Path: "/ws/ticket", Tag: "Live updates", Summary: "Mint a single-use ticket for opening /ws without an Authorization header", Scope: db.ScopeItemsRead, Status: 201, Response: WebSocketTicketResponse{}},
+}
+
+// openAPIExtraSchemas are component schemas not used by any operation body
+var openAPIExtraSchemas = []interface{}{handlers.Event{}}
+
+var (
+	openAPIDocument     []byte
+	openAPIDocumentOnce sync.Once
+)
+
+// GetOpenAPI serves the OpenAPI 3 document describing /api/v1
+func GetOpenAPI(c *fiber.Ctx) error {
+	openAPIDocumentOnce.Do(func() {
+		openAPIDocument, _ = json.Marshal(buildOpenAPI())
+	})
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(openAPIDocument)
+}
+
+// CheckOpenAPICoverage reports /api/v1 routes registered on app that are
+// missing from apiOperations, and documented operations without a route
+func CheckOpenAPICoverage(app *fiber.App) error {
+	documented := make(map[string]bool)
+	for _, op := range apiOperations {
+		documented[op.Method+" /api/v1"+op.Path] = true
+	}
+
+	var missing []string
+	registered := make(map[string]bool)
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		if registered[key] {
+			continue
+		}
+		registered[key] = true
+		if !documented[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	var stale []string
+	for key := range documented {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+
+	if len(missing) == 0 && len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return fmt.Errorf("OpenAPI document out of sync with /api/v1 routes: undocumented %v, not registered %v", missing, stale)
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// buildOpenAPI generates the document from apiOperations
+func buildOpenAPI() map[string]interface{} {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]map[string]interface{})
+
+	for _, op := range apiOperations {
+		path := pathParam.ReplaceAllString(op.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		var params []interface{}
+		for _, name := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name": name[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "integer", "format": "int64"},
+			})
+		}
+		for _, q := range op.Query {
+			param := map[string]interface{}{
+				"name": q.Name, "in": "query", "description": q.Description,
+				"schema": map[string]interface{}{"type": q.Type},
+			}
+			if q.Required {
+				param["required"] = true
+			}
+			params = append(params, param)
+		}
+
+		if op.Method == "POST" {
+			params = append(params, map[string]interface{}{
+				"name": HeaderIdempotencyKey, "in": "header",
+				"description": "Replay the stored response when the same request is retried with this key",
+				"schema":      map[string]interface{}{"type": "string", "maxLength": MaxIdempotencyKeyLength},
+			})
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(op.Status)}
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     jsonContent(schemas.schema(reflect.TypeOf(ErrorResponse{}))),
+			},
+		}
+		if op.Versioned {
+			if op.Method == "GET" || op.Response != nil {
+				success["headers"] = map[string]interface{}{
+					"ETag": map[string]interface{}{
+						"description": "Current version, for If-Match",
+						"schema":      map[string]interface{}{"type": "string"},
+					},
+				}
+			}
+			if op.Method != "GET" {
+				params = append(params, map[string]interface{}{
+					"name": "If-Match", "in": "header",
+					"description": "Only apply the change if the resource still has one of these ETags",
+					"schema":      map[string]interface{}{"type": "string"},
+				})
+				responses["412"] = map[string]interface{}{
+					"description": "The resource was modified since the given ETag",
+					"content":     jsonContent(schemas.schema(reflect.TypeOf(ErrorResponse{}))),
+				}
+			}
+		}
+		switch {
+		case op.ContentType != "":
+			success["content"] = map[string]interface{}{
+				op.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		case op.Response != nil:
+			success["content"] = jsonContent(schemas.schema(reflect.TypeOf(op.Response)))
+		}
+		responses[fmt.Sprint(op.Status)] = success
+
+		operation := map[string]interface{}{
+			"operationId": operationID(op),
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		switch {
+		case op.MergePatch:
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					MIMEMergePatch: map[string]interface{}{"schema": schemas.patchSchema(reflect.TypeOf(op.Request))},
+				},
+			}
+		case op.Request != nil:
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemas.schema(reflect.TypeOf(op.Request))),
+			}
+		}
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+		if scopes := strings.Fields(op.Scope); len(scopes) == 1 {
+			operation["description"] = "Requires the `" + scopes[0] + "` scope."
+		} else if len(scopes) > 1 {
+			operation["description"] = "Requires the `" + strings.Join(scopes, "` and `") + "` scopes."
+		}
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	for _, v := range openAPIExtraSchemas {
+		schemas.schema(reflect.TypeOf(v))
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Koffan REST API",
+			"version": "1",
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": "/api/v1"}},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A token from Settings → API tokens, or the legacy API_TOKEN",
+				},
+			},
+		},
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// operationID names an operation for generated clients, e.g. "getListsById"
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		if strings.HasPrefix(part, ":") {
+			part = "by_" + part[1:]
+		}
+		for _, word := range strings.Split(part, "_") {
+			if word != "" {
+				id += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return id
+}
+
+// schemaBuilder generates JSON schemas from Go types. Named structs become
+// components referenced with $ref.
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: make(map[string]interface{})}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem()), "nullable": true}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			b.components[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+			b.components[t.Name()] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default: // interface{}
+		return map[string]interface{}{}
+	}
+}
+
+// patchSchema registers "<Name>Patch", the struct's schema with every
+// field optional and nullable (null resets it), and references it
+func (b *schemaBuilder) patchSchema(t reflect.Type) map[string]interface{} {
+	name := t.Name() + "Patch"
+	if _, ok := b.components[name]; !ok {
+		object := b.object(t)
+		delete(object, "required")
+		properties := object["properties"].(map[string]interface{})
+		for key, property := range properties {
+			if _, ok := property.(map[string]interface{})["$ref"]; ok {
+				// $ref can't have siblings in OpenAPI 3.0
+				property = map[string]interface{}{"allOf": []interface{}{property}}
+				properties[key] = property
+			}
+			property.(map[string]interface{})["nullable"] = true
+		}
+		b.components[name] = object
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// object builds the schema of a struct from its json tags; fields without
+// omitempty are required, embedded structs are inlined like encoding/json does
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	b.addFields(t, properties, &required)
+
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			b.addFields(embedded, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
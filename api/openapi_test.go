@@ -0,0 +1,15 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	app := fiber.New()
+	Register(app)
+	if err := CheckOpenAPICoverage(app); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MIMEMergePatch is the content type of a JSON Merge Patch (RFC 7396)
+const MIMEMergePatch = "application/merge-patch+json"
+
+// mergePatch applies a JSON Merge Patch to a decoded JSON document: objects
+// are merged recursively, null removes a member, anything else replaces it
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]interface{})
+	if !ok {
+		docObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+		} else {
+			docObject[key] = mergePatch(docObject[key], value)
+		}
+	}
+	return docObject
+}
+
+// parseMergePatch applies the request body, a JSON Merge Patch, to req, a
+// pointer to a request struct holding the resource's current state. Removed
+// members end up as zero values, which the PUT handlers treat as defaults.
+func parseMergePatch(c *fiber.Ctx, req interface{}) error {
+	var patch interface{}
+	if err := json.Unmarshal(c.Body(), &patch); err != nil {
+		return err
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return errors.New("merge patch must be a JSON object")
+	}
+
+	current, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(req).Elem()
+	target.Set(reflect.Zero(target.Type()))
+	return json.Unmarshal(merged, req)
+}
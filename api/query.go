@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Collection paging limits
+const (
+	MaxPageLimit        = 500
+	DefaultHistoryLimit = 100 // history is paged even without ?limit=
+)
+
+// parsePage reads ?sort=, ?limit= and ?cursor=. defaultLimit applies without
+// ?limit=, 0 returning everything.
+func parsePage(c *fiber.Ctx, defaultLimit int) (db.PageRequest, error) {
+	page := db.PageRequest{
+		Sort:   c.Query("sort"),
+		Limit:  defaultLimit,
+		Cursor: c.Query("cursor"),
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// parseBoolQuery reads an optional true/false query parameter
+func parseBoolQuery(c *fiber.Ctx, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &value, nil
+}
+
+// parseUpdatedSince reads ?updated_since= as unix seconds or RFC 3339, 0
+// when absent
+func parseUpdatedSince(c *fiber.Ctx) (int64, error) {
+	raw := c.Query("updated_since")
+	if raw == "" {
+		return 0, nil
+	}
+	if since, err := strconv.ParseInt(raw, 10, 64); err == nil && since >= 0 {
+		return since, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("updated_since must be unix seconds or an RFC 3339 time")
+}
+
+// parseItemFilter reads ?completed=, ?uncertain= and ?updated_since= into a
+// filter over the caller's household
+func parseItemFilter(c *fiber.Ctx) (db.ItemFilter, error) {
+	filter := db.ItemFilter{HouseholdID: handlers.HouseholdID(c)}
+	var err error
+	if filter.Completed, err = parseBoolQuery(c, "completed"); err != nil {
+		return filter, err
+	}
+	if filter.Uncertain, err = parseBoolQuery(c, "uncertain"); err != nil {
+		return filter, err
+	}
+	filter.UpdatedSince, err = parseUpdatedSince(c)
+	return filter, err
+}
+
+// validationError answers 400 for a bad query parameter
+func validationError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+		Error:   "validation_error",
+		Message: err.Error(),
+	})
+}
+
+// collectionError answers a failed collection query: 400 for a bad sort or
+// cursor, 500 with message otherwise
+func collectionError(c *fiber.Ctx, err error, message string) error {
+	var pageErr *db.PageError
+	if errors.As(err, &pageErr) {
+		return validationError(c, pageErr)
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+		Error:   "db_error",
+		Message: message,
+	})
+}
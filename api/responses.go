@@ -11,19 +11,34 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-// ListsResponse wraps multiple lists
+// MessageResponse is a confirmation without other data
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// DuplicateItemResponse is returned when an item is already on the list
+type DuplicateItemResponse struct {
+	Error   string   `json:"error"`
+	Message string   `json:"message"`
+	Item    *db.Item `json:"item"`
+}
+
+// ListsResponse wraps multiple lists; NextCursor fetches the next page
 type ListsResponse struct {
-	Lists []db.List `json:"lists"`
+	Lists      []db.List `json:"lists"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
-// SectionsResponse wraps multiple sections
+// SectionsResponse wraps multiple sections; NextCursor fetches the next page
 type SectionsResponse struct {
-	Sections []db.Section `json:"sections"`
+	Sections   []db.Section `json:"sections"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
-// ItemsResponse wraps multiple items
+// ItemsResponse wraps multiple items; NextCursor fetches the next page
 type ItemsResponse struct {
-	Items []db.Item `json:"items"`
+	Items      []db.Item `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
 // BatchCreateRequest represents the request body for batch creation
@@ -55,8 +70,10 @@ type BatchSectionInput struct {
 
 // BatchItemInput represents an item for creation
 type BatchItemInput struct {
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
 }
 
 // BatchCreateResponse represents the response from batch creation
@@ -68,14 +85,17 @@ type BatchCreateResponse struct {
 
 // CreateListRequest for creating a new list
 type CreateListRequest struct {
-	Name string `json:"name"`
-	Icon string `json:"icon,omitempty"`
+	Name          string `json:"name"`
+	Icon          string `json:"icon,omitempty"`
+	DuplicateMode string `json:"duplicate_mode,omitempty"`
 }
 
-// UpdateListRequest for updating a list
+// UpdateListRequest is the writable state of a list: the body of PUT, and
+// the document PATCH merges its patch into
 type UpdateListRequest struct {
-	Name string `json:"name,omitempty"`
-	Icon string `json:"icon,omitempty"`
+	Name          string `json:"name"`
+	Icon          string `json:"icon,omitempty"`
+	DuplicateMode string `json:"duplicate_mode,omitempty"`
 }
 
 // CreateSectionRequest for creating a new section
@@ -84,24 +104,30 @@ type CreateSectionRequest struct {
 	Name   string `json:"name"`
 }
 
-// UpdateSectionRequest for updating a section
+// UpdateSectionRequest is the writable state of a section
 type UpdateSectionRequest struct {
 	Name string `json:"name"`
 }
 
 // CreateItemRequest for creating a new item
 type CreateItemRequest struct {
-	SectionID   int64  `json:"section_id"`
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
+	SectionID   int64   `json:"section_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+	RecurDays   int     `json:"recur_days,omitempty"` // back on the list this many days after being bought; a merge keeps the open item's
 }
 
-// UpdateItemRequest for updating an item
+// UpdateItemRequest is the writable state of an item
 type UpdateItemRequest struct {
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	Completed   *bool  `json:"completed,omitempty"`
-	Uncertain   *bool  `json:"uncertain,omitempty"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+	Completed   bool    `json:"completed,omitempty"`
+	Uncertain   bool    `json:"uncertain,omitempty"`
+	RecurDays   int     `json:"recur_days,omitempty"`
 }
 
 // MoveItemRequest for moving item to another section
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"shopping-list/db"
+	"shopping-list/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultScheduleRunsLimit is how many runs GetScheduleRuns returns without ?limit=
+const DefaultScheduleRunsLimit = 20
+
+// SchedulesResponse wraps multiple template schedules
+type SchedulesResponse struct {
+	Schedules []db.TemplateSchedule `json:"schedules"`
+}
+
+// ScheduleRequest for creating or replacing a template schedule. Set either
+// every_days or weekdays.
+type ScheduleRequest struct {
+	TemplateID int64    `json:"template_id"`
+	ListID     int64    `json:"list_id"`
+	EveryDays  int      `json:"every_days,omitempty"`
+	Weekdays   []string `json:"weekdays,omitempty"` // mon, tue, ... sun
+	Time       string   `json:"time"`               // HH:MM, server local time
+	Enabled    *bool    `json:"enabled,omitempty"`  // defaults to true
+}
+
+// ScheduleRunsResponse wraps a schedule's run history
+type ScheduleRunsResponse struct {
+	Runs []db.ScheduleRun `json:"runs"`
+}
+
+// parseScheduleRequest reads and validates a schedule request, answering the
+// error itself when it returns false
+func parseScheduleRequest(c *fiber.Ctx, schedule *db.TemplateSchedule) (bool, error) {
+	var req ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	*schedule = db.TemplateSchedule{
+		TemplateID: req.TemplateID,
+		ListID:     req.ListID,
+		EveryDays:  req.EveryDays,
+		Weekdays:   req.Weekdays,
+		Time:       req.Time,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := handlers.ValidateScheduleInput(handlers.HouseholdID(c), schedule); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+	return true, nil
+}
+
+// GetSchedules returns the household's template schedules
+func GetSchedules(c *fiber.Ctx) error {
+	schedules, err := db.GetTemplateSchedules(handlers.HouseholdID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch schedules",
+		})
+	}
+	return c.JSON(SchedulesResponse{Schedules: schedules})
+}
+
+// GetSchedule returns a single template schedule by ID
+func GetSchedule(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	schedule, err := db.GetTemplateScheduleByID(int64(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch schedule",
+		})
+	}
+	return c.JSON(schedule)
+}
+
+// CreateSchedule schedules a template to be applied to a list
+func CreateSchedule(c *fiber.Ctx) error {
+	var schedule db.TemplateSchedule
+	if ok, err := parseScheduleRequest(c, &schedule); !ok {
+		return err
+	}
+
+	created, err := db.CreateTemplateSchedule(handlers.HouseholdID(c), schedule)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create schedule",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// UpdateSchedule replaces a template schedule; its next run is recomputed
+func UpdateSchedule(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var schedule db.TemplateSchedule
+	if ok, err := parseScheduleRequest(c, &schedule); !ok {
+		return err
+	}
+
+	updated, err := db.UpdateTemplateSchedule(int64(id), schedule)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update schedule",
+		})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteSchedule removes a template schedule and its run history
+func DeleteSchedule(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	if err := db.DeleteTemplateSchedule(int64(id)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "delete_failed",
+			Message: "Failed to delete schedule",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RunSchedule applies a schedule's template now, without moving its next
+// scheduled run
+func RunSchedule(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	schedule, err := db.GetTemplateScheduleByID(int64(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch schedule",
+		})
+	}
+
+	run, err := handlers.RunTemplateSchedule(schedule, handlers.ActorFromContext(c), true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to record run",
+		})
+	}
+	return c.JSON(run)
+}
+
+// GetScheduleRuns returns a schedule's recent runs, newest first.
+// Query: ?limit= (default 20, max 100).
+func GetScheduleRuns(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	limit := c.QueryInt("limit", DefaultScheduleRunsLimit)
+	if limit < 1 || limit > db.MaxScheduleRuns {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "limit must be between 1 and 100",
+		})
+	}
+
+	runs, err := db.GetScheduleRuns(int64(id), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch runs",
+		})
+	}
+	return c.JSON(ScheduleRunsResponse{Runs: runs})
+}
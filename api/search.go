@@ -0,0 +1,49 @@
+package api
+
+import (
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Search finds items (open and completed), sections and lists by name, and
+// templates with their items, grouped by list. Query: ?q= (required),
+// ?limit= matches of each kind (default 20, max 100). A list-restricted
+// token only searches its list, and templates are only searched for tokens
+// with templates:read that aren't restricted.
+func Search(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "q is required",
+		})
+	}
+	if len(query) > handlers.MaxSearchQueryLength {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "q too long (max 200 characters)",
+		})
+	}
+
+	limit := c.QueryInt("limit", handlers.DefaultSearchLimit)
+	if limit < 1 || limit > handlers.MaxSearchLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "limit must be between 1 and 100",
+		})
+	}
+
+	restricted := restrictedListID(c)
+	withTemplates := restricted == 0 && hasScope(c, db.ScopeTemplatesRead)
+	results, err := db.Search(handlers.HouseholdID(c), restricted, query, withTemplates, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Search failed",
+		})
+	}
+	return c.JSON(results)
+}
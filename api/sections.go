@@ -36,6 +36,7 @@ func GetSection(c *fiber.Ctx) error {
 		})
 	}
 
+	c.Set(fiber.HeaderETag, section.ETag())
 	return c.JSON(section)
 }
 
@@ -72,6 +73,9 @@ func CreateSection(c *fiber.Ctx) error {
 
 	// Check if list exists
 	_, err := db.GetListByID(req.ListID)
+	if err == nil && !ownsList(c, req.ListID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -93,12 +97,21 @@ func CreateSection(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("section_created", section)
+	handlers.BroadcastUpdate(c, section.ListID, handlers.EventSectionCreated, section)
 	return c.Status(fiber.StatusCreated).JSON(section)
 }
 
-// UpdateSection updates a section
+// UpdateSection replaces a section's name
 func UpdateSection(c *fiber.Ctx) error {
+	return updateSection(c, false)
+}
+
+// PatchSection updates a section with a JSON Merge Patch
+func PatchSection(c *fiber.Ctx) error {
+	return updateSection(c, true)
+}
+
+func updateSection(c *fiber.Ctx, patch bool) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
@@ -107,8 +120,28 @@ func UpdateSection(c *fiber.Ctx) error {
 		})
 	}
 
+	existing, err := db.GetSectionByID(int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Section not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch section",
+		})
+	}
+
 	var req UpdateSectionRequest
-	if err := c.BodyParser(&req); err != nil {
+	if patch {
+		req = UpdateSectionRequest{Name: existing.Name}
+		err = parseMergePatch(c, &req)
+	} else {
+		err = c.BodyParser(&req)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error:   "invalid_json",
 			Message: "Failed to parse request body",
@@ -129,22 +162,10 @@ func UpdateSection(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if section exists
-	_, err = db.GetSectionByID(int64(id))
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
-				Error:   "not_found",
-				Message: "Section not found",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error:   "db_error",
-			Message: "Failed to fetch section",
-		})
+	section, err := db.UpdateSectionIfMatch(int64(id), req.Name, ifMatchTags(c))
+	if err == db.ErrPreconditionFailed {
+		return writeConflict(c, sectionVersion)
 	}
-
-	section, err := db.UpdateSection(int64(id), req.Name)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "update_failed",
@@ -152,7 +173,8 @@ func UpdateSection(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("section_updated", section)
+	handlers.BroadcastUpdate(c, section.ListID, handlers.EventSectionUpdated, section)
+	c.Set(fiber.HeaderETag, section.ETag())
 	return c.JSON(section)
 }
 
@@ -167,7 +189,7 @@ func DeleteSection(c *fiber.Ctx) error {
 	}
 
 	// Check if section exists
-	_, err = db.GetSectionByID(int64(id))
+	section, err := db.GetSectionByID(int64(id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -181,18 +203,23 @@ func DeleteSection(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.DeleteSection(int64(id)); err != nil {
+	err = db.DeleteSectionIfMatch(int64(id), ifMatchTags(c))
+	if err == db.ErrPreconditionFailed {
+		return writeConflict(c, sectionVersion)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
 			Error:   "delete_failed",
 			Message: "Failed to delete section",
 		})
 	}
 
-	handlers.BroadcastUpdate("section_deleted", map[string]int64{"id": int64(id)})
+	handlers.BroadcastUpdate(c, section.ListID, handlers.EventSectionDeleted, map[string]int64{"id": int64(id), "list_id": section.ListID})
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
-// GetSectionItems returns all items for a section
+// GetSectionItems returns the items of a section, filtered by ?completed=,
+// ?uncertain= and ?updated_since= and paged by ?sort=, ?limit= and ?cursor=
 func GetSectionItems(c *fiber.Ctx) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
@@ -217,15 +244,22 @@ func GetSectionItems(c *fiber.Ctx) error {
 		})
 	}
 
-	items, err := db.GetItemsBySection(int64(id))
+	filter, err := parseItemFilter(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error:   "db_error",
-			Message: "Failed to fetch items",
-		})
+		return validationError(c, err)
+	}
+	filter.SectionID = int64(id)
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return validationError(c, err)
+	}
+
+	items, next, err := db.FindItems(filter, page)
+	if err != nil {
+		return collectionError(c, err, "Failed to fetch items")
 	}
 
-	return c.JSON(ItemsResponse{Items: items})
+	return c.JSON(ItemsResponse{Items: items, NextCursor: next})
 }
 
 // MoveSectionUp moves a section up in sort order
@@ -260,7 +294,7 @@ func MoveSectionUp(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("sections_reordered", nil)
+	handlers.BroadcastSectionsReordered(c, int64(id))
 
 	section, _ := db.GetSectionByID(int64(id))
 	return c.JSON(section)
@@ -298,7 +332,7 @@ func MoveSectionDown(c *fiber.Ctx) error {
 		})
 	}
 
-	handlers.BroadcastUpdate("sections_reordered", nil)
+	handlers.BroadcastSectionsReordered(c, int64(id))
 
 	section, _ := db.GetSectionByID(int64(id))
 	return c.JSON(section)
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"shopping-list/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChangePasswordRequest for changing the shared app password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword changes the shared app password and logs out all browser
+// sessions. Like the settings page, it is limited to admins, and wrong
+// current passwords count against the login rate limit.
+func ChangePassword(c *fiber.Ctx) error {
+	if !isAdminCaller(c) {
+		return adminRequired(c)
+	}
+
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if err := handlers.ValidatePassword(req.NewPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+
+	err := handlers.ChangeAppPassword(c.IP(), req.CurrentPassword, req.NewPassword)
+	if err == handlers.ErrWrongPassword {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "wrong_password",
+			Message: "Current password is incorrect",
+		})
+	}
+	if err == handlers.ErrTooManyAttempts {
+		return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResponse{
+			Error:   "rate_limited",
+			Message: "Too many failed attempts, try again later",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to change password",
+		})
+	}
+
+	return c.JSON(MessageResponse{
+		Message: "Password changed, all sessions were logged out",
+	})
+}
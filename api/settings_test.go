@@ -0,0 +1,48 @@
+package api
+
+import (
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChangePasswordAdminAndRateLimit(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("APP_PASSWORD", "correct-password")
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "2")
+	handlers.InitAppPassword()
+	handlers.InitLoginRateLimiter()
+
+	admin, err := db.CreateUser(db.DefaultHouseholdID, "admin", "x", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := db.CreateUser(db.DefaultHouseholdID, "member", "x", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	Register(app)
+
+	change := func(token, current string) int {
+		return apiCall(t, app, "PUT", "/api/v1/settings/password", token,
+			`{"current_password": "`+current+`", "new_password": "another-password"}`)
+	}
+
+	// A member could have held settings:write since before it became admin-only
+	if status := change(mintToken(t, member.ID, 0, db.ScopeSettingsWrite), "correct-password"); status != fiber.StatusForbidden {
+		t.Errorf("member: %d, want 403", status)
+	}
+
+	adminToken := mintToken(t, admin.ID, 0, db.ScopeSettingsWrite)
+	for _, want := range []int{fiber.StatusForbidden, fiber.StatusForbidden, fiber.StatusTooManyRequests, fiber.StatusTooManyRequests} {
+		if status := change(adminToken, "guess"); status != want {
+			t.Fatalf("wrong password: %d, want %d", status, want)
+		}
+	}
+	if status := change(adminToken, "correct-password"); status != fiber.StatusTooManyRequests {
+		t.Errorf("right password while locked out: %d, want 429", status)
+	}
+}
@@ -0,0 +1,559 @@
+package api
+
+import (
+	"database/sql"
+	"shopping-list/db"
+	"shopping-list/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const MaxTemplateNameLength = 100
+
+// TemplatesResponse wraps multiple templates
+type TemplatesResponse struct {
+	Templates []db.Template `json:"templates"`
+}
+
+// TemplateRequest is the writable state of a template: the body of POST,
+// PUT, and the document a PATCH is merged into
+type TemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// TemplateItemRequest is the writable state of a template item
+type TemplateItemRequest struct {
+	SectionName string  `json:"section_name"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+}
+
+// ApplyTemplateRequest names the list a template is applied to, optionally
+// scaling quantities and picking some of its items
+type ApplyTemplateRequest struct {
+	ListID     int64   `json:"list_id"`
+	Multiplier float64 `json:"multiplier,omitempty"` // scales quantities; counted items round up (default 1)
+	ItemIDs    []int64 `json:"item_ids,omitempty"`   // template items to add (default all)
+}
+
+// ApplyTemplateResponse is the list a template was applied to, with all its
+// sections and items
+type ApplyTemplateResponse struct {
+	TemplateID int64        `json:"template_id"`
+	List       *db.List     `json:"list"`
+	Sections   []db.Section `json:"sections"`
+}
+
+// CreateTemplateFromListRequest for saving a list's open items as a template
+type CreateTemplateFromListRequest struct {
+	ListID      int64  `json:"list_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// validateTemplate returns why a template's state is invalid, "" if it isn't
+func validateTemplate(name, description string) string {
+	if name == "" {
+		return "Name is required"
+	}
+	if len(name) > MaxTemplateNameLength {
+		return "Name exceeds maximum length of 100 characters"
+	}
+	if len(description) > MaxDescriptionLength {
+		return "Description exceeds maximum length of 500 characters"
+	}
+	return ""
+}
+
+// validateTemplateItem returns why a template item's state is invalid, ""
+// if it isn't
+func validateTemplateItem(req TemplateItemRequest) string {
+	if req.SectionName == "" {
+		return "section_name is required"
+	}
+	if len(req.SectionName) > MaxSectionNameLength {
+		return "section_name exceeds maximum length of 100 characters"
+	}
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if len(req.Name) > MaxItemNameLength {
+		return "Name exceeds maximum length of 200 characters"
+	}
+	if len(req.Description) > MaxDescriptionLength {
+		return "Description exceeds maximum length of 500 characters"
+	}
+	return validateQuantity(req.Quantity, req.Unit)
+}
+
+// GetTemplates returns the household's templates with their items
+func GetTemplates(c *fiber.Ctx) error {
+	templates, err := db.GetAllTemplates(handlers.HouseholdID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch templates",
+		})
+	}
+
+	if templates == nil {
+		templates = []db.Template{}
+	}
+
+	return c.JSON(TemplatesResponse{Templates: templates})
+}
+
+// GetTemplate returns a single template with its items
+func GetTemplate(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+
+	template, err := db.GetTemplateByID(int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Template not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch template",
+		})
+	}
+
+	c.Set(fiber.HeaderETag, template.ETag())
+	return c.JSON(template)
+}
+
+// CreateTemplate creates an empty template
+func CreateTemplate(c *fiber.Ctx) error {
+	var req TemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if msg := validateTemplate(req.Name, req.Description); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	template, err := db.CreateTemplate(handlers.HouseholdID(c), req.Name, req.Description)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create template",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateCreated, template)
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateTemplate replaces a template's name and description; an omitted
+// description is cleared
+func UpdateTemplate(c *fiber.Ctx) error {
+	return updateTemplate(c, false, 1)
+}
+
+// PatchTemplate updates a template with a JSON Merge Patch
+func PatchTemplate(c *fiber.Ctx) error {
+	return updateTemplate(c, true, 1)
+}
+
+// updateTemplate applies a PUT or PATCH; attempt counts the merges of a PATCH
+func updateTemplate(c *fiber.Ctx, patch bool, attempt int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+
+	existing, err := db.GetTemplateByID(int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Template not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch template",
+		})
+	}
+
+	var req TemplateRequest
+	if patch {
+		req = TemplateRequest{Name: existing.Name, Description: existing.Description}
+		err = parseMergePatch(c, &req)
+	} else {
+		err = c.BodyParser(&req)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if msg := validateTemplate(req.Name, req.Description); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	tags, retry := writeTags(c, patch, existing.ETag())
+	template, err := db.UpdateTemplateIfMatch(int64(id), req.Name, req.Description, tags)
+	if err == db.ErrPreconditionFailed {
+		if retry && attempt < maxPatchAttempts {
+			return updateTemplate(c, patch, attempt+1) // changed since it was read, merge again
+		}
+		return writeConflict(c, templateVersion)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update template",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateUpdated, template)
+	c.Set(fiber.HeaderETag, template.ETag())
+	return c.JSON(template)
+}
+
+// DeleteTemplate deletes a template with its items
+func DeleteTemplate(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+
+	err = db.DeleteTemplateIfMatch(int64(id), ifMatchTags(c))
+	if err == db.ErrPreconditionFailed {
+		return writeConflict(c, templateVersion)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "delete_failed",
+			Message: "Failed to delete template",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateDeleted, map[string]int64{"id": int64(id)})
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateTemplateItem adds an item to a template
+func CreateTemplateItem(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+
+	var req TemplateItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if msg := validateTemplateItem(req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	item, err := db.AddTemplateItem(int64(id), req.SectionName, req.Name, req.Description, req.Quantity, req.Unit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to add item to template",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateItemCreated, item)
+	return c.Status(fiber.StatusCreated).JSON(item)
+}
+
+// templateItemAccess is route middleware answering 404 when the :itemId
+// item belongs to another template than :id
+func templateItemAccess(c *fiber.Ctx) error {
+	templateID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+	itemID, err := c.ParamsInt("itemId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template item ID",
+		})
+	}
+	if owner, err := db.TemplateItemTemplateID(int64(itemID)); err != nil || owner != int64(templateID) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+			Error:   "not_found",
+			Message: "Template item not found",
+		})
+	}
+	return c.Next()
+}
+
+// UpdateTemplateItem replaces a template item; omitted fields are cleared
+func UpdateTemplateItem(c *fiber.Ctx) error {
+	return updateTemplateItem(c, false)
+}
+
+// PatchTemplateItem updates a template item with a JSON Merge Patch
+func PatchTemplateItem(c *fiber.Ctx) error {
+	return updateTemplateItem(c, true)
+}
+
+func updateTemplateItem(c *fiber.Ctx, patch bool) error {
+	itemID, err := c.ParamsInt("itemId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template item ID",
+		})
+	}
+
+	existing, err := db.GetTemplateItemByID(int64(itemID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Template item not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch template item",
+		})
+	}
+
+	var req TemplateItemRequest
+	if patch {
+		req = TemplateItemRequest{
+			SectionName: existing.SectionName,
+			Name:        existing.Name,
+			Description: existing.Description,
+			Quantity:    existing.Quantity,
+			Unit:        existing.Unit,
+		}
+		err = parseMergePatch(c, &req)
+	} else {
+		err = c.BodyParser(&req)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if msg := validateTemplateItem(req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	item, err := db.UpdateTemplateItem(existing.ID, req.SectionName, req.Name, req.Description, req.Quantity, req.Unit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to update template item",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateItemUpdated, item)
+	return c.JSON(item)
+}
+
+// DeleteTemplateItem removes an item from a template
+func DeleteTemplateItem(c *fiber.Ctx) error {
+	templateID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+	itemID, err := c.ParamsInt("itemId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template item ID",
+		})
+	}
+
+	if err := db.DeleteTemplateItem(int64(itemID)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "delete_failed",
+			Message: "Failed to delete template item",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateItemDeleted, map[string]int64{"id": int64(itemID), "template_id": int64(templateID)})
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ApplyTemplate adds a template's items to a list, creating missing sections
+// and merging items already on it
+func ApplyTemplate(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid template ID",
+		})
+	}
+
+	var req ApplyTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if req.ListID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "list_id is required",
+		})
+	}
+
+	opts := db.ApplyTemplateOptions{Multiplier: req.Multiplier, ItemIDs: req.ItemIDs}
+	if err := handlers.ValidateApplyOptions(int64(id), opts); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+
+	list, err := db.GetListByID(req.ListID)
+	if err == nil && !ownsList(c, req.ListID) {
+		err = sql.ErrNoRows // Another household, or outside the token's list
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "List not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch list",
+		})
+	}
+
+	if err := db.ApplyTemplateToList(int64(id), list.ID, opts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "update_failed",
+			Message: "Failed to apply template",
+		})
+	}
+
+	handlers.BroadcastTemplateApplied(c, int64(id), list.ID)
+
+	list, err = db.GetListByID(list.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch list",
+		})
+	}
+	list.Stats = db.GetListStats(list.ID)
+	sections, err := db.GetSectionsByList(list.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch sections",
+		})
+	}
+	return c.JSON(ApplyTemplateResponse{TemplateID: int64(id), List: list, Sections: sections})
+}
+
+// CreateTemplateFromList saves a list's open items as a new template
+func CreateTemplateFromList(c *fiber.Ctx) error {
+	var req CreateTemplateFromListRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+
+	if req.ListID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "list_id is required",
+		})
+	}
+
+	if msg := validateTemplate(req.Name, req.Description); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: msg,
+		})
+	}
+
+	_, err := db.GetListByID(req.ListID)
+	if err == nil && !ownsList(c, req.ListID) {
+		err = sql.ErrNoRows // Another household
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "List not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch list",
+		})
+	}
+
+	template, err := db.CreateTemplateFromList(req.ListID, req.Name, req.Description)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create template from list",
+		})
+	}
+
+	handlers.BroadcastIndexUpdate(c, 0, handlers.EventTemplateCreated, template)
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
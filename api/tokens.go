@@ -0,0 +1,134 @@
+package api
+
+import (
+	"database/sql"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TokensResponse wraps multiple API tokens
+type TokensResponse struct {
+	Tokens []db.APIToken `json:"tokens"`
+}
+
+// CreateTokenRequest for minting a new API token
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ListID        int64    `json:"list_id,omitempty"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+// CreateTokenResponse is the new token plus its secret, returned only once
+type CreateTokenResponse struct {
+	*db.APIToken
+	Token string `json:"token"`
+}
+
+// GetTokens returns the household's API tokens. A list-restricted caller
+// only sees tokens restricted to the same list.
+func GetTokens(c *fiber.Ctx) error {
+	tokens, err := db.GetAPITokens(handlers.HouseholdID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch tokens",
+		})
+	}
+
+	visible := []db.APIToken{}
+	restricted := restrictedListID(c)
+	for _, token := range tokens {
+		if restricted == 0 || token.ListID == restricted {
+			visible = append(visible, token)
+		}
+	}
+	return c.JSON(TokensResponse{Tokens: visible})
+}
+
+// CreateToken mints a new API token. The caller can't grant scopes it
+// doesn't hold or widen its own list restriction, and only an admin caller
+// can grant admin-only scopes. The new token inherits the caller's creator.
+func CreateToken(c *fiber.Ctx) error {
+	var req CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+
+	if err := handlers.ValidateAPITokenInput(c, req.Name, req.Scopes, req.ListID, req.ExpiresInDays); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+
+	for _, scope := range req.Scopes {
+		if !hasScope(c, scope) {
+			return insufficientScope(c, scope)
+		}
+	}
+	if scope := handlers.AdminOnlyScope(req.Scopes); scope != "" && !isAdminCaller(c) {
+		return adminRequired(c)
+	}
+	if restricted := restrictedListID(c); restricted != 0 && req.ListID != restricted {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "API token can only create tokens restricted to its own list",
+		})
+	}
+
+	var createdBy int64
+	if caller := currentToken(c); caller != nil {
+		createdBy = caller.CreatedBy
+	}
+	token, secret, err := handlers.MintAPIToken(handlers.HouseholdID(c), req.Name, req.Scopes, req.ListID, req.ExpiresInDays, createdBy)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create token",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateTokenResponse{APIToken: token, Token: secret})
+}
+
+// DeleteToken revokes an API token
+func DeleteToken(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid token ID",
+		})
+	}
+
+	token, err := db.GetAPITokenByID(int64(id))
+	if err == nil && (token.HouseholdID != handlers.HouseholdID(c) ||
+		(restrictedListID(c) != 0 && token.ListID != restrictedListID(c))) {
+		err = sql.ErrNoRows // Another household, or outside the caller's list
+	}
+	if err == nil {
+		err = db.DeleteAPIToken(token.HouseholdID, token.ID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error:   "not_found",
+				Message: "Token not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "delete_failed",
+			Message: "Failed to revoke token",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
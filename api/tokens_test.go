@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setupTestDB points the db package at a fresh database for one test
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	db.Init()
+	t.Cleanup(db.Close)
+}
+
+// mintToken creates a token for the default household on behalf of createdBy
+func mintToken(t *testing.T, createdBy int64, listID int64, scopes ...string) string {
+	t.Helper()
+	_, secret, err := handlers.MintAPIToken(db.DefaultHouseholdID, "test", scopes, listID, 0, createdBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// apiCall sends a JSON request with the token to the registered API routes
+// and returns the response status
+func apiCall(t *testing.T, app *fiber.App, method, path, token, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestCreateTokenAdminOnlyScope(t *testing.T) {
+	setupTestDB(t)
+	admin, err := db.CreateUser(db.DefaultHouseholdID, "admin", "x", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := db.CreateUser(db.DefaultHouseholdID, "member", "x", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	Register(app)
+
+	body := `{"name": "settings", "scopes": ["settings:write"]}`
+	memberToken := mintToken(t, member.ID, 0, db.ScopeTokensManage, db.ScopeSettingsWrite)
+	if status := apiCall(t, app, "POST", "/api/v1/tokens", memberToken, body); status != fiber.StatusForbidden {
+		t.Errorf("token minted by a member: %d, want 403", status)
+	}
+	adminToken := mintToken(t, admin.ID, 0, db.ScopeTokensManage, db.ScopeSettingsWrite)
+	if status := apiCall(t, app, "POST", "/api/v1/tokens", adminToken, body); status != fiber.StatusCreated {
+		t.Errorf("token minted by an admin: %d, want 201", status)
+	}
+}
+
+func TestListRestrictedTokenHouseholdWide(t *testing.T) {
+	setupTestDB(t)
+	list, err := db.CreateList(db.DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	Register(app)
+
+	token := mintToken(t, 0, list.ID, db.ScopeHistoryRead, db.ScopeHistoryWrite)
+	for _, route := range [][2]string{
+		{"GET", "/api/v1/history"},
+		{"POST", "/api/v1/history"},
+		{"DELETE", "/api/v1/history/1"},
+		{"POST", "/api/v1/history/batch-delete"},
+	} {
+		if status := apiCall(t, app, route[0], route[1], token, `{}`); status != fiber.StatusForbidden {
+			t.Errorf("%s %s: %d, want 403", route[0], route[1], status)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Webhook API limits
+const (
+	MinWebhookSecretLength = 16
+	DefaultDeliveriesLimit = 50
+	MaxDeliveriesLimit     = 200
+)
+
+// WebhooksResponse wraps multiple webhooks
+type WebhooksResponse struct {
+	Webhooks []db.Webhook `json:"webhooks"`
+}
+
+// WebhookRequest for creating or replacing a webhook
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // create only; generated when empty
+	Events []string `json:"events,omitempty"` // empty for all events
+	ListID int64    `json:"list_id,omitempty"`
+	Active *bool    `json:"active,omitempty"` // defaults to true
+}
+
+// CreateWebhookResponse is the new webhook plus its signing secret, returned only once
+type CreateWebhookResponse struct {
+	*db.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookDeliveriesResponse wraps a webhook's delivery log
+type WebhookDeliveriesResponse struct {
+	Deliveries []db.WebhookDelivery `json:"deliveries"`
+}
+
+// ownsWebhook is ownsList for a webhook: a list-restricted token only sees
+// webhooks filtered to its list
+func ownsWebhook(c *fiber.Ctx, id int64) bool {
+	if !handlers.OwnsWebhook(c, id) {
+		return false
+	}
+	if restricted := restrictedListID(c); restricted != 0 {
+		webhook, err := db.GetWebhookByID(id)
+		return err == nil && webhook.ListID == restricted
+	}
+	return true
+}
+
+// parseWebhookRequest reads and validates a webhook request, answering the
+// error itself when it returns false
+func parseWebhookRequest(c *fiber.Ctx, req *WebhookRequest) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "invalid_json",
+			Message: "Failed to parse request body",
+		})
+	}
+	req.URL = strings.TrimSpace(req.URL)
+
+	if err := handlers.ValidateWebhookInput(handlers.HouseholdID(c), req.URL, req.Events, req.ListID); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	}
+	if restricted := restrictedListID(c); restricted != 0 && req.ListID != restricted {
+		return false, c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Error:   "insufficient_scope",
+			Message: "API token can only manage webhooks filtered to its own list",
+		})
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	return true, nil
+}
+
+// GetWebhooks returns the household's webhooks
+func GetWebhooks(c *fiber.Ctx) error {
+	webhooks, err := db.GetWebhooks(handlers.HouseholdID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch webhooks",
+		})
+	}
+
+	visible := []db.Webhook{}
+	restricted := restrictedListID(c)
+	for _, webhook := range webhooks {
+		if restricted == 0 || webhook.ListID == restricted {
+			visible = append(visible, webhook)
+		}
+	}
+	return c.JSON(WebhooksResponse{Webhooks: visible})
+}
+
+// GetWebhook returns a single webhook by ID
+func GetWebhook(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	webhook, err := db.GetWebhookByID(int64(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch webhook",
+		})
+	}
+	return c.JSON(webhook)
+}
+
+// CreateWebhook registers a webhook. The signing secret is generated unless
+// given, and is only returned here.
+func CreateWebhook(c *fiber.Ctx) error {
+	var req WebhookRequest
+	if ok, err := parseWebhookRequest(c, &req); !ok {
+		return err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = handlers.GenerateWebhookSecret(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+				Error:   "create_failed",
+				Message: "Failed to create webhook",
+			})
+		}
+	} else if len(secret) < MinWebhookSecretLength {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "Secret too short (min 16 characters)",
+		})
+	}
+
+	active := req.Active == nil || *req.Active
+	webhook, err := db.CreateWebhook(handlers.HouseholdID(c), req.URL, secret, req.Events, req.ListID, active)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(CreateWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// UpdateWebhook replaces a webhook's URL, filters and active flag; the
+// secret can't be changed
+func UpdateWebhook(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var req WebhookRequest
+	if ok, err := parseWebhookRequest(c, &req); !ok {
+		return err
+	}
+
+	active := req.Active == nil || *req.Active
+	webhook, err := db.UpdateWebhook(int64(id), req.URL, req.Events, req.ListID, active)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to update webhook",
+		})
+	}
+	return c.JSON(webhook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	if err := db.DeleteWebhook(int64(id)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "delete_failed",
+			Message: "Failed to delete webhook",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetWebhookDeliveries returns a webhook's recent deliveries, newest first.
+// Query: ?limit= (default 50, max 200).
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	limit := c.QueryInt("limit", DefaultDeliveriesLimit)
+	if limit < 1 || limit > MaxDeliveriesLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_error",
+			Message: "limit must be between 1 and 200",
+		})
+	}
+
+	deliveries, err := db.GetWebhookDeliveries(int64(id), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "db_error",
+			Message: "Failed to fetch deliveries",
+		})
+	}
+	return c.JSON(WebhookDeliveriesResponse{Deliveries: deliveries})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"shopping-list/db"
+	"shopping-list/handlers"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// wsTicketLifetime is how long a WebSocket ticket can be redeemed
+const wsTicketLifetime = 30 * time.Second
+
+// WebSocketTicketResponse is a single-use ticket for opening /api/v1/ws from
+// clients that can't send an Authorization header (browsers)
+type WebSocketTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// wsTicket is the caller a ticket was minted for
+type wsTicket struct {
+	token       *db.APIToken // nil for the legacy API_TOKEN
+	householdID int64
+	actor       handlers.EventActor
+	expiresAt   time.Time
+}
+
+// Outstanding tickets by secret, guarded by wsTicketsMu
+var (
+	wsTickets   = make(map[string]wsTicket)
+	wsTicketsMu sync.Mutex
+)
+
+// CreateWebSocketTicket mints a ticket carrying the caller's token, to be
+// passed as /api/v1/ws?ticket=... within wsTicketLifetime
+func CreateWebSocketTicket(c *fiber.Ctx) error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+			Error:   "create_failed",
+			Message: "Failed to create ticket",
+		})
+	}
+	secret := hex.EncodeToString(bytes)
+
+	ticket := wsTicket{
+		token:       currentToken(c),
+		householdID: handlers.HouseholdID(c),
+		actor:       handlers.ActorFromContext(c),
+		expiresAt:   time.Now().Add(wsTicketLifetime),
+	}
+
+	wsTicketsMu.Lock()
+	for key, t := range wsTickets {
+		if time.Now().After(t.expiresAt) {
+			delete(wsTickets, key)
+		}
+	}
+	wsTickets[secret] = ticket
+	wsTicketsMu.Unlock()
+
+	return c.Status(fiber.StatusCreated).JSON(WebSocketTicketResponse{
+		Ticket:    secret,
+		ExpiresAt: ticket.expiresAt.Unix(),
+	})
+}
+
+// redeemWebSocketTicket removes a ticket and reports whether it was still valid
+func redeemWebSocketTicket(secret string) (wsTicket, bool) {
+	wsTicketsMu.Lock()
+	defer wsTicketsMu.Unlock()
+	ticket, ok := wsTickets[secret]
+	delete(wsTickets, secret)
+	if !ok || time.Now().After(ticket.expiresAt) {
+		return wsTicket{}, false
+	}
+	if ticket.token != nil && ticket.token.Expired() {
+		return wsTicket{}, false
+	}
+	return ticket, true
+}
+
+// WebSocketAuth authenticates /api/v1/ws with ?ticket= from
+// CreateWebSocketTicket, or else like TokenAuthMiddleware with a Bearer token
+func WebSocketAuth(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(ErrorResponse{
+			Error:   "upgrade_required",
+			Message: "This endpoint only accepts WebSocket connections",
+		})
+	}
+
+	secret := c.Query("ticket")
+	if secret == "" {
+		return TokenAuthMiddleware(c)
+	}
+
+	ticket, ok := redeemWebSocketTicket(secret)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+			Error:   "invalid_ticket",
+			Message: "Invalid or expired WebSocket ticket",
+		})
+	}
+	if ticket.token != nil {
+		c.Locals(LocalsAPIToken, ticket.token)
+	}
+	c.Locals(handlers.LocalsHouseholdID, ticket.householdID)
+	c.Locals(handlers.LocalsActor, ticket.actor)
+	return c.Next()
+}
+
+// webSocketAccess limits the connection's subscriptions to what the token
+// may read: its list if restricted, the list index only with lists:read
+func webSocketAccess(c *fiber.Ctx) error {
+	restricted := restrictedListID(c)
+	c.Locals(handlers.LocalsEventAccess, handlers.EventAccess{
+		ListID:  restricted,
+		NoIndex: restricted != 0 || !hasScope(c, db.ScopeListsRead),
+	})
+	return c.Next()
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"shopping-list/i18n"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -154,6 +156,49 @@ func runMigrations() {
 
 	// Migration: Add icon to lists
 	migrateListIcons()
+
+	// Migration: Quantities and units on items and template items
+	migrateItemQuantities()
+
+	// Migration: Per-list duplicate item handling
+	migrateListDuplicateMode()
+
+	// Migration: User accounts
+	migrateUsers()
+
+	// Migration: Households owning lists, templates and item history
+	migrateHouseholds()
+
+	// Migration: Key/value app settings (hashed app password)
+	migrateSettings()
+
+	// Migration: Named, scoped API tokens
+	migrateAPITokens()
+
+	// Migration: Change log for delta sync
+	migrateChangeLog()
+
+	// Migration: Outgoing webhooks and their delivery log
+	migrateWebhooks()
+
+	// Migration: Stored responses for REST Idempotency-Key replays
+	migrateIdempotencyKeys()
+
+	// Migration: Scheduled template application and its run history
+	migrateTemplateSchedules()
+
+	// Migration: Recurring items coming back after being bought
+	migrateRecurringItems()
+
+	// Migration: Ordered template sections
+	migrateTemplateSections()
+
+	// Migration: User who minted each API token
+	migrateAPITokenCreators()
+
+	// Migration: Full-text search index (rebuilt when missing, e.g. after
+	// running a binary built without FTS5)
+	migrateSearchIndex()
 }
 
 func migrateToMultipleLists() {
@@ -299,6 +344,734 @@ func migrateListIcons() {
 	log.Println("Migration completed: List icons added")
 }
 
+func migrateItemQuantities() {
+	// Check if quantity column exists in items
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name='quantity'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding quantity and unit to items...")
+
+	_, err = DB.Exec(`
+		ALTER TABLE items ADD COLUMN quantity REAL DEFAULT 0;
+		ALTER TABLE items ADD COLUMN unit TEXT DEFAULT '';
+		ALTER TABLE template_items ADD COLUMN quantity REAL DEFAULT 0;
+		ALTER TABLE template_items ADD COLUMN unit TEXT DEFAULT '';
+	`)
+	if err != nil {
+		log.Println("Migration failed - adding quantity and unit:", err)
+		return
+	}
+
+	log.Println("Migration completed: Item quantities and units added")
+}
+
+func migrateListDuplicateMode() {
+	// Check if duplicate_mode column exists in lists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('lists') WHERE name='duplicate_mode'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding duplicate_mode to lists...")
+
+	_, err = DB.Exec("ALTER TABLE lists ADD COLUMN duplicate_mode TEXT DEFAULT 'merge'")
+	if err != nil {
+		log.Println("Migration failed - adding duplicate_mode to lists:", err)
+		return
+	}
+
+	log.Println("Migration completed: List duplicate mode added")
+}
+
+func migrateUsers() {
+	// Check if users table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='users'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding user accounts...")
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			password_hash TEXT NOT NULL,
+			is_admin BOOLEAN DEFAULT FALSE,
+			disabled BOOLEAN DEFAULT FALSE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at INTEGER DEFAULT (strftime('%s', 'now'))
+		);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating users table:", err)
+		return
+	}
+
+	_, err = DB.Exec("ALTER TABLE sessions ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE")
+	if err != nil {
+		log.Println("Migration failed - adding user_id to sessions:", err)
+		return
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id)")
+	if err != nil {
+		log.Println("Migration warning - creating sessions user index:", err)
+	}
+
+	log.Println("Migration completed: User accounts added")
+}
+
+func migrateHouseholds() {
+	// Check if households table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='households'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding households...")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Println("Migration failed - starting transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS households (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating households table:", err)
+		return
+	}
+
+	// Everything that exists so far belongs to the default household
+	defaultName := i18n.Get(i18n.GetDefaultLang(), "households.default_name")
+	_, err = tx.Exec(`INSERT INTO households (id, name) VALUES (?, ?)`, DefaultHouseholdID, defaultName)
+	if err != nil {
+		log.Println("Migration failed - creating default household:", err)
+		return
+	}
+
+	for _, table := range []string{"lists", "templates", "users"} {
+		_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN household_id INTEGER REFERENCES households(id) ON DELETE CASCADE")
+		if err != nil {
+			log.Printf("Migration failed - adding household_id to %s: %v", table, err)
+			return
+		}
+		_, err = tx.Exec("UPDATE "+table+" SET household_id = ?", DefaultHouseholdID)
+		if err != nil {
+			log.Printf("Migration failed - updating %s with household_id: %v", table, err)
+			return
+		}
+	}
+
+	// item_history names were unique globally; rebuild it to make them unique per household
+	_, err = tx.Exec(`
+		CREATE TABLE item_history_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			name TEXT NOT NULL COLLATE NOCASE,
+			last_section_id INTEGER,
+			usage_count INTEGER DEFAULT 1,
+			last_used_at INTEGER DEFAULT (strftime('%s', 'now')),
+			UNIQUE(household_id, name COLLATE NOCASE)
+		);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating new item_history table:", err)
+		return
+	}
+	_, err = tx.Exec(`
+		INSERT INTO item_history_new (id, household_id, name, last_section_id, usage_count, last_used_at)
+		SELECT id, ?, name, last_section_id, usage_count, last_used_at FROM item_history
+	`, DefaultHouseholdID)
+	if err != nil {
+		log.Println("Migration failed - copying item_history:", err)
+		return
+	}
+	_, err = tx.Exec(`
+		DROP TABLE item_history;
+		ALTER TABLE item_history_new RENAME TO item_history;
+		CREATE INDEX IF NOT EXISTS idx_item_history_name ON item_history(name COLLATE NOCASE);
+		CREATE INDEX IF NOT EXISTS idx_lists_household ON lists(household_id, sort_order);
+		CREATE INDEX IF NOT EXISTS idx_templates_household ON templates(household_id, sort_order);
+	`)
+	if err != nil {
+		log.Println("Migration failed - replacing item_history table:", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Migration failed - committing households:", err)
+		return
+	}
+
+	log.Println("Migration completed: Households added")
+}
+
+func migrateSettings() {
+	// Check if settings table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='settings'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding settings...")
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at INTEGER DEFAULT (strftime('%s', 'now'))
+		);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating settings table:", err)
+		return
+	}
+
+	log.Println("Migration completed: Settings added")
+}
+
+func migrateAPITokens() {
+	// Check if api_tokens table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='api_tokens'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding API tokens...")
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			scopes TEXT NOT NULL DEFAULT '',
+			list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE,
+			expires_at INTEGER,
+			last_used_at INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_api_tokens_household ON api_tokens(household_id);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating api_tokens table:", err)
+		return
+	}
+
+	log.Println("Migration completed: API tokens added")
+}
+
+func migrateChangeLog() {
+	// Check if change_log table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='change_log'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding change log...")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Println("Migration failed - starting transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Every insert, update and delete of a list, section or item appends a
+	// row; seq is the sync cursor. Rows deleted by a cascade are skipped (their
+	// parent is gone too), clients drop children along with a deleted parent.
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS change_log (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			op TEXT NOT NULL,
+			changed_at INTEGER DEFAULT (strftime('%s', 'now'))
+		);
+		CREATE INDEX IF NOT EXISTS idx_change_log_household ON change_log(household_id, seq);
+
+		CREATE TRIGGER IF NOT EXISTS change_log_lists_insert AFTER INSERT ON lists BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT NEW.household_id, 'list', NEW.id, 'insert' WHERE NEW.household_id IS NOT NULL;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_lists_update AFTER UPDATE ON lists BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT NEW.household_id, 'list', NEW.id, 'update' WHERE NEW.household_id IS NOT NULL;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_lists_delete AFTER DELETE ON lists BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT OLD.household_id, 'list', OLD.id, 'delete' WHERE OLD.household_id IS NOT NULL;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS change_log_sections_insert AFTER INSERT ON sections BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT household_id, 'section', NEW.id, 'insert' FROM lists WHERE id = NEW.list_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_sections_update AFTER UPDATE ON sections BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT household_id, 'section', NEW.id, 'update' FROM lists WHERE id = NEW.list_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_sections_delete AFTER DELETE ON sections BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT household_id, 'section', OLD.id, 'delete' FROM lists WHERE id = OLD.list_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS change_log_items_insert AFTER INSERT ON items BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT l.household_id, 'item', NEW.id, 'insert'
+			FROM sections s JOIN lists l ON l.id = s.list_id WHERE s.id = NEW.section_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_items_update AFTER UPDATE ON items BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT l.household_id, 'item', NEW.id, 'update'
+			FROM sections s JOIN lists l ON l.id = s.list_id WHERE s.id = NEW.section_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS change_log_items_delete AFTER DELETE ON items BEGIN
+			INSERT INTO change_log (household_id, entity, entity_id, op)
+			SELECT l.household_id, 'item', OLD.id, 'delete'
+			FROM sections s JOIN lists l ON l.id = s.list_id WHERE s.id = OLD.section_id;
+		END;
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating change_log table:", err)
+		return
+	}
+
+	// Seed the log with the existing rows so the first cursor covers them
+	_, err = tx.Exec(`
+		INSERT INTO change_log (household_id, entity, entity_id, op)
+		SELECT household_id, 'list', id, 'insert' FROM lists WHERE household_id IS NOT NULL;
+		INSERT INTO change_log (household_id, entity, entity_id, op)
+		SELECT l.household_id, 'section', s.id, 'insert' FROM sections s JOIN lists l ON l.id = s.list_id;
+		INSERT INTO change_log (household_id, entity, entity_id, op)
+		SELECT l.household_id, 'item', i.id, 'insert'
+		FROM items i JOIN sections s ON s.id = i.section_id JOIN lists l ON l.id = s.list_id;
+	`)
+	if err != nil {
+		log.Println("Migration failed - seeding change_log:", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Migration failed - committing change log:", err)
+		return
+	}
+
+	log.Println("Migration completed: Change log added")
+}
+
+func migrateWebhooks() {
+	// Check if webhooks table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='webhooks'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding webhooks...")
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhooks_household ON webhooks(household_id);
+
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER,
+			error TEXT,
+			next_attempt_at INTEGER,
+			delivered_at INTEGER,
+			created_at INTEGER DEFAULT (strftime('%s', 'now'))
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating webhooks tables:", err)
+		return
+	}
+
+	log.Println("Migration completed: Webhooks added")
+}
+
+func migrateIdempotencyKeys() {
+	// Check if idempotency_keys table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='idempotency_keys'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding idempotency keys...")
+
+	// token_id is 0 for the legacy API_TOKEN; status is 0 while the first
+	// request is still being handled
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			household_id INTEGER NOT NULL,
+			token_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL DEFAULT '',
+			body BLOB,
+			created_at INTEGER DEFAULT (strftime('%s', 'now')),
+			PRIMARY KEY (household_id, token_id, key)
+		);
+		CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating idempotency_keys table:", err)
+		return
+	}
+
+	log.Println("Migration completed: Idempotency keys added")
+}
+
+func migrateTemplateSchedules() {
+	// Check if template_schedules table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='template_schedules'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding template schedules...")
+
+	// A schedule runs every every_days days, or on the listed weekdays, at
+	// at_time (HH:MM, server local time); next_run_at is NULL while disabled
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS template_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			template_id INTEGER NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+			list_id INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+			every_days INTEGER NOT NULL DEFAULT 0,
+			weekdays TEXT NOT NULL DEFAULT '',
+			at_time TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at INTEGER,
+			last_run_at INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_template_schedules_household ON template_schedules(household_id);
+		CREATE INDEX IF NOT EXISTS idx_template_schedules_due ON template_schedules(enabled, next_run_at);
+
+		CREATE TABLE IF NOT EXISTS template_schedule_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id INTEGER NOT NULL REFERENCES template_schedules(id) ON DELETE CASCADE,
+			status TEXT NOT NULL,
+			items_added INTEGER NOT NULL DEFAULT 0,
+			items_skipped INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			manual BOOLEAN NOT NULL DEFAULT FALSE,
+			ran_at INTEGER DEFAULT (strftime('%s', 'now'))
+		);
+		CREATE INDEX IF NOT EXISTS idx_template_schedule_runs_schedule ON template_schedule_runs(schedule_id, id);
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating template schedule tables:", err)
+		return
+	}
+
+	log.Println("Migration completed: Template schedules added")
+}
+
+func migrateRecurringItems() {
+	// Check if recur_days column exists in items
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name='recur_days'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding recurring items...")
+
+	// A completed item with recur_days set is due back at recurs_at. Clearing
+	// completed items moves the recurring ones to recurring_items until then.
+	_, err = DB.Exec(`
+		ALTER TABLE items ADD COLUMN recur_days INTEGER DEFAULT 0;
+		ALTER TABLE items ADD COLUMN recurs_at INTEGER;
+		CREATE INDEX IF NOT EXISTS idx_items_recurs_at ON items(recurs_at);
+
+		CREATE TABLE IF NOT EXISTS recurring_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			section_id INTEGER NOT NULL REFERENCES sections(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			quantity REAL NOT NULL DEFAULT 0,
+			unit TEXT NOT NULL DEFAULT '',
+			recur_days INTEGER NOT NULL,
+			recurs_at INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_recurring_items_recurs_at ON recurring_items(recurs_at);
+	`)
+	if err != nil {
+		log.Println("Migration failed - adding recurring items:", err)
+		return
+	}
+
+	log.Println("Migration completed: Recurring items added")
+}
+
+func migrateTemplateSections() {
+	// Check if template_sections table exists
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='template_sections'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding template sections...")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Println("Migration failed - starting transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The order of a template's sections; template items still name their
+	// section. Existing templates keep the order their sections first appear
+	// in, which for templates saved from a list is the list's order.
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS template_sections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			template_id INTEGER NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+			name TEXT NOT NULL COLLATE NOCASE,
+			sort_order INTEGER NOT NULL,
+			UNIQUE (template_id, name)
+		);
+		CREATE INDEX IF NOT EXISTS idx_template_sections_order ON template_sections(template_id, sort_order);
+
+		INSERT INTO template_sections (template_id, name, sort_order)
+		SELECT template_id, section_name, ROW_NUMBER() OVER (PARTITION BY template_id ORDER BY MIN(sort_order), MIN(id)) - 1
+		FROM template_items
+		GROUP BY template_id, section_name COLLATE NOCASE;
+	`)
+	if err != nil {
+		log.Println("Migration failed - creating template_sections table:", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Migration failed - committing template sections:", err)
+		return
+	}
+
+	log.Println("Migration completed: Template sections added")
+}
+
+// searchIndexes are the FTS5 tables behind Search, each an external content
+// index of a table's text columns kept in sync by triggers
+var searchIndexes = []struct{ table, columns string }{
+	{"lists", "name"},
+	{"sections", "name"},
+	{"items", "name, description"},
+	{"templates", "name, description"},
+	{"template_items", "name, description"},
+}
+
+func migrateAPITokenCreators() {
+	// Check if created_by column exists in api_tokens
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('api_tokens') WHERE name='created_by'").Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count > 0 {
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Adding API token creators...")
+
+	// Existing tokens keep a NULL creator, like tokens minted with the shared password
+	_, err = DB.Exec("ALTER TABLE api_tokens ADD COLUMN created_by INTEGER REFERENCES users(id) ON DELETE SET NULL")
+	if err != nil {
+		log.Println("Migration failed - adding api_tokens.created_by:", err)
+		return
+	}
+
+	log.Println("Migration completed: api_tokens.created_by added")
+}
+
+func migrateSearchIndex() {
+	var fts5 bool
+	if err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if !fts5 {
+		// The index can't be written without FTS5, so drop its triggers
+		// before they break every write; the next FTS5 build rebuilds it
+		for _, index := range searchIndexes {
+			for _, op := range []string{"insert", "update", "delete"} {
+				if _, err := DB.Exec("DROP TRIGGER IF EXISTS search_" + index.table + "_" + op); err != nil {
+					log.Println("Migration failed - dropping search triggers:", err)
+					return
+				}
+			}
+		}
+		log.Println("SQLite was built without FTS5 (build tag sqlite_fts5), search falls back to LIKE")
+		return
+	}
+
+	// Check if every search trigger exists
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='trigger' AND name LIKE 'search\_%' ESCAPE '\'`).Scan(&count)
+	if err != nil {
+		log.Println("Migration check failed:", err)
+		return
+	}
+
+	if count == len(searchIndexes)*3 {
+		searchFTS = true
+		return // Already migrated
+	}
+
+	log.Println("Running migration: Building search index...")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Println("Migration failed - starting transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, index := range searchIndexes {
+		fts := "search_" + index.table
+		cols := strings.Split(index.columns, ", ")
+		newValues := "new." + strings.Join(cols, ", new.")
+		oldValues := "old." + strings.Join(cols, ", old.")
+
+		_, err = tx.Exec(fmt.Sprintf(`
+			CREATE VIRTUAL TABLE IF NOT EXISTS %[1]s USING fts5(
+				%[3]s, content='%[2]s', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+			);
+			CREATE TRIGGER IF NOT EXISTS %[1]s_insert AFTER INSERT ON %[2]s BEGIN
+				INSERT INTO %[1]s (rowid, %[3]s) VALUES (new.id, %[4]s);
+			END;
+			CREATE TRIGGER IF NOT EXISTS %[1]s_update AFTER UPDATE OF %[3]s ON %[2]s BEGIN
+				INSERT INTO %[1]s (%[1]s, rowid, %[3]s) VALUES ('delete', old.id, %[5]s);
+				INSERT INTO %[1]s (rowid, %[3]s) VALUES (new.id, %[4]s);
+			END;
+			CREATE TRIGGER IF NOT EXISTS %[1]s_delete AFTER DELETE ON %[2]s BEGIN
+				INSERT INTO %[1]s (%[1]s, rowid, %[3]s) VALUES ('delete', old.id, %[5]s);
+			END;
+			INSERT INTO %[1]s (%[1]s) VALUES ('rebuild');
+		`, fts, index.table, index.columns, newValues, oldValues))
+		if err != nil {
+			log.Printf("Migration failed - creating %s: %v", fts, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Migration failed - committing search index:", err)
+		return
+	}
+
+	searchFTS = true
+	log.Println("Migration completed: Search index built")
+}
+
 func Close() {
 	if DB != nil {
 		DB.Close()
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ETags identify a version of a row for optimistic concurrency. updated_at
+// alone only changes once per second and isn't bumped by reordering, so a
+// hash of the row's own fields is appended. Nested data (a list's stats, a
+// section's items) is left out: changing an item doesn't conflict with
+// renaming its list.
+
+func etag(updatedAt int64, fields ...interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", fields)))
+	return `"` + strconv.FormatInt(updatedAt, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ETag returns the list's current version
+func (l *List) ETag() string {
+	return etag(l.UpdatedAt, l.ID, l.Name, l.Icon, l.DuplicateMode, l.SortOrder, l.IsActive)
+}
+
+// ETag returns the section's current version, ignoring its items
+func (s *Section) ETag() string {
+	return etag(s.UpdatedAt, s.ID, s.ListID, s.Name, s.SortOrder)
+}
+
+// ETag returns the item's current version
+func (i *Item) ETag() string {
+	return etag(i.UpdatedAt, i.ID, i.SectionID, i.Name, i.Description, i.Quantity, i.Unit, i.Completed, i.Uncertain, i.SortOrder, i.RecurDays)
+}
+
+// ETag returns the template's current version, ignoring its items
+func (t *Template) ETag() string {
+	return etag(t.UpdatedAt, t.ID, t.Name, t.Description, t.SortOrder)
+}
+
+// ErrPreconditionFailed is returned by a conditional write when the row no
+// longer has any of the expected ETags
+var ErrPreconditionFailed = errors.New("the row was modified since the given ETag")
+
+// ETagMatches reports whether current is one of the If-Match tags; "*"
+// matches any version and weak ETags never match
+func ETagMatches(tags []string, current string) bool {
+	for _, tag := range tags {
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIfMatch runs write in a transaction once the row's version is one of
+// tags; no tags skip the check. The transaction takes the database write lock
+// before reading the version, so no other write lands between the two.
+func writeIfMatch(table string, tags []string, version func(*sql.Tx) (string, error), write func(*sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(tags) > 0 {
+		if _, err := tx.Exec(`UPDATE ` + table + ` SET id = id WHERE FALSE`); err != nil {
+			return err
+		}
+		current, err := version(tx)
+		if err == sql.ErrNoRows {
+			return ErrPreconditionFailed // deleted since; not even "*" matches
+		}
+		if err != nil {
+			return err
+		}
+		if !ETagMatches(tags, current) {
+			return ErrPreconditionFailed
+		}
+	}
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateListIfMatch sets a list's name, icon and duplicate mode if its
+// version is one of tags
+func UpdateListIfMatch(id int64, name, icon, duplicateMode string, tags []string) (*List, error) {
+	err := writeIfMatch("lists", tags, listVersionTx(id), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE lists SET name = ?, icon = ?, duplicate_mode = ?, updated_at = strftime('%s', 'now') WHERE id = ?
+		`, name, icon, duplicateMode, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetListByID(id)
+}
+
+// DeleteListIfMatch deletes a list if its version is one of tags
+func DeleteListIfMatch(id int64, tags []string) error {
+	return writeIfMatch("lists", tags, listVersionTx(id), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM lists WHERE id = ?`, id)
+		return err
+	})
+}
+
+// UpdateSectionIfMatch renames a section if its version is one of tags
+func UpdateSectionIfMatch(id int64, name string, tags []string) (*Section, error) {
+	err := writeIfMatch("sections", tags, sectionVersionTx(id), func(tx *sql.Tx) error {
+		_, err := UpdateSectionTx(tx, id, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSectionByID(id)
+}
+
+// DeleteSectionIfMatch deletes a section if its version is one of tags
+func DeleteSectionIfMatch(id int64, tags []string) error {
+	return writeIfMatch("sections", tags, sectionVersionTx(id), func(tx *sql.Tx) error {
+		return DeleteSectionTx(tx, id)
+	})
+}
+
+// ReplaceItemIfMatch is ReplaceItem if the item's version is one of tags
+func ReplaceItemIfMatch(id int64, name, description string, quantity float64, unit string, completed, uncertain bool, recurDays int, tags []string) (*Item, error) {
+	err := writeIfMatch("items", tags, itemVersionTx(id), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE items SET name = ?, description = ?, quantity = ?, unit = ?, completed = ?, uncertain = ?,
+				recurs_at = `+recursAt(strconv.FormatBool(completed), strconv.Itoa(recurDays))+`, recur_days = ?, updated_at = strftime('%s', 'now')
+			WHERE id = ?
+		`, name, description, quantity, unit, completed, uncertain, recurDays, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetItemByID(id)
+}
+
+// DeleteItemIfMatch deletes an item if its version is one of tags
+func DeleteItemIfMatch(id int64, tags []string) error {
+	return writeIfMatch("items", tags, itemVersionTx(id), func(tx *sql.Tx) error {
+		return DeleteItemTx(tx, id)
+	})
+}
+
+// UpdateTemplateIfMatch sets a template's name and description if its
+// version is one of tags
+func UpdateTemplateIfMatch(id int64, name, description string, tags []string) (*Template, error) {
+	err := writeIfMatch("templates", tags, templateVersionTx(id), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE templates SET name = ?, description = ?, updated_at = strftime('%s', 'now') WHERE id = ?
+		`, name, description, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetTemplateByID(id)
+}
+
+// DeleteTemplateIfMatch deletes a template if its version is one of tags
+func DeleteTemplateIfMatch(id int64, tags []string) error {
+	return writeIfMatch("templates", tags, templateVersionTx(id), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM templates WHERE id = ?`, id)
+		return err
+	})
+}
+
+// The versions of rows read within a conditional write
+
+func listVersionTx(id int64) func(*sql.Tx) (string, error) {
+	return func(tx *sql.Tx) (string, error) {
+		var l List
+		err := tx.QueryRow(`
+			SELECT id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, COALESCE(updated_at, 0)
+			FROM lists WHERE id = ?
+		`, id).Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.UpdatedAt)
+		if err != nil {
+			return "", err
+		}
+		return l.ETag(), nil
+	}
+}
+
+func sectionVersionTx(id int64) func(*sql.Tx) (string, error) {
+	return func(tx *sql.Tx) (string, error) {
+		section, err := GetSectionTx(tx, id)
+		if err != nil {
+			return "", err
+		}
+		return section.ETag(), nil
+	}
+}
+
+func itemVersionTx(id int64) func(*sql.Tx) (string, error) {
+	return func(tx *sql.Tx) (string, error) {
+		item, err := GetItemTx(tx, id)
+		if err != nil {
+			return "", err
+		}
+		return item.ETag(), nil
+	}
+}
+
+func templateVersionTx(id int64) func(*sql.Tx) (string, error) {
+	return func(tx *sql.Tx) (string, error) {
+		var t Template
+		err := tx.QueryRow(`
+			SELECT id, name, description, sort_order, COALESCE(updated_at, 0) FROM templates WHERE id = ?
+		`, id).Scan(&t.ID, &t.Name, &t.Description, &t.SortOrder, &t.UpdatedAt)
+		if err != nil {
+			return "", err
+		}
+		return t.ETag(), nil
+	}
+}
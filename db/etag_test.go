@@ -0,0 +1,91 @@
+package db
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// setupTestDB points the package at a fresh database for one test
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	Init()
+	t.Cleanup(Close)
+}
+
+func TestReplaceItemIfMatchConcurrent(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := CreateItem(section.ID, "Milk", "", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := []string{item.ETag()}
+
+	// Every writer read the same version; only the first write may land
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := ReplaceItemIfMatch(item.ID, "Milk", "", float64(i+1), "l", false, false, 0, tag)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	applied := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			applied++
+		case ErrPreconditionFailed:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if applied != 1 {
+		t.Errorf("%d writes applied, want 1", applied)
+	}
+}
+
+func TestDeleteItemIfMatch(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := CreateItem(section.ID, "Milk", "", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := item.ETag()
+	if _, err := ToggleItemUncertain(item.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteItemIfMatch(item.ID, []string{stale}); err != ErrPreconditionFailed {
+		t.Fatalf("stale ETag: %v, want ErrPreconditionFailed", err)
+	}
+	if err := DeleteItemIfMatch(item.ID, []string{"*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteItemIfMatch(item.ID, []string{"*"}); err != ErrPreconditionFailed {
+		t.Errorf("deleted row: %v, want ErrPreconditionFailed", err)
+	}
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultHouseholdID is the household created by the migration. It owns all
+// pre-existing data and is used when there is no user account to resolve
+// (shared password, DISABLE_AUTH, the API token).
+const DefaultHouseholdID int64 = 1
+
+// Household groups users sharing lists, templates and item history
+type Household struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ==================== HOUSEHOLDS ====================
+
+// GetAllHouseholds returns all households ordered by creation
+func GetAllHouseholds() ([]Household, error) {
+	rows, err := DB.Query(`SELECT id, name, created_at FROM households ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var households []Household
+	for rows.Next() {
+		var h Household
+		if err := rows.Scan(&h.ID, &h.Name, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		households = append(households, h)
+	}
+	return households, nil
+}
+
+// GetHouseholdByID returns a single household by ID
+func GetHouseholdByID(id int64) (*Household, error) {
+	var h Household
+	err := DB.QueryRow(`SELECT id, name, created_at FROM households WHERE id = ?`, id).Scan(&h.ID, &h.Name, &h.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// CreateHousehold creates a new, empty household
+func CreateHousehold(name string) (*Household, error) {
+	result, err := DB.Exec(`INSERT INTO households (name) VALUES (?)`, name)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return GetHouseholdByID(id)
+}
+
+// ==================== OWNERSHIP ====================
+// Each lookup returns sql.ErrNoRows when the row doesn't exist.
+
+// ListHouseholdID returns the household owning a list
+func ListHouseholdID(listID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT COALESCE(household_id, 0) FROM lists WHERE id = ?`, listID).Scan(&id)
+	return id, err
+}
+
+// SectionHouseholdID returns the household owning a section's list
+func SectionHouseholdID(sectionID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		SELECT COALESCE(l.household_id, 0)
+		FROM sections s JOIN lists l ON l.id = s.list_id
+		WHERE s.id = ?
+	`, sectionID).Scan(&id)
+	return id, err
+}
+
+// ItemHouseholdID returns the household owning an item's list
+func ItemHouseholdID(itemID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		SELECT COALESCE(l.household_id, 0)
+		FROM items i
+		JOIN sections s ON s.id = i.section_id
+		JOIN lists l ON l.id = s.list_id
+		WHERE i.id = ?
+	`, itemID).Scan(&id)
+	return id, err
+}
+
+// TemplateHouseholdID returns the household owning a template
+func TemplateHouseholdID(templateID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT COALESCE(household_id, 0) FROM templates WHERE id = ?`, templateID).Scan(&id)
+	return id, err
+}
+
+// TemplateItemTemplateID returns the template a template item belongs to
+func TemplateItemTemplateID(templateItemID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT template_id FROM template_items WHERE id = ?`, templateItemID).Scan(&id)
+	return id, err
+}
+
+// TemplateItemHouseholdID returns the household owning a template item's template
+func TemplateItemHouseholdID(templateItemID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		SELECT COALESCE(t.household_id, 0)
+		FROM template_items ti JOIN templates t ON t.id = ti.template_id
+		WHERE ti.id = ?
+	`, templateItemID).Scan(&id)
+	return id, err
+}
+
+// WebhookHouseholdID returns the household owning a webhook
+func WebhookHouseholdID(webhookID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT household_id FROM webhooks WHERE id = ?`, webhookID).Scan(&id)
+	return id, err
+}
+
+// TemplateScheduleHouseholdID returns the household owning a template schedule
+func TemplateScheduleHouseholdID(scheduleID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT household_id FROM template_schedules WHERE id = ?`, scheduleID).Scan(&id)
+	return id, err
+}
+
+// SectionListID returns the list a section belongs to
+func SectionListID(sectionID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT list_id FROM sections WHERE id = ?`, sectionID).Scan(&id)
+	return id, err
+}
+
+// ItemListID returns the list an item belongs to
+func ItemListID(itemID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+		SELECT s.list_id FROM items i JOIN sections s ON s.id = i.section_id WHERE i.id = ?
+	`, itemID).Scan(&id)
+	return id, err
+}
+
+// SectionHouseholdIDTx is SectionHouseholdID within a transaction, so rows
+// created earlier in the transaction are found
+func SectionHouseholdIDTx(tx *sql.Tx, sectionID int64) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`
+		SELECT COALESCE(l.household_id, 0)
+		FROM sections s JOIN lists l ON l.id = s.list_id
+		WHERE s.id = ?
+	`, sectionID).Scan(&id)
+	return id, err
+}
+
+// SectionListIDTx is SectionListID within a transaction
+func SectionListIDTx(tx *sql.Tx, sectionID int64) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT list_id FROM sections WHERE id = ?`, sectionID).Scan(&id)
+	return id, err
+}
+
+// ItemHouseholdIDTx is ItemHouseholdID within a transaction
+func ItemHouseholdIDTx(tx *sql.Tx, itemID int64) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`
+		SELECT COALESCE(l.household_id, 0)
+		FROM items i
+		JOIN sections s ON s.id = i.section_id
+		JOIN lists l ON l.id = s.list_id
+		WHERE i.id = ?
+	`, itemID).Scan(&id)
+	return id, err
+}
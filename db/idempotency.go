@@ -0,0 +1,78 @@
+package db
+
+import "time"
+
+// IdempotencyReservationTimeout is how long a key stays claimed by a request
+// that hasn't stored its response. A claim left behind by a crashed request
+// is free to reclaim after it.
+const IdempotencyReservationTimeout = time.Minute
+
+// IdempotentResponse is the stored outcome of the first request made with an
+// Idempotency-Key
+type IdempotentResponse struct {
+	Fingerprint string // method, URL and body hash of the first request
+	Status      int    // 0 while the first request is still being handled
+	ContentType string
+	Body        []byte
+}
+
+// ReserveIdempotencyKey claims key for a request of the caller (tokenID is 0
+// for the legacy API_TOKEN). It returns nil when the claim is new, or the
+// response stored for the key if it was used within window. Expired keys and
+// stale claims are dropped along the way.
+func ReserveIdempotencyKey(householdID, tokenID int64, key, fingerprint string, window time.Duration) (*IdempotentResponse, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cutoff := time.Now().Add(-window).Unix()
+	if _, err := tx.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, cutoff); err != nil {
+		return nil, err
+	}
+	stale := time.Now().Add(-IdempotencyReservationTimeout).Unix()
+	if _, err := tx.Exec(`DELETE FROM idempotency_keys WHERE status = 0 AND created_at < ?`, stale); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO idempotency_keys (household_id, token_id, key, fingerprint)
+		VALUES (?, ?, ?, ?)
+	`, householdID, tokenID, key, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return nil, tx.Commit()
+	}
+
+	var stored IdempotentResponse
+	var body []byte
+	err = tx.QueryRow(`
+		SELECT fingerprint, status, content_type, body FROM idempotency_keys
+		WHERE household_id = ? AND token_id = ? AND key = ?
+	`, householdID, tokenID, key).Scan(&stored.Fingerprint, &stored.Status, &stored.ContentType, &body)
+	if err != nil {
+		return nil, err
+	}
+	stored.Body = body
+	return &stored, tx.Commit()
+}
+
+// SaveIdempotentResponse stores the response to a reserved key for replay
+func SaveIdempotentResponse(householdID, tokenID int64, key string, status int, contentType string, body []byte) error {
+	_, err := DB.Exec(`
+		UPDATE idempotency_keys SET status = ?, content_type = ?, body = ?
+		WHERE household_id = ? AND token_id = ? AND key = ?
+	`, status, contentType, body, householdID, tokenID, key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key so the request can be retried
+func ReleaseIdempotencyKey(householdID, tokenID int64, key string) error {
+	_, err := DB.Exec(`
+		DELETE FROM idempotency_keys WHERE household_id = ? AND token_id = ? AND key = ?
+	`, householdID, tokenID, key)
+	return err
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveIdempotencyKeyReclaimsStaleClaim(t *testing.T) {
+	setupTestDB(t)
+
+	stored, err := ReserveIdempotencyKey(DefaultHouseholdID, 0, "key-1", "fp", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("first claim = %v, %v; want a new claim", stored, err)
+	}
+
+	// A repeat while the first request is being handled finds the claim
+	stored, err = ReserveIdempotencyKey(DefaultHouseholdID, 0, "key-1", "fp", time.Hour)
+	if err != nil || stored == nil || stored.Status != 0 {
+		t.Fatalf("repeat = %+v, %v; want the in-progress claim", stored, err)
+	}
+
+	// The first request died without storing a response
+	past := time.Now().Add(-IdempotencyReservationTimeout - time.Second).Unix()
+	if _, err := DB.Exec(`UPDATE idempotency_keys SET created_at = ?`, past); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = ReserveIdempotencyKey(DefaultHouseholdID, 0, "key-1", "fp", time.Hour)
+	if err != nil || stored != nil {
+		t.Fatalf("after timeout = %+v, %v; want the claim reclaimed", stored, err)
+	}
+
+	// A stored response outlives the timeout until the window ends
+	if err := SaveIdempotentResponse(DefaultHouseholdID, 0, "key-1", 201, "application/json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DB.Exec(`UPDATE idempotency_keys SET created_at = ?`, past); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = ReserveIdempotencyKey(DefaultHouseholdID, 0, "key-1", "fp", time.Hour)
+	if err != nil || stored == nil || stored.Status != 201 {
+		t.Fatalf("replay = %+v, %v; want the stored 201", stored, err)
+	}
+}
@@ -0,0 +1,71 @@
+package db
+
+import "testing"
+
+func TestAddItemMergesMatchingUnits(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adds := []struct {
+		quantity float64
+		unit     string
+		merged   bool
+	}{
+		{2, "l", false},
+		{1, "pcs", false}, // different unit, a separate item
+		{1, "L", true},    // into the litres
+		{2, "pcs", true},  // into the pieces
+	}
+	for _, add := range adds {
+		_, merged, err := AddItem(section.ID, "Milk", "", add.quantity, add.unit, 0)
+		if err != nil {
+			t.Fatalf("adding %v %s: %v", add.quantity, add.unit, err)
+		}
+		if merged != add.merged {
+			t.Errorf("adding %v %s: merged = %v, want %v", add.quantity, add.unit, merged, add.merged)
+		}
+	}
+
+	sections, err := GetSectionsByList(list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, item := range sections[0].Items {
+		got[item.Unit] = item.Quantity
+	}
+	if len(got) != 2 || got["l"] != 3 || got["pcs"] != 3 {
+		t.Errorf("milk on the list = %v, want 3 l and 3 pcs", got)
+	}
+}
+
+func TestAddItemRejectMode(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetListDuplicateMode(list.ID, DuplicateModeReject); err != nil {
+		t.Fatal(err)
+	}
+	section, err := CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _, err := AddItem(section.ID, "Milk", "", 2, "l", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, _, err := AddItem(section.ID, "milk", "", 1, "pcs", 0)
+	if err != ErrDuplicateItem || existing == nil || existing.ID != first.ID {
+		t.Errorf("second add: err = %v, want ErrDuplicateItem with the first item", err)
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PageError is a PageRequest with an unknown sort or a bad cursor
+type PageError struct {
+	Message string
+}
+
+func (e *PageError) Error() string {
+	return e.Message
+}
+
+// PageRequest asks for one page of a collection
+type PageRequest struct {
+	Sort   string // a sort key, prefixed with "-" for descending; empty for the default
+	Limit  int    // rows per page, 0 for all of them
+	Cursor string // NextCursor of the previous page
+}
+
+// sortKeys maps the sort keys a collection accepts to the expressions it is
+// ordered by; the row id always breaks ties
+type sortKeys map[string][]string
+
+func (k sortKeys) names() string {
+	names := make([]string, 0, len(k))
+	for name := range k {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// pageCursor is the position after the last row of a page: the sort it was
+// taken with and that row's sort values
+type pageCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeCursor(sortKey string, values []interface{}) string {
+	data, _ := json.Marshal(pageCursor{Sort: sortKey, Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor, sortKey string, n int) ([]interface{}, error) {
+	invalid := &PageError{"Invalid cursor"}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var c pageCursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, invalid
+	}
+	if c.Sort != sortKey {
+		return nil, &PageError{fmt.Sprintf("Cursor was returned for sort %q", c.Sort)}
+	}
+	if len(c.Values) != n {
+		return nil, invalid
+	}
+
+	for i, v := range c.Values {
+		if num, ok := v.(json.Number); ok {
+			if c.Values[i], err = num.Int64(); err != nil {
+				if c.Values[i], err = num.Float64(); err != nil {
+					return nil, invalid
+				}
+			}
+		}
+	}
+	return c.Values, nil
+}
+
+// queryPage selects columns plus the sort values from from (which must end
+// in a WHERE clause) for one page, ordered by page.Sort or else defaultSort.
+// scan reads a row into the caller's result, scanning the sort values into
+// sortDest after its own columns. It returns the cursor of the next page,
+// empty on the last one.
+func queryPage(columns, from string, args []interface{}, idExpr string, keys sortKeys, defaultSort string, page PageRequest,
+	scan func(rows *sql.Rows, sortDest []interface{}) error) (string, error) {
+	sortKey := page.Sort
+	if sortKey == "" {
+		sortKey = defaultSort
+	}
+	exprs, ok := keys[strings.TrimPrefix(sortKey, "-")]
+	if !ok {
+		return "", &PageError{fmt.Sprintf("Unknown sort %q (use %s, prefixed with - for descending)", sortKey, keys.names())}
+	}
+	exprs = append(append([]string{}, exprs...), idExpr)
+
+	op, dir := ">", " ASC"
+	if strings.HasPrefix(sortKey, "-") {
+		op, dir = "<", " DESC"
+	}
+
+	query := "SELECT " + columns + ", " + strings.Join(exprs, ", ") + " " + from
+	args = append([]interface{}{}, args...)
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, sortKey, len(exprs))
+		if err != nil {
+			return "", err
+		}
+		query += " AND (" + strings.Join(exprs, ", ") + ") " + op + " (?" + strings.Repeat(", ?", len(exprs)-1) + ")"
+		args = append(args, values...)
+	}
+	query += " ORDER BY " + strings.Join(exprs, dir+", ") + dir
+	if page.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, page.Limit+1) // one more tells whether there is a next page
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var last []interface{}
+	for n := 0; rows.Next(); n++ {
+		if page.Limit > 0 && n == page.Limit {
+			return encodeCursor(sortKey, last), nil
+		}
+		values := make([]interface{}, len(exprs))
+		dest := make([]interface{}, len(exprs))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := scan(rows, dest); err != nil {
+			return "", err
+		}
+		last = values
+	}
+	return "", rows.Err()
+}
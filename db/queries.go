@@ -2,8 +2,11 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,29 +28,49 @@ type Item struct {
 	SectionID   int64     `json:"section_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Quantity    float64   `json:"quantity"`
+	Unit        string    `json:"unit"`
 	Completed   bool      `json:"completed"`
 	Uncertain   bool      `json:"uncertain"`
 	SortOrder   int       `json:"sort_order"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   int64     `json:"updated_at"`
+	RecurDays   int       `json:"recur_days,omitempty"` // back on the list this many days after being bought
+	RecursAt    int64     `json:"recurs_at,omitempty"`  // unix seconds; set while a recurring item is completed
 }
 
 // Session represents a user session
 type Session struct {
 	ID        string
+	UserID    int64 // 0 for sessions opened with the shared app password
 	ExpiresAt int64
 }
 
 // List represents a shopping list
 type List struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Icon      string    `json:"icon"`
-	SortOrder int       `json:"sort_order"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt int64     `json:"updated_at"`
-	Stats     Stats     `json:"stats,omitempty"`
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Icon          string    `json:"icon"`
+	DuplicateMode string    `json:"duplicate_mode"`
+	SortOrder     int       `json:"sort_order"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     int64     `json:"updated_at"`
+	Stats         Stats     `json:"stats,omitempty"`
+}
+
+// Duplicate handling modes for lists
+const (
+	DuplicateModeMerge  = "merge"  // bump the quantity of the existing open item; a separate item when the units can't be combined
+	DuplicateModeReject = "reject" // refuse to add a second open item with the same name
+)
+
+// ErrDuplicateItem is returned when an open item with the same name is already on the list
+var ErrDuplicateItem = errors.New("item already on list")
+
+// IsValidDuplicateMode reports whether mode is a known duplicate handling mode
+func IsValidDuplicateMode(mode string) bool {
+	return mode == DuplicateModeMerge || mode == DuplicateModeReject
 }
 
 // Template represents a reusable template
@@ -68,19 +91,44 @@ type TemplateItem struct {
 	SectionName string    `json:"section_name"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Quantity    float64   `json:"quantity"`
+	Unit        string    `json:"unit"`
 	SortOrder   int       `json:"sort_order"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// QuantityLabel returns the item's quantity formatted for display
+func (i Item) QuantityLabel() string {
+	return FormatQuantity(i.Quantity, i.Unit)
+}
+
+// QuantityLabel returns the template item's quantity formatted for display
+func (ti TemplateItem) QuantityLabel() string {
+	return FormatQuantity(ti.Quantity, ti.Unit)
+}
+
+// FormatQuantity formats a quantity and unit, e.g. "500 g", "3x" or "" when unset
+func FormatQuantity(quantity float64, unit string) string {
+	if quantity <= 0 {
+		return unit
+	}
+	q := strconv.FormatFloat(quantity, 'f', -1, 64)
+	if unit == "" {
+		return q + "x"
+	}
+	return q + " " + unit
+}
+
 // ==================== LISTS ====================
 
-// GetAllLists returns all shopping lists with their stats
-func GetAllLists() ([]List, error) {
+// GetAllLists returns all shopping lists of a household with their stats
+func GetAllLists(householdID int64) ([]List, error) {
 	rows, err := DB.Query(`
-		SELECT id, name, COALESCE(icon, '🛒'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
+		SELECT id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
 		FROM lists
+		WHERE household_id = ?
 		ORDER BY sort_order ASC
-	`)
+	`, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +137,7 @@ func GetAllLists() ([]List, error) {
 	var lists []List
 	for rows.Next() {
 		var l List
-		err := rows.Scan(&l.ID, &l.Name, &l.Icon, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+		err := rows.Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -99,13 +147,54 @@ func GetAllLists() ([]List, error) {
 	return lists, nil
 }
 
+// listSorts are the sort keys of FindLists
+var listSorts = sortKeys{
+	"sort_order": {"sort_order"},
+	"name":       {"name COLLATE NOCASE"},
+	"updated_at": {"COALESCE(updated_at, 0)"},
+	"created_at": {"id"}, // ids are assigned in creation order
+}
+
+// FindLists returns a page of a household's lists with their stats. listID
+// limits it to one list and updatedSince (unix seconds) to lists changed
+// since then; 0 doesn't filter. It also returns the next page's cursor.
+func FindLists(householdID, listID, updatedSince int64, page PageRequest) ([]List, string, error) {
+	from := `FROM lists WHERE household_id = ?`
+	args := []interface{}{householdID}
+	if listID != 0 {
+		from += ` AND id = ?`
+		args = append(args, listID)
+	}
+	if updatedSince != 0 {
+		from += ` AND COALESCE(updated_at, 0) >= ?`
+		args = append(args, updatedSince)
+	}
+
+	lists := []List{}
+	next, err := queryPage(`id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, created_at, COALESCE(updated_at, 0)`,
+		from, args, "id", listSorts, "sort_order", page, func(rows *sql.Rows, sortDest []interface{}) error {
+			var l List
+			err := rows.Scan(append([]interface{}{&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt}, sortDest...)...)
+			if err != nil {
+				return err
+			}
+			l.Stats = GetListStats(l.ID)
+			lists = append(lists, l)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return lists, next, nil
+}
+
 // GetListByID returns a single list by ID
 func GetListByID(id int64) (*List, error) {
 	var l List
 	err := DB.QueryRow(`
-		SELECT id, name, COALESCE(icon, '🛒'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
+		SELECT id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
 		FROM lists WHERE id = ?
-	`, id).Scan(&l.ID, &l.Name, &l.Icon, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+	`, id).Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -113,14 +202,14 @@ func GetListByID(id int64) (*List, error) {
 	return &l, nil
 }
 
-// GetActiveList returns the currently active list
-func GetActiveList() (*List, error) {
+// GetActiveList returns the household's currently active list
+func GetActiveList(householdID int64) (*List, error) {
 	var l List
 	err := DB.QueryRow(`
-		SELECT id, name, COALESCE(icon, '🛒'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
-		FROM lists WHERE is_active = TRUE
+		SELECT id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
+		FROM lists WHERE household_id = ? AND is_active = TRUE
 		LIMIT 1
-	`).Scan(&l.ID, &l.Name, &l.Icon, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+	`, householdID).Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -128,18 +217,18 @@ func GetActiveList() (*List, error) {
 	return &l, nil
 }
 
-// CreateList creates a new shopping list
-func CreateList(name, icon string) (*List, error) {
+// CreateList creates a new shopping list in a household
+func CreateList(householdID int64, name, icon string) (*List, error) {
 	var maxOrder int
-	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM lists").Scan(&maxOrder)
+	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM lists WHERE household_id = ?", householdID).Scan(&maxOrder)
 
 	if icon == "" {
 		icon = "🛒"
 	}
 
 	result, err := DB.Exec(`
-		INSERT INTO lists (name, icon, sort_order, is_active) VALUES (?, ?, ?, FALSE)
-	`, name, icon, maxOrder+1)
+		INSERT INTO lists (household_id, name, icon, sort_order, is_active) VALUES (?, ?, ?, ?, FALSE)
+	`, householdID, name, icon, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
@@ -164,13 +253,22 @@ func UpdateList(id int64, name, icon string) (*List, error) {
 	return GetListByID(id)
 }
 
+// SetListDuplicateMode sets how a list handles adding an item that is already on it
+func SetListDuplicateMode(id int64, mode string) (*List, error) {
+	_, err := DB.Exec(`UPDATE lists SET duplicate_mode = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, mode, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetListByID(id)
+}
+
 // DeleteList deletes a list and all its sections/items
 func DeleteList(id int64) error {
 	_, err := DB.Exec(`DELETE FROM lists WHERE id = ?`, id)
 	return err
 }
 
-// SetActiveList sets a list as the active one
+// SetActiveList sets a list as the active one of its household
 func SetActiveList(id int64) error {
 	tx, err := DB.Begin()
 	if err != nil {
@@ -178,8 +276,8 @@ func SetActiveList(id int64) error {
 	}
 	defer tx.Rollback()
 
-	// Deactivate all lists
-	_, err = tx.Exec("UPDATE lists SET is_active = FALSE")
+	// Deactivate all lists of the household
+	_, err = tx.Exec("UPDATE lists SET is_active = FALSE WHERE household_id = (SELECT household_id FROM lists WHERE id = ?)", id)
 	if err != nil {
 		return err
 	}
@@ -202,7 +300,8 @@ func MoveListUp(id int64) error {
 	defer tx.Rollback()
 
 	var currentOrder int
-	err = tx.QueryRow("SELECT sort_order FROM lists WHERE id = ?", id).Scan(&currentOrder)
+	var householdID int64
+	err = tx.QueryRow("SELECT sort_order, household_id FROM lists WHERE id = ?", id).Scan(&currentOrder, &householdID)
 	if err != nil {
 		return err
 	}
@@ -211,7 +310,7 @@ func MoveListUp(id int64) error {
 		return nil
 	}
 
-	_, err = tx.Exec(`UPDATE lists SET sort_order = sort_order + 1 WHERE sort_order = ?`, currentOrder-1)
+	_, err = tx.Exec(`UPDATE lists SET sort_order = sort_order + 1 WHERE sort_order = ? AND household_id = ?`, currentOrder-1, householdID)
 	if err != nil {
 		return err
 	}
@@ -233,11 +332,12 @@ func MoveListDown(id int64) error {
 	defer tx.Rollback()
 
 	var currentOrder, maxOrder int
-	err = tx.QueryRow("SELECT sort_order FROM lists WHERE id = ?", id).Scan(&currentOrder)
+	var householdID int64
+	err = tx.QueryRow("SELECT sort_order, household_id FROM lists WHERE id = ?", id).Scan(&currentOrder, &householdID)
 	if err != nil {
 		return err
 	}
-	err = tx.QueryRow("SELECT MAX(sort_order) FROM lists").Scan(&maxOrder)
+	err = tx.QueryRow("SELECT MAX(sort_order) FROM lists WHERE household_id = ?", householdID).Scan(&maxOrder)
 	if err != nil {
 		return err
 	}
@@ -246,7 +346,7 @@ func MoveListDown(id int64) error {
 		return nil
 	}
 
-	_, err = tx.Exec(`UPDATE lists SET sort_order = sort_order - 1 WHERE sort_order = ?`, currentOrder+1)
+	_, err = tx.Exec(`UPDATE lists SET sort_order = sort_order - 1 WHERE sort_order = ? AND household_id = ?`, currentOrder+1, householdID)
 	if err != nil {
 		return err
 	}
@@ -280,11 +380,11 @@ func GetListStats(listID int64) Stats {
 
 // ==================== SECTIONS ====================
 
-func GetAllSections() ([]Section, error) {
-	activeList, err := GetActiveList()
+func GetAllSections(householdID int64) ([]Section, error) {
+	activeList, err := GetActiveList(householdID)
 	if err != nil {
-		// Fallback: return all sections if no active list (shouldn't happen)
-		return getAllSectionsGlobal()
+		// Fallback: return all sections of the household if no active list (shouldn't happen)
+		return getAllSectionsByHousehold(householdID)
 	}
 	return GetSectionsByList(activeList.ID)
 }
@@ -318,13 +418,53 @@ func GetSectionsByList(listID int64) ([]Section, error) {
 	return sections, nil
 }
 
-// getAllSectionsGlobal returns all sections (fallback, used during migration)
-func getAllSectionsGlobal() ([]Section, error) {
+// sectionSorts are the sort keys of FindSections
+var sectionSorts = sortKeys{
+	"sort_order": {"sort_order"},
+	"name":       {"name COLLATE NOCASE"},
+	"updated_at": {"COALESCE(updated_at, 0)"},
+	"created_at": {"id"},
+}
+
+// FindSections returns a page of a list's sections with their items,
+// optionally only those changed since updatedSince (unix seconds), and the
+// next page's cursor
+func FindSections(listID, updatedSince int64, page PageRequest) ([]Section, string, error) {
+	from := `FROM sections WHERE list_id = ?`
+	args := []interface{}{listID}
+	if updatedSince != 0 {
+		from += ` AND COALESCE(updated_at, 0) >= ?`
+		args = append(args, updatedSince)
+	}
+
+	sections := []Section{}
+	next, err := queryPage(`id, list_id, name, sort_order, created_at, COALESCE(updated_at, 0)`,
+		from, args, "id", sectionSorts, "sort_order", page, func(rows *sql.Rows, sortDest []interface{}) error {
+			var s Section
+			err := rows.Scan(append([]interface{}{&s.ID, &s.ListID, &s.Name, &s.SortOrder, &s.CreatedAt, &s.UpdatedAt}, sortDest...)...)
+			if err != nil {
+				return err
+			}
+			if s.Items, err = GetItemsBySection(s.ID); err != nil {
+				return err
+			}
+			sections = append(sections, s)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return sections, next, nil
+}
+
+// getAllSectionsByHousehold returns all sections of a household's lists (fallback)
+func getAllSectionsByHousehold(householdID int64) ([]Section, error) {
 	rows, err := DB.Query(`
 		SELECT id, list_id, name, sort_order, created_at, COALESCE(updated_at, 0)
 		FROM sections
+		WHERE list_id IN (SELECT id FROM lists WHERE household_id = ?)
 		ORDER BY sort_order ASC
-	`)
+	`, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -362,8 +502,8 @@ func GetSectionByID(id int64) (*Section, error) {
 	return &s, nil
 }
 
-func CreateSection(name string) (*Section, error) {
-	activeList, err := GetActiveList()
+func CreateSection(householdID int64, name string) (*Section, error) {
+	activeList, err := GetActiveList(householdID)
 	if err != nil {
 		return nil, fmt.Errorf("no active list found")
 	}
@@ -484,7 +624,7 @@ func MoveSectionDown(id int64) error {
 
 func GetItemsBySection(sectionID int64) ([]Item, error) {
 	rows, err := DB.Query(`
-		SELECT id, section_id, name, description, completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0)
+		SELECT id, section_id, name, description, COALESCE(quantity, 0), COALESCE(unit, ''), completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0), COALESCE(recur_days, 0), COALESCE(recurs_at, 0)
 		FROM items
 		WHERE section_id = ?
 		ORDER BY completed ASC, sort_order ASC
@@ -497,7 +637,7 @@ func GetItemsBySection(sectionID int64) ([]Item, error) {
 	var items []Item
 	for rows.Next() {
 		var i Item
-		err := rows.Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt)
+		err := rows.Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt)
 		if err != nil {
 			return nil, err
 		}
@@ -509,23 +649,85 @@ func GetItemsBySection(sectionID int64) ([]Item, error) {
 func GetItemByID(id int64) (*Item, error) {
 	var i Item
 	err := DB.QueryRow(`
-		SELECT id, section_id, name, description, completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0)
+		SELECT id, section_id, name, description, COALESCE(quantity, 0), COALESCE(unit, ''), completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0), COALESCE(recur_days, 0), COALESCE(recurs_at, 0)
 		FROM items WHERE id = ?
-	`, id).Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt)
+	`, id).Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt)
 	if err != nil {
 		return nil, err
 	}
 	return &i, nil
 }
 
-func CreateItem(sectionID int64, name, description string) (*Item, error) {
+// ItemFilter narrows FindItems; zero fields don't filter
+type ItemFilter struct {
+	HouseholdID  int64
+	ListID       int64
+	SectionID    int64
+	Completed    *bool
+	Uncertain    *bool
+	UpdatedSince int64 // unix seconds
+}
+
+// itemSorts are the sort keys of FindItems; position is the order items are
+// shown in, open before completed within each section
+var itemSorts = sortKeys{
+	"position":   {"l.sort_order", "s.sort_order", "i.completed", "i.sort_order"},
+	"name":       {"i.name COLLATE NOCASE"},
+	"updated_at": {"COALESCE(i.updated_at, 0)"},
+	"created_at": {"i.id"},
+}
+
+// FindItems returns a page of a household's items matching filter, and the
+// next page's cursor
+func FindItems(filter ItemFilter, page PageRequest) ([]Item, string, error) {
+	from := `FROM items i JOIN sections s ON s.id = i.section_id JOIN lists l ON l.id = s.list_id WHERE l.household_id = ?`
+	args := []interface{}{filter.HouseholdID}
+	if filter.ListID != 0 {
+		from += ` AND l.id = ?`
+		args = append(args, filter.ListID)
+	}
+	if filter.SectionID != 0 {
+		from += ` AND s.id = ?`
+		args = append(args, filter.SectionID)
+	}
+	if filter.Completed != nil {
+		from += ` AND i.completed = ?`
+		args = append(args, *filter.Completed)
+	}
+	if filter.Uncertain != nil {
+		from += ` AND i.uncertain = ?`
+		args = append(args, *filter.Uncertain)
+	}
+	if filter.UpdatedSince != 0 {
+		from += ` AND COALESCE(i.updated_at, 0) >= ?`
+		args = append(args, filter.UpdatedSince)
+	}
+
+	items := []Item{}
+	next, err := queryPage(`i.id, i.section_id, i.name, i.description, COALESCE(i.quantity, 0), COALESCE(i.unit, ''), i.completed, i.uncertain, i.sort_order, i.created_at, COALESCE(i.updated_at, 0), COALESCE(i.recur_days, 0), COALESCE(i.recurs_at, 0)`,
+		from, args, "i.id", itemSorts, "position", page, func(rows *sql.Rows, sortDest []interface{}) error {
+			var i Item
+			err := rows.Scan(append([]interface{}{&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt}, sortDest...)...)
+			if err != nil {
+				return err
+			}
+			items = append(items, i)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+func CreateItem(sectionID int64, name, description string, quantity float64, unit string) (*Item, error) {
 	// Get max sort_order for this section
 	var maxOrder int
 	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM items WHERE section_id = ?", sectionID).Scan(&maxOrder)
 
 	result, err := DB.Exec(`
-		INSERT INTO items (section_id, name, description, sort_order) VALUES (?, ?, ?, ?)
-	`, sectionID, name, description, maxOrder+1)
+		INSERT INTO items (section_id, name, description, quantity, unit, sort_order) VALUES (?, ?, ?, ?, ?, ?)
+	`, sectionID, name, description, quantity, unit, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
@@ -534,10 +736,124 @@ func CreateItem(sectionID int64, name, description string) (*Item, error) {
 	return GetItemByID(id)
 }
 
-func UpdateItem(id int64, name, description string) (*Item, error) {
+// AddItem creates an item, or merges it into an open item with the same name on
+// the section's list according to the list's duplicate mode. merged reports
+// whether an existing item was updated instead. When the list rejects
+// duplicates, the existing item is returned together with ErrDuplicateItem.
+// Quantities whose units can't be combined ("2 l" and "1 pcs") are added as a
+// separate item even when the list merges. recurDays only
+// applies to a new item; a merge keeps the existing item's recurrence.
+func AddItem(sectionID int64, name, description string, quantity float64, unit string, recurDays int) (item *Item, merged bool, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	item, merged, err = AddItemTx(tx, sectionID, name, description, quantity, unit, GetMaxItemOrderTx(tx, sectionID)+1, recurDays)
+	if err != nil {
+		return item, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return item, merged, nil
+}
+
+// findOpenDuplicateTx returns the uncompleted item with the same (case-insensitive)
+// name on the section's list, if any, along with the list's duplicate mode
+func findOpenDuplicateTx(tx *sql.Tx, sectionID int64, name string) (*Item, string, error) {
+	items, mode, err := findOpenDuplicatesTx(tx, sectionID, name)
+	if err != nil || len(items) == 0 {
+		return nil, mode, err
+	}
+	return &items[0], mode, nil
+}
+
+// findOpenDuplicatesTx returns every uncompleted item with the same
+// (case-insensitive) name on the section's list, oldest first, along with the
+// list's duplicate mode. A merging list holds several when their units differ.
+func findOpenDuplicatesTx(tx *sql.Tx, sectionID int64, name string) ([]Item, string, error) {
+	mode := DuplicateModeMerge
+	err := tx.QueryRow(`
+		SELECT COALESCE(l.duplicate_mode, 'merge')
+		FROM sections s JOIN lists l ON l.id = s.list_id
+		WHERE s.id = ?
+	`, sectionID).Scan(&mode)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "", err
+	}
+
+	// SQLite's NOCASE only folds ASCII, so compare names in Go
+	rows, err := tx.Query(`
+		SELECT i.id, i.section_id, i.name, i.description, COALESCE(i.quantity, 0), COALESCE(i.unit, ''), i.completed, i.uncertain, i.sort_order, i.created_at, COALESCE(i.updated_at, 0), COALESCE(i.recur_days, 0), COALESCE(i.recurs_at, 0)
+		FROM items i JOIN sections s ON s.id = i.section_id
+		WHERE s.list_id = (SELECT list_id FROM sections WHERE id = ?) AND i.completed = FALSE
+		ORDER BY i.id ASC
+	`, sectionID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var items []Item
+	name = strings.TrimSpace(name)
+	for rows.Next() {
+		var i Item
+		err := rows.Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt)
+		if err != nil {
+			return nil, "", err
+		}
+		if strings.EqualFold(strings.TrimSpace(i.Name), name) {
+			items = append(items, i)
+		}
+	}
+	return items, mode, rows.Err()
+}
+
+// mergeQuantity adds an incoming quantity to an existing one. A missing quantity
+// counts as one piece; quantities in different units can't be combined.
+func mergeQuantity(existingQty float64, existingUnit string, qty float64, unit string) (float64, string, bool) {
+	switch {
+	case strings.EqualFold(existingUnit, unit):
+		if existingQty <= 0 {
+			existingQty = 1
+		}
+		if qty <= 0 {
+			qty = 1
+		}
+		return existingQty + qty, existingUnit, true
+	case existingQty <= 0 && existingUnit == "":
+		// "flour" + "500 g flour" - the new amount is more specific
+		return qty, unit, true
+	case qty <= 0 && unit == "":
+		// "500 g flour" + "flour" - already covered
+		return existingQty, existingUnit, true
+	}
+	return 0, "", false
+}
+
+// UpdateItem sets an item's name, description, quantity and recurrence
+// interval (0 for none)
+func UpdateItem(id int64, name, description string, quantity float64, unit string, recurDays int) (*Item, error) {
+	_, err := DB.Exec(`
+		UPDATE items SET name = ?, description = ?, quantity = ?, unit = ?,
+			recurs_at = `+recursAt("completed", strconv.Itoa(recurDays))+`, recur_days = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, name, description, quantity, unit, recurDays, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemByID(id)
+}
+
+// ReplaceItem sets all of an item's editable fields, including its flags
+func ReplaceItem(id int64, name, description string, quantity float64, unit string, completed, uncertain bool, recurDays int) (*Item, error) {
 	_, err := DB.Exec(`
-		UPDATE items SET name = ?, description = ?, updated_at = strftime('%s', 'now') WHERE id = ?
-	`, name, description, id)
+		UPDATE items SET name = ?, description = ?, quantity = ?, unit = ?, completed = ?, uncertain = ?,
+			recurs_at = `+recursAt(strconv.FormatBool(completed), strconv.Itoa(recurDays))+`, recur_days = ?, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, name, description, quantity, unit, completed, uncertain, recurDays, id)
 	if err != nil {
 		return nil, err
 	}
@@ -549,26 +865,55 @@ func DeleteItem(id int64) error {
 	return err
 }
 
-// DeleteCompletedItems deletes all completed items from the active list
-func DeleteCompletedItems() (int64, error) {
-	activeList, err := GetActiveList()
+// DeleteCompletedItems deletes all completed items from a list and returns
+// their IDs. Recurring ones are kept aside until they are due back.
+func DeleteCompletedItems(listID int64) ([]int64, error) {
+	tx, err := DB.Begin()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	result, err := DB.Exec(`
+	rows, err := tx.Query(`
+		SELECT i.id FROM items i JOIN sections s ON s.id = i.section_id
+		WHERE i.completed = TRUE AND s.list_id = ?
+	`, listID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := saveRecurringItemsTx(tx, listID); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
 		DELETE FROM items WHERE completed = TRUE AND section_id IN (
 			SELECT id FROM sections WHERE list_id = ?
 		)
-	`, activeList.ID)
+	`, listID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
 func ToggleItemCompleted(id int64) (*Item, error) {
-	_, err := DB.Exec(`UPDATE items SET completed = NOT completed, updated_at = strftime('%s', 'now') WHERE id = ?`, id)
+	_, err := DB.Exec(`
+		UPDATE items SET completed = NOT completed, recurs_at = `+recursAt("NOT completed", "COALESCE(recur_days, 0)")+`, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -861,14 +1206,19 @@ func MoveItemDown(id int64) error {
 
 // ==================== SESSIONS ====================
 
-func CreateSession(id string, expiresAt int64) error {
-	_, err := DB.Exec(`INSERT INTO sessions (id, expires_at) VALUES (?, ?)`, id, expiresAt)
+// CreateSession stores a new session; userID 0 means no user account
+func CreateSession(id string, expiresAt int64, userID int64) error {
+	var user interface{}
+	if userID != 0 {
+		user = userID
+	}
+	_, err := DB.Exec(`INSERT INTO sessions (id, expires_at, user_id) VALUES (?, ?, ?)`, id, expiresAt, user)
 	return err
 }
 
 func GetSession(id string) (*Session, error) {
 	var s Session
-	err := DB.QueryRow(`SELECT id, expires_at FROM sessions WHERE id = ?`, id).Scan(&s.ID, &s.ExpiresAt)
+	err := DB.QueryRow(`SELECT id, COALESCE(user_id, 0), expires_at FROM sessions WHERE id = ?`, id).Scan(&s.ID, &s.UserID, &s.ExpiresAt)
 	if err != nil {
 		return nil, err
 	}
@@ -880,6 +1230,12 @@ func DeleteSession(id string) error {
 	return err
 }
 
+// DeleteUserSessions logs a user out everywhere
+func DeleteUserSessions(userID int64) error {
+	_, err := DB.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	return err
+}
+
 func CleanExpiredSessions() error {
 	_, err := DB.Exec(`DELETE FROM sessions WHERE expires_at < ?`, time.Now().Unix())
 	return err
@@ -893,20 +1249,30 @@ type Stats struct {
 	Percentage     int `json:"percentage"`
 }
 
-func GetStats() Stats {
-	activeList, err := GetActiveList()
+func GetStats(householdID int64) Stats {
+	activeList, err := GetActiveList(householdID)
 	if err != nil {
-		// Fallback to global stats
-		return getGlobalStats()
+		// Fallback to household-wide stats
+		return getHouseholdStats(householdID)
 	}
 	return GetListStats(activeList.ID)
 }
 
-// getGlobalStats returns stats for all items (fallback)
-func getGlobalStats() Stats {
+// getHouseholdStats returns stats for all items of a household (fallback)
+func getHouseholdStats(householdID int64) Stats {
 	var stats Stats
-	DB.QueryRow("SELECT COUNT(*) FROM items").Scan(&stats.TotalItems)
-	DB.QueryRow("SELECT COUNT(*) FROM items WHERE completed = TRUE").Scan(&stats.CompletedItems)
+	DB.QueryRow(`
+		SELECT COUNT(*) FROM items i
+		JOIN sections s ON i.section_id = s.id
+		JOIN lists l ON s.list_id = l.id
+		WHERE l.household_id = ?
+	`, householdID).Scan(&stats.TotalItems)
+	DB.QueryRow(`
+		SELECT COUNT(*) FROM items i
+		JOIN sections s ON i.section_id = s.id
+		JOIN lists l ON s.list_id = l.id
+		WHERE l.household_id = ? AND i.completed = TRUE
+	`, householdID).Scan(&stats.CompletedItems)
 	if stats.TotalItems > 0 {
 		stats.Percentage = (stats.CompletedItems * 100) / stats.TotalItems
 	}
@@ -933,7 +1299,8 @@ func GetSectionStats(sectionID int64) SectionStats {
 
 // ==================== BATCH DELETE SECTIONS ====================
 
-func DeleteSections(ids []int64) error {
+// DeleteSections deletes the given sections; IDs outside the household are ignored
+func DeleteSections(householdID int64, ids []int64) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return err
@@ -941,7 +1308,7 @@ func DeleteSections(ids []int64) error {
 	defer tx.Rollback()
 
 	for _, id := range ids {
-		_, err := tx.Exec("DELETE FROM sections WHERE id = ?", id)
+		_, err := tx.Exec("DELETE FROM sections WHERE id = ? AND list_id IN (SELECT id FROM lists WHERE household_id = ?)", id, householdID)
 		if err != nil {
 			return err
 		}
@@ -959,16 +1326,19 @@ type ItemSuggestion struct {
 	UsageCount      int    `json:"usage_count"`
 }
 
-// SaveItemHistory saves or updates item name in history for auto-completion
-func SaveItemHistory(name string, sectionID int64) error {
-	_, err := DB.Exec(`
-		INSERT INTO item_history (name, last_section_id, usage_count, last_used_at)
-		VALUES (?, ?, 1, strftime('%s', 'now'))
-		ON CONFLICT(name COLLATE NOCASE) DO UPDATE SET
-			last_section_id = excluded.last_section_id,
-			usage_count = usage_count + 1,
-			last_used_at = strftime('%s', 'now')
-	`, name, sectionID)
+// saveItemHistorySQL upserts a name into a household's item history
+const saveItemHistorySQL = `
+	INSERT INTO item_history (household_id, name, last_section_id, usage_count, last_used_at)
+	VALUES (?, ?, ?, 1, strftime('%s', 'now'))
+	ON CONFLICT(household_id, name COLLATE NOCASE) DO UPDATE SET
+		last_section_id = excluded.last_section_id,
+		usage_count = usage_count + 1,
+		last_used_at = strftime('%s', 'now')
+`
+
+// SaveItemHistory saves or updates item name in the household's history for auto-completion
+func SaveItemHistory(householdID int64, name string, sectionID int64) error {
+	_, err := DB.Exec(saveItemHistorySQL, householdID, name, sectionID)
 	return err
 }
 
@@ -1056,7 +1426,7 @@ func scoreSuggestion(name, query string) int {
 }
 
 // GetItemSuggestions returns item name suggestions matching the query with fuzzy matching
-func GetItemSuggestions(query string, limit int) ([]ItemSuggestion, error) {
+func GetItemSuggestions(householdID int64, query string, limit int) ([]ItemSuggestion, error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -1066,9 +1436,10 @@ func GetItemSuggestions(query string, limit int) ([]ItemSuggestion, error) {
 		SELECT h.name, COALESCE(h.last_section_id, 0), COALESCE(s.name, ''), h.usage_count
 		FROM item_history h
 		LEFT JOIN sections s ON h.last_section_id = s.id
+		WHERE h.household_id = ?
 		ORDER BY h.usage_count DESC, h.last_used_at DESC
 		LIMIT 200
-	`)
+	`, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -1112,7 +1483,7 @@ func GetItemSuggestions(query string, limit int) ([]ItemSuggestion, error) {
 }
 
 // GetAllItemSuggestions returns all item suggestions for offline cache
-func GetAllItemSuggestions(limit int) ([]ItemSuggestion, error) {
+func GetAllItemSuggestions(householdID int64, limit int) ([]ItemSuggestion, error) {
 	if limit <= 0 {
 		limit = 100
 	}
@@ -1121,9 +1492,10 @@ func GetAllItemSuggestions(limit int) ([]ItemSuggestion, error) {
 		SELECT h.name, COALESCE(h.last_section_id, 0), COALESCE(s.name, ''), h.usage_count
 		FROM item_history h
 		LEFT JOIN sections s ON h.last_section_id = s.id
+		WHERE h.household_id = ?
 		ORDER BY h.usage_count DESC, h.last_used_at DESC
 		LIMIT ?
-	`, limit)
+	`, householdID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -1149,15 +1521,31 @@ type HistoryItem struct {
 	UsageCount      int    `json:"usage_count"`
 }
 
-// GetItemHistoryList returns all history items for management UI
-func GetItemHistoryList() ([]HistoryItem, error) {
+// GetItemHistoryByName returns the household's history entry for a name (case-insensitive)
+func GetItemHistoryByName(householdID int64, name string) (*HistoryItem, error) {
+	var h HistoryItem
+	err := DB.QueryRow(`
+		SELECT h.id, h.name, COALESCE(h.last_section_id, 0), COALESCE(s.name, ''), h.usage_count
+		FROM item_history h
+		LEFT JOIN sections s ON h.last_section_id = s.id
+		WHERE h.household_id = ? AND h.name = ? COLLATE NOCASE
+	`, householdID, name).Scan(&h.ID, &h.Name, &h.LastSectionID, &h.LastSectionName, &h.UsageCount)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetItemHistoryList returns the household's history items for management UI
+func GetItemHistoryList(householdID int64) ([]HistoryItem, error) {
 	rows, err := DB.Query(`
 		SELECT h.id, h.name, COALESCE(h.last_section_id, 0), COALESCE(s.name, ''), h.usage_count
 		FROM item_history h
 		LEFT JOIN sections s ON h.last_section_id = s.id
+		WHERE h.household_id = ?
 		ORDER BY h.usage_count DESC, h.last_used_at DESC
 		LIMIT 100
-	`)
+	`, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -1174,9 +1562,43 @@ func GetItemHistoryList() ([]HistoryItem, error) {
 	return items, nil
 }
 
-// DeleteItemHistory deletes a single item from history
-func DeleteItemHistory(id int64) error {
-	result, err := DB.Exec("DELETE FROM item_history WHERE id = ?", id)
+// historySorts are the sort keys of FindItemHistory
+var historySorts = sortKeys{
+	"usage_count":  {"h.usage_count", "COALESCE(h.last_used_at, 0)"},
+	"name":         {"h.name COLLATE NOCASE"},
+	"last_used_at": {"COALESCE(h.last_used_at, 0)"},
+}
+
+// FindItemHistory returns a page of a household's history entries, most used
+// first by default, optionally only those used since updatedSince (unix
+// seconds), and the next page's cursor
+func FindItemHistory(householdID, updatedSince int64, page PageRequest) ([]HistoryItem, string, error) {
+	from := `FROM item_history h LEFT JOIN sections s ON h.last_section_id = s.id WHERE h.household_id = ?`
+	args := []interface{}{householdID}
+	if updatedSince != 0 {
+		from += ` AND COALESCE(h.last_used_at, 0) >= ?`
+		args = append(args, updatedSince)
+	}
+
+	items := []HistoryItem{}
+	next, err := queryPage(`h.id, h.name, COALESCE(h.last_section_id, 0), COALESCE(s.name, ''), h.usage_count`,
+		from, args, "h.id", historySorts, "-usage_count", page, func(rows *sql.Rows, sortDest []interface{}) error {
+			var h HistoryItem
+			if err := rows.Scan(append([]interface{}{&h.ID, &h.Name, &h.LastSectionID, &h.LastSectionName, &h.UsageCount}, sortDest...)...); err != nil {
+				return err
+			}
+			items = append(items, h)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, next, nil
+}
+
+// DeleteItemHistory deletes a single item from the household's history
+func DeleteItemHistory(householdID, id int64) error {
+	result, err := DB.Exec("DELETE FROM item_history WHERE id = ? AND household_id = ?", id, householdID)
 	if err != nil {
 		return err
 	}
@@ -1187,21 +1609,22 @@ func DeleteItemHistory(id int64) error {
 	return nil
 }
 
-// DeleteItemHistoryBatch deletes multiple items from history
-func DeleteItemHistoryBatch(ids []int64) (int64, error) {
+// DeleteItemHistoryBatch deletes multiple items from the household's history
+func DeleteItemHistoryBatch(householdID int64, ids []int64) (int64, error) {
 	if len(ids) == 0 {
 		return 0, nil
 	}
 
 	// Build placeholders
 	placeholders := make([]string, len(ids))
-	args := make([]interface{}, len(ids))
+	args := make([]interface{}, len(ids), len(ids)+1)
 	for i, id := range ids {
 		placeholders[i] = "?"
 		args[i] = id
 	}
+	args = append(args, householdID)
 
-	query := fmt.Sprintf("DELETE FROM item_history WHERE id IN (%s)", strings.Join(placeholders, ","))
+	query := fmt.Sprintf("DELETE FROM item_history WHERE id IN (%s) AND household_id = ?", strings.Join(placeholders, ","))
 	result, err := DB.Exec(query, args...)
 	if err != nil {
 		return 0, err
@@ -1211,13 +1634,14 @@ func DeleteItemHistoryBatch(ids []int64) (int64, error) {
 
 // ==================== TEMPLATES ====================
 
-// GetAllTemplates returns all templates with their items
-func GetAllTemplates() ([]Template, error) {
+// GetAllTemplates returns all templates of a household with their items
+func GetAllTemplates(householdID int64) ([]Template, error) {
 	rows, err := DB.Query(`
 		SELECT id, name, description, sort_order, created_at, COALESCE(updated_at, 0)
 		FROM templates
+		WHERE household_id = ?
 		ORDER BY sort_order ASC
-	`)
+	`, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -1256,13 +1680,15 @@ func GetTemplateByID(id int64) (*Template, error) {
 	return &t, nil
 }
 
-// GetTemplateItems returns all items for a template
+// GetTemplateItems returns all items for a template, by section in the
+// template's section order
 func GetTemplateItems(templateID int64) ([]TemplateItem, error) {
 	rows, err := DB.Query(`
-		SELECT id, template_id, section_name, name, description, sort_order, created_at
-		FROM template_items
-		WHERE template_id = ?
-		ORDER BY section_name ASC, sort_order ASC
+		SELECT ti.id, ti.template_id, ti.section_name, ti.name, ti.description, COALESCE(ti.quantity, 0), COALESCE(ti.unit, ''), ti.sort_order, ti.created_at
+		FROM template_items ti
+		LEFT JOIN template_sections ts ON ts.template_id = ti.template_id AND ts.name = ti.section_name
+		WHERE ti.template_id = ?
+		ORDER BY ts.sort_order IS NULL, ts.sort_order ASC, ti.section_name ASC, ti.sort_order ASC
 	`, templateID)
 	if err != nil {
 		return nil, err
@@ -1272,7 +1698,7 @@ func GetTemplateItems(templateID int64) ([]TemplateItem, error) {
 	var items []TemplateItem
 	for rows.Next() {
 		var ti TemplateItem
-		err := rows.Scan(&ti.ID, &ti.TemplateID, &ti.SectionName, &ti.Name, &ti.Description, &ti.SortOrder, &ti.CreatedAt)
+		err := rows.Scan(&ti.ID, &ti.TemplateID, &ti.SectionName, &ti.Name, &ti.Description, &ti.Quantity, &ti.Unit, &ti.SortOrder, &ti.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -1281,14 +1707,14 @@ func GetTemplateItems(templateID int64) ([]TemplateItem, error) {
 	return items, nil
 }
 
-// CreateTemplate creates a new template
-func CreateTemplate(name, description string) (*Template, error) {
+// CreateTemplate creates a new template in a household
+func CreateTemplate(householdID int64, name, description string) (*Template, error) {
 	var maxOrder int
-	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM templates").Scan(&maxOrder)
+	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM templates WHERE household_id = ?", householdID).Scan(&maxOrder)
 
 	result, err := DB.Exec(`
-		INSERT INTO templates (name, description, sort_order) VALUES (?, ?, ?)
-	`, name, description, maxOrder+1)
+		INSERT INTO templates (household_id, name, description, sort_order) VALUES (?, ?, ?, ?)
+	`, householdID, name, description, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
@@ -1314,18 +1740,21 @@ func DeleteTemplate(id int64) error {
 	return err
 }
 
-// AddTemplateItem adds an item to a template
-func AddTemplateItem(templateID int64, sectionName, name, description string) (*TemplateItem, error) {
+// AddTemplateItem adds an item to a template; a new section goes last
+func AddTemplateItem(templateID int64, sectionName, name, description string, quantity float64, unit string) (*TemplateItem, error) {
 	var maxOrder int
 	DB.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM template_items WHERE template_id = ?", templateID).Scan(&maxOrder)
 
 	result, err := DB.Exec(`
-		INSERT INTO template_items (template_id, section_name, name, description, sort_order)
-		VALUES (?, ?, ?, ?, ?)
-	`, templateID, sectionName, name, description, maxOrder+1)
+		INSERT INTO template_items (template_id, section_name, name, description, quantity, unit, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, templateID, sectionName, name, description, quantity, unit, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
+	if err := addTemplateSection(DB, templateID, sectionName); err != nil {
+		return nil, err
+	}
 
 	id, _ := result.LastInsertId()
 	return GetTemplateItemByID(id)
@@ -1335,99 +1764,270 @@ func AddTemplateItem(templateID int64, sectionName, name, description string) (*
 func GetTemplateItemByID(id int64) (*TemplateItem, error) {
 	var ti TemplateItem
 	err := DB.QueryRow(`
-		SELECT id, template_id, section_name, name, description, sort_order, created_at
+		SELECT id, template_id, section_name, name, description, COALESCE(quantity, 0), COALESCE(unit, ''), sort_order, created_at
 		FROM template_items WHERE id = ?
-	`, id).Scan(&ti.ID, &ti.TemplateID, &ti.SectionName, &ti.Name, &ti.Description, &ti.SortOrder, &ti.CreatedAt)
+	`, id).Scan(&ti.ID, &ti.TemplateID, &ti.SectionName, &ti.Name, &ti.Description, &ti.Quantity, &ti.Unit, &ti.SortOrder, &ti.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &ti, nil
 }
 
-// UpdateTemplateItem updates a template item
-func UpdateTemplateItem(id int64, sectionName, name, description string) (*TemplateItem, error) {
+// UpdateTemplateItem updates a template item; moving it to a new section
+// puts that section last
+func UpdateTemplateItem(id int64, sectionName, name, description string, quantity float64, unit string) (*TemplateItem, error) {
 	_, err := DB.Exec(`
-		UPDATE template_items SET section_name = ?, name = ?, description = ? WHERE id = ?
-	`, sectionName, name, description, id)
+		UPDATE template_items SET section_name = ?, name = ?, description = ?, quantity = ?, unit = ? WHERE id = ?
+	`, sectionName, name, description, quantity, unit, id)
 	if err != nil {
 		return nil, err
 	}
-	return GetTemplateItemByID(id)
+	item, err := GetTemplateItemByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := addTemplateSection(DB, item.TemplateID, sectionName); err != nil {
+		return nil, err
+	}
+	return item, pruneTemplateSections(item.TemplateID)
 }
 
 // DeleteTemplateItem deletes a template item
 func DeleteTemplateItem(id int64) error {
-	_, err := DB.Exec(`DELETE FROM template_items WHERE id = ?`, id)
+	templateID, err := TemplateItemTemplateID(id)
+	if err != nil {
+		return err
+	}
+	if _, err := DB.Exec(`DELETE FROM template_items WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return pruneTemplateSections(templateID)
+}
+
+// addTemplateSection appends a section to a template's section order unless
+// it's there already
+func addTemplateSection(q interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, templateID int64, name string) error {
+	_, err := q.Exec(`
+		INSERT OR IGNORE INTO template_sections (template_id, name, sort_order)
+		SELECT ?, ?, COALESCE(MAX(sort_order), -1) + 1 FROM template_sections WHERE template_id = ?
+	`, templateID, name, templateID)
 	return err
 }
 
+// pruneTemplateSections drops the sections of a template left without items
+func pruneTemplateSections(templateID int64) error {
+	_, err := DB.Exec(`
+		DELETE FROM template_sections WHERE template_id = ? AND NOT EXISTS (
+			SELECT 1 FROM template_items ti WHERE ti.template_id = template_sections.template_id AND template_sections.name = ti.section_name
+		)
+	`, templateID)
+	return err
+}
+
+// ApplyTemplateOptions pick and scale the items ApplyTemplateToList adds
+type ApplyTemplateOptions struct {
+	Multiplier float64 // scales quantities, e.g. 8 for a per-person template at a party of eight; 0 for 1
+	ItemIDs    []int64 // template items to add; nil for all of them
+}
+
 // ApplyTemplateToList applies a template to a list (adds items from template)
-func ApplyTemplateToList(templateID, listID int64) error {
+func ApplyTemplateToList(templateID, listID int64, opts ApplyTemplateOptions) error {
+	_, _, err := applyTemplate(templateID, listID, opts, false)
+	return err
+}
+
+// countedUnits are the units of whole pieces; scaled amounts in them round up
+var countedUnits = map[string]bool{"": true, "pcs": true, "szt": true, "stk": true, "st": true, "pack": true, "can": true, "bottle": true}
+
+// ScaleQuantity multiplies a template item's quantity. Counted items round up
+// to whole pieces, measured amounts to two decimals; an item without a
+// quantity stays without one.
+func ScaleQuantity(quantity float64, unit string, multiplier float64) float64 {
+	if quantity <= 0 || multiplier <= 0 || multiplier == 1 {
+		return quantity
+	}
+	scaled := quantity * multiplier
+	if countedUnits[strings.ToLower(unit)] {
+		return math.Ceil(scaled - 1e-9)
+	}
+	return math.Round(scaled*100) / 100
+}
+
+func applyTemplate(templateID, listID int64, opts ApplyTemplateOptions, skipOpen bool) ([]Item, int, error) {
 	template, err := GetTemplateByID(templateID)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	tx, err := DB.Begin()
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 	defer tx.Rollback()
 
-	// Group items by section name
+	added, skipped, err := applyTemplateTx(tx, template, listID, opts, skipOpen)
+	if err != nil {
+		return nil, 0, err
+	}
+	return added, skipped, tx.Commit()
+}
+
+// applyTemplateTx adds a template's items to a list within a transaction,
+// skipping items already open on it when skipOpen is set. It returns the
+// items added and how many were skipped.
+func applyTemplateTx(tx *sql.Tx, template *Template, listID int64, opts ApplyTemplateOptions, skipOpen bool) ([]Item, int, error) {
+	var householdID int64
+	if err := tx.QueryRow("SELECT household_id FROM lists WHERE id = ?", listID).Scan(&householdID); err != nil {
+		return nil, 0, err
+	}
+
+	var open map[string]bool
+	if skipOpen {
+		var err error
+		if open, err = openItemNamesTx(tx, listID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var picked map[int64]bool
+	if opts.ItemIDs != nil {
+		picked = make(map[int64]bool)
+		for _, id := range opts.ItemIDs {
+			picked[id] = true
+		}
+	}
+
+	// Group items by section name, keeping the template's section order.
+	// Names match case-insensitively, like the section lookups below, so
+	// "Dairy" and "dairy" fill one section named after the first of them.
+	skipped := 0
+	var sectionNames []string
 	sectionItems := make(map[string][]TemplateItem)
 	for _, item := range template.Items {
-		sectionItems[item.SectionName] = append(sectionItems[item.SectionName], item)
+		key := strings.ToLower(item.SectionName)
+		if _, seen := sectionItems[key]; !seen {
+			sectionNames = append(sectionNames, item.SectionName)
+			sectionItems[key] = nil
+		}
+		if picked != nil && !picked[item.ID] {
+			continue
+		}
+		if open[strings.ToLower(strings.TrimSpace(item.Name))] {
+			skipped++
+			continue
+		}
+		sectionItems[key] = append(sectionItems[key], item)
 	}
 
-	// For each section in template
-	for sectionName, items := range sectionItems {
-		// Find or create section in target list
-		var sectionID int64
+	// Find the template's sections on the list, which place the missing ones
+	sectionIDs := make([]int64, len(sectionNames))
+	for i, sectionName := range sectionNames {
 		err := tx.QueryRow(`
 			SELECT id FROM sections WHERE list_id = ? AND name = ? COLLATE NOCASE
-		`, listID, sectionName).Scan(&sectionID)
+		`, listID, sectionName).Scan(&sectionIDs[i])
+		if err != nil && err != sql.ErrNoRows {
+			return nil, 0, err
+		}
+	}
 
-		if err != nil {
-			// Section doesn't exist, create it
-			var maxOrder int
-			tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM sections WHERE list_id = ?", listID).Scan(&maxOrder)
+	// For each section in template, in order
+	var added []Item
+	for i, sectionName := range sectionNames {
+		items := sectionItems[strings.ToLower(sectionName)]
+		if len(items) == 0 {
+			continue
+		}
 
-			result, err := tx.Exec(`
-				INSERT INTO sections (name, sort_order, list_id) VALUES (?, ?, ?)
-			`, sectionName, maxOrder+1, listID)
-			if err != nil {
-				return err
+		// Section doesn't exist, create it among the template's other sections
+		if sectionIDs[i] == 0 {
+			var err error
+			if sectionIDs[i], err = insertTemplateSectionTx(tx, listID, sectionName, sectionIDs, i); err != nil {
+				return nil, 0, err
 			}
-			sectionID, _ = result.LastInsertId()
 		}
+		sectionID := sectionIDs[i]
 
-		// Add items to section
+		// Add items to section, merging with items already on the list
 		for _, item := range items {
-			var maxItemOrder int
-			tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM items WHERE section_id = ?", sectionID).Scan(&maxItemOrder)
-
-			_, err := tx.Exec(`
-				INSERT INTO items (section_id, name, description, sort_order)
-				VALUES (?, ?, ?, ?)
-			`, sectionID, item.Name, item.Description, maxItemOrder+1)
+			quantity := ScaleQuantity(item.Quantity, item.Unit, opts.Multiplier)
+			newItem, _, err := AddItemTx(tx, sectionID, item.Name, item.Description, quantity, item.Unit, GetMaxItemOrderTx(tx, sectionID)+1, 0)
+			if err == ErrDuplicateItem {
+				continue // Already on the list, which rejects duplicates - keep the existing item
+			}
 			if err != nil {
-				return err
+				return nil, 0, err
 			}
+			added = append(added, *newItem)
 
 			// Save to item history
-			tx.Exec(`
-				INSERT INTO item_history (name, last_section_id, usage_count, last_used_at)
-				VALUES (?, ?, 1, strftime('%s', 'now'))
-				ON CONFLICT(name COLLATE NOCASE) DO UPDATE SET
-					last_section_id = excluded.last_section_id,
-					usage_count = usage_count + 1,
-					last_used_at = strftime('%s', 'now')
-			`, item.Name, sectionID)
+			SaveItemHistoryTx(tx, householdID, item.Name, sectionID)
 		}
 	}
 
-	return tx.Commit()
+	return added, skipped, nil
+}
+
+// insertTemplateSectionTx creates section i of a template on a list: after
+// the list's copy of the template section before it, else before the copy of
+// the one after it, else last. sectionIDs are the copies found so far, 0 for
+// missing ones.
+func insertTemplateSectionTx(tx *sql.Tx, listID int64, name string, sectionIDs []int64, i int) (int64, error) {
+	position := -1
+	for j := i - 1; j >= 0 && position < 0; j-- {
+		if sectionIDs[j] != 0 {
+			tx.QueryRow("SELECT sort_order + 1 FROM sections WHERE id = ?", sectionIDs[j]).Scan(&position)
+		}
+	}
+	for j := i + 1; j < len(sectionIDs) && position < 0; j++ {
+		if sectionIDs[j] != 0 {
+			tx.QueryRow("SELECT sort_order FROM sections WHERE id = ?", sectionIDs[j]).Scan(&position)
+		}
+	}
+
+	if position < 0 {
+		tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) + 1 FROM sections WHERE list_id = ?", listID).Scan(&position)
+	} else {
+		// Make room
+		_, err := tx.Exec(`
+			UPDATE sections SET sort_order = sort_order + 1 WHERE list_id = ? AND sort_order >= ?
+		`, listID, position)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO sections (name, sort_order, list_id) VALUES (?, ?, ?)
+	`, name, position, listID)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// openItemNamesTx returns the names of a list's open items, trimmed and
+// lowercased
+func openItemNamesTx(tx *sql.Tx, listID int64) (map[string]bool, error) {
+	rows, err := tx.Query(`
+		SELECT i.name FROM items i JOIN sections s ON s.id = i.section_id
+		WHERE s.list_id = ? AND i.completed = FALSE
+	`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return names, rows.Err()
 }
 
 // CreateTemplateFromList creates a template from an existing list
@@ -1443,13 +2043,17 @@ func CreateTemplateFromList(listID int64, templateName, templateDescription stri
 	}
 	defer tx.Rollback()
 
-	// Create template
+	// Create template in the list's household
+	var householdID int64
+	if err := tx.QueryRow("SELECT household_id FROM lists WHERE id = ?", listID).Scan(&householdID); err != nil {
+		return nil, err
+	}
 	var maxOrder int
-	tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM templates").Scan(&maxOrder)
+	tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM templates WHERE household_id = ?", householdID).Scan(&maxOrder)
 
 	result, err := tx.Exec(`
-		INSERT INTO templates (name, description, sort_order) VALUES (?, ?, ?)
-	`, templateName, templateDescription, maxOrder+1)
+		INSERT INTO templates (household_id, name, description, sort_order) VALUES (?, ?, ?, ?)
+	`, householdID, templateName, templateDescription, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
@@ -1461,12 +2065,16 @@ func CreateTemplateFromList(listID int64, templateName, templateDescription stri
 		for _, item := range section.Items {
 			if !item.Completed { // Only add non-completed items
 				_, err := tx.Exec(`
-					INSERT INTO template_items (template_id, section_name, name, description, sort_order)
-					VALUES (?, ?, ?, ?, ?)
-				`, templateID, section.Name, item.Name, item.Description, itemOrder)
+					INSERT INTO template_items (template_id, section_name, name, description, quantity, unit, sort_order)
+					VALUES (?, ?, ?, ?, ?, ?, ?)
+				`, templateID, section.Name, item.Name, item.Description, item.Quantity, item.Unit, itemOrder)
 				if err != nil {
 					return nil, err
 				}
+				// Sections are visited in list order, so the template keeps it
+				if err := addTemplateSection(tx, templateID, section.Name); err != nil {
+					return nil, err
+				}
 				itemOrder++
 			}
 		}
@@ -1482,18 +2090,18 @@ func CreateTemplateFromList(listID int64, templateName, templateDescription stri
 
 // ==================== TRANSACTION HELPERS (for batch API) ====================
 
-// CreateListTx creates a list within a transaction
-func CreateListTx(tx *sql.Tx, name, icon string) (*List, error) {
+// CreateListTx creates a list in a household within a transaction
+func CreateListTx(tx *sql.Tx, householdID int64, name, icon string) (*List, error) {
 	var maxOrder int
-	tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM lists").Scan(&maxOrder)
+	tx.QueryRow("SELECT COALESCE(MAX(sort_order), -1) FROM lists WHERE household_id = ?", householdID).Scan(&maxOrder)
 
 	if icon == "" {
 		icon = "🛒"
 	}
 
 	result, err := tx.Exec(`
-		INSERT INTO lists (name, icon, sort_order, is_active) VALUES (?, ?, ?, FALSE)
-	`, name, icon, maxOrder+1)
+		INSERT INTO lists (household_id, name, icon, sort_order, is_active) VALUES (?, ?, ?, ?, FALSE)
+	`, householdID, name, icon, maxOrder+1)
 	if err != nil {
 		return nil, err
 	}
@@ -1502,9 +2110,9 @@ func CreateListTx(tx *sql.Tx, name, icon string) (*List, error) {
 
 	var l List
 	err = tx.QueryRow(`
-		SELECT id, name, COALESCE(icon, '🛒'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
+		SELECT id, name, COALESCE(icon, '🛒'), COALESCE(duplicate_mode, 'merge'), sort_order, is_active, created_at, COALESCE(updated_at, 0)
 		FROM lists WHERE id = ?
-	`, id).Scan(&l.ID, &l.Name, &l.Icon, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+	`, id).Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -1535,36 +2143,150 @@ func CreateSectionForListTx(tx *sql.Tx, listID int64, name string, sortOrder int
 }
 
 // CreateItemTx creates an item within a transaction
-func CreateItemTx(tx *sql.Tx, sectionID int64, name, description string, sortOrder int) (*Item, error) {
+func CreateItemTx(tx *sql.Tx, sectionID int64, name, description string, quantity float64, unit string, sortOrder int) (*Item, error) {
 	result, err := tx.Exec(`
-		INSERT INTO items (section_id, name, description, sort_order) VALUES (?, ?, ?, ?)
-	`, sectionID, name, description, sortOrder)
+		INSERT INTO items (section_id, name, description, quantity, unit, sort_order) VALUES (?, ?, ?, ?, ?, ?)
+	`, sectionID, name, description, quantity, unit, sortOrder)
 	if err != nil {
 		return nil, err
 	}
 
 	id, _ := result.LastInsertId()
+	return GetItemTx(tx, id)
+}
 
+// AddItemTx is AddItem within a transaction; sortOrder and recurDays are used
+// only when a new row is created
+func AddItemTx(tx *sql.Tx, sectionID int64, name, description string, quantity float64, unit string, sortOrder, recurDays int) (*Item, bool, error) {
+	duplicates, mode, err := findOpenDuplicatesTx(tx, sectionID, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(duplicates) > 0 && mode == DuplicateModeReject {
+		return &duplicates[0], false, ErrDuplicateItem
+	}
+
+	// Merge into the first open item whose quantity adds up
+	for _, existing := range duplicates {
+		newQty, newUnit, ok := mergeQuantity(existing.Quantity, existing.Unit, quantity, unit)
+		if !ok {
+			continue
+		}
+		if existing.Description == "" {
+			existing.Description = description
+		}
+
+		_, err = tx.Exec(`
+			UPDATE items SET description = ?, quantity = ?, unit = ?, updated_at = strftime('%s', 'now') WHERE id = ?
+		`, existing.Description, newQty, newUnit, existing.ID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		item, err := GetItemTx(tx, existing.ID)
+		return item, true, err
+	}
+
+	item, err := CreateItemTx(tx, sectionID, name, description, quantity, unit, sortOrder)
+	if err == nil && recurDays > 0 {
+		item, err = setItemRecurDaysTx(tx, item.ID, recurDays)
+	}
+	return item, false, err
+}
+
+// GetItemTx returns a single item by ID within a transaction
+func GetItemTx(tx *sql.Tx, id int64) (*Item, error) {
 	var i Item
-	err = tx.QueryRow(`
-		SELECT id, section_id, name, description, completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0)
+	err := tx.QueryRow(`
+		SELECT id, section_id, name, description, COALESCE(quantity, 0), COALESCE(unit, ''), completed, uncertain, sort_order, created_at, COALESCE(updated_at, 0), COALESCE(recur_days, 0), COALESCE(recurs_at, 0)
 		FROM items WHERE id = ?
-	`, id).Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt)
+	`, id).Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt)
 	if err != nil {
 		return nil, err
 	}
 	return &i, nil
 }
 
-// SaveItemHistoryTx saves item name to history within a transaction
-func SaveItemHistoryTx(tx *sql.Tx, name string, sectionID int64) {
-	tx.Exec(`
-		INSERT INTO item_history (name, last_section_id, usage_count)
-		VALUES (?, ?, 1)
-		ON CONFLICT(name) DO UPDATE SET
-			usage_count = usage_count + 1,
-			last_section_id = excluded.last_section_id
-	`, name, sectionID)
+// GetSectionTx returns a single section, without its items, within a transaction
+func GetSectionTx(tx *sql.Tx, id int64) (*Section, error) {
+	var s Section
+	err := tx.QueryRow(`
+		SELECT id, list_id, name, sort_order, created_at, COALESCE(updated_at, 0)
+		FROM sections WHERE id = ?
+	`, id).Scan(&s.ID, &s.ListID, &s.Name, &s.SortOrder, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSectionTx renames a section within a transaction
+func UpdateSectionTx(tx *sql.Tx, id int64, name string) (*Section, error) {
+	_, err := tx.Exec(`UPDATE sections SET name = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, name, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetSectionTx(tx, id)
+}
+
+// DeleteSectionTx deletes a section and its items within a transaction
+func DeleteSectionTx(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec(`DELETE FROM sections WHERE id = ?`, id)
+	return err
+}
+
+// UpdateItemTx updates an item's name, description and quantity within a transaction
+func UpdateItemTx(tx *sql.Tx, id int64, name, description string, quantity float64, unit string) (*Item, error) {
+	_, err := tx.Exec(`
+		UPDATE items SET name = ?, description = ?, quantity = ?, unit = ?, updated_at = strftime('%s', 'now') WHERE id = ?
+	`, name, description, quantity, unit, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemTx(tx, id)
+}
+
+// SetItemCompletedTx sets an item's completed flag within a transaction
+func SetItemCompletedTx(tx *sql.Tx, id int64, completed bool) (*Item, error) {
+	_, err := tx.Exec(`
+		UPDATE items SET completed = ?, recurs_at = `+recursAt(strconv.FormatBool(completed), "COALESCE(recur_days, 0)")+`, updated_at = strftime('%s', 'now')
+		WHERE id = ?
+	`, completed, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemTx(tx, id)
+}
+
+// SetItemUncertainTx sets an item's uncertain flag within a transaction
+func SetItemUncertainTx(tx *sql.Tx, id int64, uncertain bool) (*Item, error) {
+	_, err := tx.Exec(`UPDATE items SET uncertain = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, uncertain, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemTx(tx, id)
+}
+
+// MoveItemToSectionTx moves an item to the end of another section within a transaction
+func MoveItemToSectionTx(tx *sql.Tx, id, newSectionID int64) (*Item, error) {
+	_, err := tx.Exec(`
+		UPDATE items SET section_id = ?, sort_order = ?, updated_at = strftime('%s', 'now') WHERE id = ?
+	`, newSectionID, GetMaxItemOrderTx(tx, newSectionID)+1, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemTx(tx, id)
+}
+
+// DeleteItemTx deletes an item within a transaction
+func DeleteItemTx(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec(`DELETE FROM items WHERE id = ?`, id)
+	return err
+}
+
+// SaveItemHistoryTx saves item name to the household's history within a transaction
+func SaveItemHistoryTx(tx *sql.Tx, householdID int64, name string, sectionID int64) {
+	tx.Exec(saveItemHistorySQL, householdID, name, sectionID)
 }
 
 // GetMaxSectionOrderTx gets max sort_order for sections in a list within a transaction
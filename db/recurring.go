@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ==================== RECURRING ITEMS ====================
+
+// recursAt is the SQL for an item's recurs_at in an UPDATE that leaves the
+// item completed when completed is true and recurring every recurDays days,
+// both SQL expressions over the row before the update. A recurring item is
+// due back recurDays after it was bought; one already waiting keeps its due
+// time unless its interval changed.
+func recursAt(completed, recurDays string) string {
+	return `CASE
+		WHEN NOT (` + completed + `) OR (` + recurDays + `) <= 0 THEN NULL
+		WHEN completed AND recurs_at IS NOT NULL AND COALESCE(recur_days, 0) = (` + recurDays + `) THEN recurs_at
+		ELSE CAST(strftime('%s', 'now') AS INTEGER) + (` + recurDays + `) * 86400
+	END`
+}
+
+// saveRecurringItemsTx keeps the recurring completed items of a list about to
+// be cleared, so ReviveDueItems can add them back when they are due
+func saveRecurringItemsTx(tx *sql.Tx, listID int64) error {
+	_, err := tx.Exec(`
+		INSERT INTO recurring_items (section_id, name, description, quantity, unit, recur_days, recurs_at)
+		SELECT i.section_id, i.name, COALESCE(i.description, ''), COALESCE(i.quantity, 0), COALESCE(i.unit, ''), i.recur_days,
+			COALESCE(i.recurs_at, CAST(strftime('%s', 'now') AS INTEGER) + i.recur_days * 86400)
+		FROM items i JOIN sections s ON s.id = i.section_id
+		WHERE i.completed = TRUE AND i.recur_days > 0 AND s.list_id = ?
+	`, listID)
+	return err
+}
+
+// RevivedItems are the recurring items ReviveDueItems brought back
+type RevivedItems struct {
+	Reopened []Item // completed items, open again
+	Added    []Item // items cleared from the list, added back to their section
+	Updated  []Item // open items with the same name as a cleared one, now recurring in its place
+}
+
+// ReviveDueItems brings back the recurring items due by now: completed items
+// are marked open again and cleared ones are added back, unless an open item
+// with the same name is already on the list
+func ReviveDueItems(now time.Time) (*RevivedItems, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	revived := &RevivedItems{}
+
+	reopened, err := syncIDs(tx, `SELECT id FROM items WHERE completed = TRUE AND recurs_at <= ?`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range reopened {
+		item, err := SetItemCompletedTx(tx, id, false)
+		if err != nil {
+			return nil, err
+		}
+		revived.Reopened = append(revived.Reopened, *item)
+	}
+
+	type pending struct {
+		id, sectionID     int64
+		name, description string
+		quantity          float64
+		unit              string
+		recurDays         int
+	}
+	rows, err := tx.Query(`
+		SELECT id, section_id, name, description, quantity, unit, recur_days
+		FROM recurring_items WHERE recurs_at <= ? ORDER BY recurs_at ASC, id ASC
+	`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	var due []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.sectionID, &p.name, &p.description, &p.quantity, &p.unit, &p.recurDays); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range due {
+		if _, err := tx.Exec(`DELETE FROM recurring_items WHERE id = ?`, p.id); err != nil {
+			return nil, err
+		}
+
+		existing, _, err := findOpenDuplicateTx(tx, p.sectionID, p.name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if existing.RecurDays == 0 {
+				item, err := setItemRecurDaysTx(tx, existing.ID, p.recurDays)
+				if err != nil {
+					return nil, err
+				}
+				revived.Updated = append(revived.Updated, *item)
+			}
+			continue
+		}
+
+		item, err := CreateItemTx(tx, p.sectionID, p.name, p.description, p.quantity, p.unit, GetMaxItemOrderTx(tx, p.sectionID)+1)
+		if err != nil {
+			return nil, err
+		}
+		if item, err = setItemRecurDaysTx(tx, item.ID, p.recurDays); err != nil {
+			return nil, err
+		}
+		revived.Added = append(revived.Added, *item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revived, nil
+}
+
+// setItemRecurDaysTx sets the interval of an open item within a transaction
+func setItemRecurDaysTx(tx *sql.Tx, id int64, days int) (*Item, error) {
+	_, err := tx.Exec(`UPDATE items SET recur_days = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, days, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetItemTx(tx, id)
+}
@@ -0,0 +1,31 @@
+package db
+
+import "testing"
+
+func TestAddItemRecurDaysOnlyOnNewItems(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, merged, err := AddItem(section.ID, "Milk", "", 1, "l", 7)
+	if err != nil || merged {
+		t.Fatalf("first add: merged=%v err=%v", merged, err)
+	}
+	if item.RecurDays != 7 {
+		t.Errorf("new item recurs every %d days, want 7", item.RecurDays)
+	}
+
+	item, merged, err = AddItem(section.ID, "milk", "", 1, "l", 30)
+	if err != nil || !merged {
+		t.Fatalf("second add: merged=%v err=%v", merged, err)
+	}
+	if item.RecurDays != 7 || item.Quantity != 2 {
+		t.Errorf("merged item = %v l every %d days, want 2 l every 7", item.Quantity, item.RecurDays)
+	}
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Template schedule run statuses
+const (
+	RunApplied   = "applied"   // the template added items to the list
+	RunUnchanged = "unchanged" // every item was already open on the list
+	RunFailed    = "failed"
+)
+
+// MaxScheduleRuns is how many runs are kept in each schedule's history
+const MaxScheduleRuns = 100
+
+// ScheduleTimeLayout is the format of a schedule's time of day
+const ScheduleTimeLayout = "15:04"
+
+// Weekdays are the day names a schedule accepts, indexed by time.Weekday
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// TemplateSchedule applies a template to a list every EveryDays days, or on
+// the listed Weekdays, at Time (server local time). Items already open on
+// the list are skipped.
+type TemplateSchedule struct {
+	ID           int64     `json:"id"`
+	HouseholdID  int64     `json:"-"`
+	TemplateID   int64     `json:"template_id"`
+	TemplateName string    `json:"template_name"`
+	ListID       int64     `json:"list_id"`
+	ListName     string    `json:"list_name"`
+	EveryDays    int       `json:"every_days,omitempty"`
+	Weekdays     []string  `json:"weekdays,omitempty"`
+	Time         string    `json:"time"` // HH:MM
+	Enabled      bool      `json:"enabled"`
+	NextRunAt    int64     `json:"next_run_at,omitempty"` // unix seconds; unset while disabled
+	LastRunAt    int64     `json:"last_run_at,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NextRun returns when the schedule is next due after after, in unix
+// seconds, or 0 when it is disabled. An every-N-days schedule counts from
+// previous, its last due time (0 if it has none), so late runs don't drift.
+func (s *TemplateSchedule) NextRun(previous int64, after time.Time) int64 {
+	at, err := time.Parse(ScheduleTimeLayout, s.Time)
+	if !s.Enabled || err != nil || (s.EveryDays <= 0 && len(s.Weekdays) == 0) {
+		return 0
+	}
+	after = after.In(time.Local)
+
+	if s.EveryDays > 0 && previous != 0 {
+		last := time.Unix(previous, 0).In(time.Local)
+		next := time.Date(last.Year(), last.Month(), last.Day(), at.Hour(), at.Minute(), 0, 0, time.Local)
+		for !next.After(after) {
+			next = next.AddDate(0, 0, s.EveryDays)
+		}
+		return next.Unix()
+	}
+
+	next := time.Date(after.Year(), after.Month(), after.Day(), at.Hour(), at.Minute(), 0, 0, time.Local)
+	for !next.After(after) || !s.runsOn(next.Weekday()) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Unix()
+}
+
+// runsOn reports whether the schedule may run on day
+func (s *TemplateSchedule) runsOn(day time.Weekday) bool {
+	if s.EveryDays > 0 {
+		return true
+	}
+	for _, name := range s.Weekdays {
+		if name == Weekdays[day] {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleRun is one application of a scheduled template
+type ScheduleRun struct {
+	ID           int64  `json:"id"`
+	ScheduleID   int64  `json:"schedule_id"`
+	Status       string `json:"status"`
+	ItemsAdded   int    `json:"items_added"`
+	ItemsSkipped int    `json:"items_skipped"` // already open on the list
+	Error        string `json:"error,omitempty"`
+	Manual       bool   `json:"manual"` // started through the API rather than by the scheduler
+	RanAt        int64  `json:"ran_at"`
+}
+
+// ==================== TEMPLATE SCHEDULES ====================
+
+const scheduleColumns = `
+	s.id, s.household_id, s.template_id, t.name, s.list_id, l.name, s.every_days, s.weekdays, s.at_time,
+	s.enabled, COALESCE(s.next_run_at, 0), COALESCE(s.last_run_at, 0), s.created_at
+	FROM template_schedules s JOIN templates t ON t.id = s.template_id JOIN lists l ON l.id = s.list_id`
+
+func scanTemplateSchedule(row interface{ Scan(...interface{}) error }) (*TemplateSchedule, error) {
+	var s TemplateSchedule
+	var weekdays string
+	err := row.Scan(&s.ID, &s.HouseholdID, &s.TemplateID, &s.TemplateName, &s.ListID, &s.ListName, &s.EveryDays, &weekdays, &s.Time,
+		&s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.Weekdays = strings.Fields(weekdays)
+	return &s, nil
+}
+
+func scanTemplateSchedules(rows *sql.Rows, err error) ([]TemplateSchedule, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []TemplateSchedule{}
+	for rows.Next() {
+		s, err := scanTemplateSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// GetTemplateSchedules returns the household's template schedules, oldest first
+func GetTemplateSchedules(householdID int64) ([]TemplateSchedule, error) {
+	return scanTemplateSchedules(DB.Query(`SELECT `+scheduleColumns+` WHERE s.household_id = ? ORDER BY s.id ASC`, householdID))
+}
+
+// GetTemplateScheduleByID returns a single template schedule by ID
+func GetTemplateScheduleByID(id int64) (*TemplateSchedule, error) {
+	return scanTemplateSchedule(DB.QueryRow(`SELECT `+scheduleColumns+` WHERE s.id = ?`, id))
+}
+
+// GetDueTemplateSchedules returns the enabled schedules due by now
+func GetDueTemplateSchedules(now time.Time) ([]TemplateSchedule, error) {
+	return scanTemplateSchedules(DB.Query(`
+		SELECT `+scheduleColumns+`
+		WHERE s.enabled = TRUE AND s.next_run_at <= ?
+		ORDER BY s.next_run_at ASC
+	`, now.Unix()))
+}
+
+// CreateTemplateSchedule stores a new schedule, due at its first run after now
+func CreateTemplateSchedule(householdID int64, s TemplateSchedule) (*TemplateSchedule, error) {
+	result, err := DB.Exec(`
+		INSERT INTO template_schedules (household_id, template_id, list_id, every_days, weekdays, at_time, enabled, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, householdID, s.TemplateID, s.ListID, s.EveryDays, strings.Join(s.Weekdays, " "), s.Time, s.Enabled,
+		nullableID(s.NextRun(0, time.Now())))
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetTemplateScheduleByID(id)
+}
+
+// UpdateTemplateSchedule replaces a schedule, due again at its first run
+// after now
+func UpdateTemplateSchedule(id int64, s TemplateSchedule) (*TemplateSchedule, error) {
+	_, err := DB.Exec(`
+		UPDATE template_schedules SET template_id = ?, list_id = ?, every_days = ?, weekdays = ?, at_time = ?, enabled = ?, next_run_at = ?
+		WHERE id = ?
+	`, s.TemplateID, s.ListID, s.EveryDays, strings.Join(s.Weekdays, " "), s.Time, s.Enabled,
+		nullableID(s.NextRun(0, time.Now())), id)
+	if err != nil {
+		return nil, err
+	}
+	return GetTemplateScheduleByID(id)
+}
+
+// DeleteTemplateSchedule removes a schedule and its run history
+func DeleteTemplateSchedule(id int64) error {
+	_, err := DB.Exec(`DELETE FROM template_schedules WHERE id = ?`, id)
+	return err
+}
+
+// ErrScheduleNotDue is returned for a scheduled run when the schedule's next
+// due time changed since it was read, e.g. because another run advanced it
+var ErrScheduleNotDue = errors.New("schedule is no longer due")
+
+// ApplyTemplateSchedule applies a schedule's template to its list, skipping
+// items already open on it, and records the run. A scheduled run also moves
+// the schedule to nextRunAt, but only while it is still due at the time s was
+// read; otherwise nothing happens and ErrScheduleNotDue is returned. A manual
+// run leaves the next due time alone. The apply and the record happen in one
+// transaction, so a schedule whose items were added is never left due to add
+// them again. When the apply fails nothing is added and the failure is
+// recorded on its own.
+func ApplyTemplateSchedule(s *TemplateSchedule, manual bool, nextRunAt int64) (*ScheduleRun, error) {
+	run, err := applyTemplateSchedule(s, manual, nextRunAt)
+	if err == nil || err == ErrScheduleNotDue {
+		return run, err
+	}
+
+	run = &ScheduleRun{ScheduleID: s.ID, Manual: manual, Status: RunFailed, Error: err.Error()}
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := advanceScheduleTx(tx, s, manual, nextRunAt); err != nil {
+		return nil, err
+	}
+	if err := recordScheduleRunTx(tx, run); err != nil {
+		return nil, err
+	}
+	return run, tx.Commit()
+}
+
+func applyTemplateSchedule(s *TemplateSchedule, manual bool, nextRunAt int64) (*ScheduleRun, error) {
+	template, err := GetTemplateByID(s.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := advanceScheduleTx(tx, s, manual, nextRunAt); err != nil {
+		return nil, err
+	}
+	added, skipped, err := applyTemplateTx(tx, template, s.ListID, ApplyTemplateOptions{}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &ScheduleRun{ScheduleID: s.ID, Manual: manual, Status: RunApplied, ItemsAdded: len(added), ItemsSkipped: skipped}
+	if len(added) == 0 {
+		run.Status = RunUnchanged
+	}
+	if err := recordScheduleRunTx(tx, run); err != nil {
+		return nil, err
+	}
+	return run, tx.Commit()
+}
+
+// advanceScheduleTx moves a scheduled run's schedule to nextRunAt if it is
+// still due at s.NextRunAt, and takes the write lock so no other run can
+// claim it meanwhile. Manual runs only take the lock.
+func advanceScheduleTx(tx *sql.Tx, s *TemplateSchedule, manual bool, nextRunAt int64) error {
+	if manual {
+		_, err := tx.Exec(`UPDATE template_schedules SET id = id WHERE FALSE`)
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE template_schedules SET next_run_at = ? WHERE id = ? AND next_run_at IS ?
+	`, nullableID(nextRunAt), s.ID, nullableID(s.NextRunAt))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrScheduleNotDue
+	}
+	return nil
+}
+
+// recordScheduleRunTx adds a run to a schedule's history, dropping the oldest
+// beyond MaxScheduleRuns, and sets when the schedule last ran
+func recordScheduleRunTx(tx *sql.Tx, run *ScheduleRun) error {
+	var errText interface{}
+	if run.Error != "" {
+		errText = run.Error
+	}
+	run.RanAt = time.Now().Unix()
+	result, err := tx.Exec(`
+		INSERT INTO template_schedule_runs (schedule_id, status, items_added, items_skipped, error, manual, ran_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, run.ScheduleID, run.Status, run.ItemsAdded, run.ItemsSkipped, errText, run.Manual, run.RanAt)
+	if err != nil {
+		return err
+	}
+	run.ID, _ = result.LastInsertId()
+
+	_, err = tx.Exec(`
+		DELETE FROM template_schedule_runs WHERE schedule_id = ? AND id <= (
+			SELECT id FROM template_schedule_runs WHERE schedule_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)
+	`, run.ScheduleID, run.ScheduleID, MaxScheduleRuns)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE template_schedules SET last_run_at = ? WHERE id = ?`, run.RanAt, run.ScheduleID)
+	return err
+}
+
+// GetScheduleRuns returns a schedule's most recent runs, newest first
+func GetScheduleRuns(scheduleID int64, limit int) ([]ScheduleRun, error) {
+	rows, err := DB.Query(`
+		SELECT id, schedule_id, status, items_added, items_skipped, COALESCE(error, ''), manual, ran_at
+		FROM template_schedule_runs WHERE schedule_id = ? ORDER BY id DESC LIMIT ?
+	`, scheduleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		var r ScheduleRun
+		if err := rows.Scan(&r.ID, &r.ScheduleID, &r.Status, &r.ItemsAdded, &r.ItemsSkipped, &r.Error, &r.Manual, &r.RanAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
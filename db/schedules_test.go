@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyTemplateScheduleRecordsRunWithApply(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Weekly", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := CreateTemplate(DefaultHouseholdID, "Basics", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTemplateItem(template.ID, "Dairy", "Milk", "", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := CreateTemplateSchedule(DefaultHouseholdID, TemplateSchedule{
+		TemplateID: template.ID, ListID: list.ID, EveryDays: 7, Time: "08:00", Enabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Due an hour ago, as the scheduler would find it
+	if _, err := DB.Exec(`UPDATE template_schedules SET next_run_at = ? WHERE id = ?`, time.Now().Add(-time.Hour).Unix(), schedule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if schedule, err = GetTemplateScheduleByID(schedule.ID); err != nil {
+		t.Fatal(err)
+	}
+	next := schedule.NextRun(schedule.NextRunAt, time.Now())
+
+	listItems := func() int {
+		sections, err := GetSectionsByList(list.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, section := range sections {
+			n += len(section.Items)
+		}
+		return n
+	}
+
+	// The run can't be recorded: nothing is added and the schedule stays due
+	if _, err := DB.Exec(`
+		CREATE TRIGGER fail_runs BEFORE INSERT ON template_schedule_runs BEGIN SELECT RAISE(ABORT, 'disk full'); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyTemplateSchedule(schedule, false, next); err == nil {
+		t.Fatal("run recorded despite the failing history")
+	}
+	if n := listItems(); n != 0 {
+		t.Errorf("%d items added by an unrecorded run, want 0", n)
+	}
+	if s, _ := GetTemplateScheduleByID(schedule.ID); s.NextRunAt != schedule.NextRunAt {
+		t.Errorf("next_run_at moved to %d by an unrecorded run", s.NextRunAt)
+	}
+
+	if _, err := DB.Exec(`DROP TRIGGER fail_runs`); err != nil {
+		t.Fatal(err)
+	}
+	run, err := ApplyTemplateSchedule(schedule, false, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != RunApplied || run.ItemsAdded != 1 || listItems() != 1 {
+		t.Errorf("run = %+v with %d list items, want applied with 1", run, listItems())
+	}
+	if s, _ := GetTemplateScheduleByID(schedule.ID); s.NextRunAt != next {
+		t.Errorf("next_run_at = %d, want %d", s.NextRunAt, next)
+	}
+
+	// A scheduled run that read the schedule before that run advanced it
+	if _, err := ApplyTemplateSchedule(schedule, false, next); err != ErrScheduleNotDue {
+		t.Errorf("stale scheduled run: err = %v, want ErrScheduleNotDue", err)
+	}
+
+	// A manual run records itself without touching the next due time
+	run, err = ApplyTemplateSchedule(schedule, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != RunUnchanged || run.ItemsSkipped != 1 {
+		t.Errorf("manual run = %+v, want unchanged with 1 skipped", run)
+	}
+	if s, _ := GetTemplateScheduleByID(schedule.ID); s.NextRunAt != next || s.LastRunAt != run.RanAt {
+		t.Errorf("after a manual run next_run_at = %d, last_run_at = %d, want %d and %d", s.NextRunAt, s.LastRunAt, next, run.RanAt)
+	}
+}
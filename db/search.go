@@ -0,0 +1,297 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// searchFTS is set by the migration when the FTS5 index is available;
+// otherwise Search matches with LIKE
+var searchFTS bool
+
+// SearchResults are the matches of a search: lists holding matching
+// sections or items (or matching by name), then matching templates
+type SearchResults struct {
+	Query     string                 `json:"query"`
+	Lists     []ListSearchResult     `json:"lists"`
+	Templates []TemplateSearchResult `json:"templates"`
+}
+
+// ListSearchResult groups the matches of one list
+type ListSearchResult struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Icon        string          `json:"icon"`
+	NameMatched bool            `json:"name_matched"`
+	Sections    []SectionHit    `json:"sections"` // sections whose name matched
+	Items       []ItemSearchHit `json:"items"`    // open and completed items, best match first
+	sortOrder   int
+}
+
+// SectionHit is a section whose name matched
+type SectionHit struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// ItemSearchHit is a matching item with the name of its section
+type ItemSearchHit struct {
+	Item
+	SectionName string `json:"section_name"`
+}
+
+// TemplateSearchResult groups the matches of one template
+type TemplateSearchResult struct {
+	ID          int64          `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	NameMatched bool           `json:"name_matched"` // the name or description matched
+	Items       []TemplateItem `json:"items"`
+	sortOrder   int
+}
+
+// searchQuery is the part of a query matching terms against one table
+type searchQuery struct {
+	join  string // joins the FTS5 index, if used
+	where string
+	order string
+	args  []interface{}
+}
+
+// newSearchQuery matches every term as a word prefix against table (aliased
+// alias) through its FTS5 index, or as a substring of columns with LIKE
+func newSearchQuery(table, alias string, columns []string, terms []string) searchQuery {
+	if searchFTS {
+		fts := "search_" + table
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+		}
+		return searchQuery{
+			join:  "JOIN " + fts + " ON " + fts + ".rowid = " + alias + ".id",
+			where: fts + " MATCH ?",
+			order: fts + ".rank",
+			args:  []interface{}{strings.Join(quoted, " ")},
+		}
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	var conds []string
+	var args []interface{}
+	for _, term := range terms {
+		var alts []string
+		for _, col := range columns {
+			alts = append(alts, "COALESCE("+alias+"."+col+", '') LIKE ? ESCAPE '\\'")
+			args = append(args, "%"+escaper.Replace(term)+"%")
+		}
+		conds = append(conds, "("+strings.Join(alts, " OR ")+")")
+	}
+	return searchQuery{
+		where: strings.Join(conds, " AND "),
+		order: alias + ".name COLLATE NOCASE",
+		args:  args,
+	}
+}
+
+// bind returns the query's arguments between the scope and the limit
+func (q searchQuery) bind(scope []interface{}, limit int) []interface{} {
+	args := append([]interface{}{}, scope...)
+	return append(append(args, q.args...), limit)
+}
+
+// eachRow runs query and calls scan for every row
+func eachRow(query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Search finds lists, sections, items, templates and template items of a
+// household whose text matches every word of query. listID limits it to one
+// list (0 for all); templates are skipped unless withTemplates. limit caps the
+// matches of each kind.
+func Search(householdID, listID int64, query string, withTemplates bool, limit int) (*SearchResults, error) {
+	results := &SearchResults{
+		Query:     query,
+		Lists:     []ListSearchResult{},
+		Templates: []TemplateSearchResult{},
+	}
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	listFilter := ""
+	scope := []interface{}{householdID}
+	if listID != 0 {
+		listFilter = " AND l.id = ?"
+		scope = append(scope, listID)
+	}
+
+	// Matches are grouped under their list, created by whichever kind finds it first
+	lists := make(map[int64]*ListSearchResult)
+	var list *ListSearchResult
+	var listName, icon string
+	var sortOrder int
+	scanList := func(id int64) {
+		if lists[id] == nil {
+			lists[id] = &ListSearchResult{
+				ID: id, Name: listName, Icon: icon, sortOrder: sortOrder,
+				Sections: []SectionHit{}, Items: []ItemSearchHit{},
+			}
+		}
+		list = lists[id]
+	}
+
+	// Lists by name
+	q := newSearchQuery("lists", "l", []string{"name"}, terms)
+	err := eachRow(`
+		SELECT l.id, l.name, COALESCE(l.icon, '🛒'), l.sort_order FROM lists l `+q.join+`
+		WHERE l.household_id = ?`+listFilter+` AND `+q.where+`
+		ORDER BY `+q.order+` LIMIT ?
+	`, q.bind(scope, limit), func(rows *sql.Rows) error {
+		var id int64
+		if err := rows.Scan(&id, &listName, &icon, &sortOrder); err != nil {
+			return err
+		}
+		scanList(id)
+		list.NameMatched = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sections by name
+	q = newSearchQuery("sections", "s", []string{"name"}, terms)
+	err = eachRow(`
+		SELECT s.id, s.name, l.id, l.name, COALESCE(l.icon, '🛒'), l.sort_order
+		FROM sections s JOIN lists l ON l.id = s.list_id `+q.join+`
+		WHERE l.household_id = ?`+listFilter+` AND `+q.where+`
+		ORDER BY `+q.order+` LIMIT ?
+	`, q.bind(scope, limit), func(rows *sql.Rows) error {
+		var hit SectionHit
+		var id int64
+		if err := rows.Scan(&hit.ID, &hit.Name, &id, &listName, &icon, &sortOrder); err != nil {
+			return err
+		}
+		scanList(id)
+		list.Sections = append(list.Sections, hit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Items by name and description, open and completed
+	q = newSearchQuery("items", "i", []string{"name", "description"}, terms)
+	err = eachRow(`
+		SELECT i.id, i.section_id, i.name, COALESCE(i.description, ''), COALESCE(i.quantity, 0), COALESCE(i.unit, ''),
+			i.completed, i.uncertain, i.sort_order, i.created_at, COALESCE(i.updated_at, 0), COALESCE(i.recur_days, 0), COALESCE(i.recurs_at, 0),
+			s.name, l.id, l.name, COALESCE(l.icon, '🛒'), l.sort_order
+		FROM items i JOIN sections s ON s.id = i.section_id JOIN lists l ON l.id = s.list_id `+q.join+`
+		WHERE l.household_id = ?`+listFilter+` AND `+q.where+`
+		ORDER BY `+q.order+` LIMIT ?
+	`, q.bind(scope, limit), func(rows *sql.Rows) error {
+		var hit ItemSearchHit
+		var id int64
+		i := &hit.Item
+		err := rows.Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit,
+			&i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt,
+			&hit.SectionName, &id, &listName, &icon, &sortOrder)
+		if err != nil {
+			return err
+		}
+		scanList(id)
+		list.Items = append(list.Items, hit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lists {
+		results.Lists = append(results.Lists, *l)
+	}
+	sort.Slice(results.Lists, func(i, j int) bool {
+		return results.Lists[i].sortOrder < results.Lists[j].sortOrder
+	})
+
+	if !withTemplates {
+		return results, nil
+	}
+
+	templates := make(map[int64]*TemplateSearchResult)
+	var template *TemplateSearchResult
+	var name, description string
+	scanTemplate := func(id int64) {
+		if templates[id] == nil {
+			templates[id] = &TemplateSearchResult{
+				ID: id, Name: name, Description: description, sortOrder: sortOrder,
+				Items: []TemplateItem{},
+			}
+		}
+		template = templates[id]
+	}
+	householdOnly := []interface{}{householdID}
+
+	// Templates by name and description
+	q = newSearchQuery("templates", "t", []string{"name", "description"}, terms)
+	err = eachRow(`
+		SELECT t.id, t.name, COALESCE(t.description, ''), t.sort_order FROM templates t `+q.join+`
+		WHERE t.household_id = ? AND `+q.where+`
+		ORDER BY `+q.order+` LIMIT ?
+	`, q.bind(householdOnly, limit), func(rows *sql.Rows) error {
+		var id int64
+		if err := rows.Scan(&id, &name, &description, &sortOrder); err != nil {
+			return err
+		}
+		scanTemplate(id)
+		template.NameMatched = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Template items by name and description
+	q = newSearchQuery("template_items", "ti", []string{"name", "description"}, terms)
+	err = eachRow(`
+		SELECT ti.id, ti.template_id, ti.section_name, ti.name, COALESCE(ti.description, ''), COALESCE(ti.quantity, 0),
+			COALESCE(ti.unit, ''), ti.sort_order, ti.created_at, t.name, COALESCE(t.description, ''), t.sort_order
+		FROM template_items ti JOIN templates t ON t.id = ti.template_id `+q.join+`
+		WHERE t.household_id = ? AND `+q.where+`
+		ORDER BY `+q.order+` LIMIT ?
+	`, q.bind(householdOnly, limit), func(rows *sql.Rows) error {
+		var ti TemplateItem
+		err := rows.Scan(&ti.ID, &ti.TemplateID, &ti.SectionName, &ti.Name, &ti.Description, &ti.Quantity,
+			&ti.Unit, &ti.SortOrder, &ti.CreatedAt, &name, &description, &sortOrder)
+		if err != nil {
+			return err
+		}
+		scanTemplate(ti.TemplateID)
+		template.Items = append(template.Items, ti)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range templates {
+		results.Templates = append(results.Templates, *t)
+	}
+	sort.Slice(results.Templates, func(i, j int) bool {
+		return results.Templates[i].sortOrder < results.Templates[j].sortOrder
+	})
+
+	return results, nil
+}
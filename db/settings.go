@@ -0,0 +1,47 @@
+package db
+
+// Setting keys
+const (
+	SettingAppPasswordHash = "app_password_hash" // bcrypt hash of the shared login password
+)
+
+// ==================== SETTINGS ====================
+
+// GetSetting returns a setting's value; sql.ErrNoRows when it was never set
+func GetSetting(key string) (string, error) {
+	var value string
+	err := DB.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	return value, err
+}
+
+// SetSetting creates or replaces a setting
+func SetSetting(key, value string) error {
+	_, err := DB.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, strftime('%s', 'now'))
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value)
+	return err
+}
+
+// SetAppPasswordHash replaces the shared login password and logs everyone out
+func SetAppPasswordHash(hash string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, strftime('%s', 'now'))
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, SettingAppPasswordHash, hash)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// Setting keys used by the change log
+const (
+	SettingChangeLogPrunedSeq = "change_log_pruned_seq" // highest seq removed by PruneChangeLog
+)
+
+// ChangeLogRetention is how long change log entries are kept. Clients whose
+// cursor is older get a full snapshot instead of a delta.
+const ChangeLogRetention = 30 * 24 * time.Hour
+
+// SyncDelta is the result of a delta sync. With Reset set it is a full
+// snapshot of the household and the client should replace its cache.
+type SyncDelta struct {
+	Cursor   int64          `json:"cursor"`
+	Reset    bool           `json:"reset"`
+	Lists    []List         `json:"lists"`
+	Sections []Section      `json:"sections"` // without nested items
+	Items    []Item         `json:"items"`
+	Deleted  SyncTombstones `json:"deleted"`
+}
+
+// SyncTombstones holds the IDs deleted since the cursor
+type SyncTombstones struct {
+	Lists    []int64 `json:"lists"`
+	Sections []int64 `json:"sections"`
+	Items    []int64 `json:"items"`
+}
+
+// ==================== SYNC ====================
+
+// GetSyncCursor returns the current change sequence
+func GetSyncCursor() (int64, error) {
+	return syncCursor(DB)
+}
+
+func syncCursor(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}) (int64, error) {
+	var cursor int64
+	err := q.QueryRow(`SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'change_log'), 0)`).Scan(&cursor)
+	return cursor, err
+}
+
+// GetSyncDelta returns the household's lists, sections and items changed after
+// since, plus tombstones for the deleted ones. since = 0, a cursor from before
+// the retained log or one ahead of the server yields a full snapshot.
+func GetSyncDelta(householdID, since int64) (*SyncDelta, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	delta := &SyncDelta{
+		Lists:    []List{},
+		Sections: []Section{},
+		Items:    []Item{},
+		Deleted:  SyncTombstones{Lists: []int64{}, Sections: []int64{}, Items: []int64{}},
+	}
+	if delta.Cursor, err = syncCursor(tx); err != nil {
+		return nil, err
+	}
+
+	var pruned int64
+	var value string
+	err = tx.QueryRow(`SELECT value FROM settings WHERE key = ?`, SettingChangeLogPrunedSeq).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if value != "" {
+		pruned, _ = strconv.ParseInt(value, 10, 64)
+	}
+
+	delta.Reset = since <= 0 || since < pruned || since > delta.Cursor
+	if delta.Reset {
+		err = fillSyncSnapshot(tx, delta, householdID)
+	} else {
+		err = fillSyncChanges(tx, delta, householdID, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i := range delta.Lists {
+		delta.Lists[i].Stats = GetListStats(delta.Lists[i].ID)
+	}
+	return delta, nil
+}
+
+// changedIDs selects the IDs of one entity type logged in (since, cursor]
+const changedIDs = `SELECT entity_id FROM change_log WHERE household_id = ? AND entity = ? AND seq > ? AND seq <= ?`
+
+const (
+	syncListColumns    = `SELECT l.id, l.name, COALESCE(l.icon, '🛒'), COALESCE(l.duplicate_mode, 'merge'), l.sort_order, l.is_active, l.created_at, COALESCE(l.updated_at, 0) FROM lists l`
+	syncSectionColumns = `SELECT s.id, s.list_id, s.name, s.sort_order, s.created_at, COALESCE(s.updated_at, 0) FROM sections s JOIN lists l ON l.id = s.list_id`
+	syncItemColumns    = `SELECT i.id, i.section_id, i.name, i.description, COALESCE(i.quantity, 0), COALESCE(i.unit, ''), i.completed, i.uncertain, i.sort_order, i.created_at, COALESCE(i.updated_at, 0), COALESCE(i.recur_days, 0), COALESCE(i.recurs_at, 0) FROM items i JOIN sections s ON s.id = i.section_id JOIN lists l ON l.id = s.list_id`
+)
+
+func fillSyncSnapshot(tx *sql.Tx, delta *SyncDelta, householdID int64) error {
+	var err error
+	if delta.Lists, err = syncLists(tx, syncListColumns+` WHERE l.household_id = ? ORDER BY l.sort_order`, householdID); err != nil {
+		return err
+	}
+	if delta.Sections, err = syncSections(tx, syncSectionColumns+` WHERE l.household_id = ? ORDER BY s.list_id, s.sort_order`, householdID); err != nil {
+		return err
+	}
+	delta.Items, err = syncItems(tx, syncItemColumns+` WHERE l.household_id = ? ORDER BY i.section_id, i.completed, i.sort_order`, householdID)
+	return err
+}
+
+func fillSyncChanges(tx *sql.Tx, delta *SyncDelta, householdID, since int64) error {
+	var err error
+	args := func(entity string) []interface{} {
+		return []interface{}{householdID, householdID, entity, since, delta.Cursor}
+	}
+
+	if delta.Lists, err = syncLists(tx, syncListColumns+` WHERE l.household_id = ? AND l.id IN (`+changedIDs+`)`, args("list")...); err != nil {
+		return err
+	}
+	if delta.Sections, err = syncSections(tx, syncSectionColumns+` WHERE l.household_id = ? AND s.id IN (`+changedIDs+`)`, args("section")...); err != nil {
+		return err
+	}
+	if delta.Items, err = syncItems(tx, syncItemColumns+` WHERE l.household_id = ? AND i.id IN (`+changedIDs+`)`, args("item")...); err != nil {
+		return err
+	}
+
+	// Tombstones: logged IDs that no longer exist in the household
+	if delta.Deleted.Lists, err = syncIDs(tx, `SELECT DISTINCT entity_id FROM change_log WHERE household_id = ? AND entity = 'list' AND seq > ? AND seq <= ?
+		AND entity_id NOT IN (SELECT id FROM lists WHERE household_id = ?)`, householdID, since, delta.Cursor, householdID); err != nil {
+		return err
+	}
+	if delta.Deleted.Sections, err = syncIDs(tx, `SELECT DISTINCT entity_id FROM change_log WHERE household_id = ? AND entity = 'section' AND seq > ? AND seq <= ?
+		AND entity_id NOT IN (SELECT s.id FROM sections s JOIN lists l ON l.id = s.list_id WHERE l.household_id = ?)`, householdID, since, delta.Cursor, householdID); err != nil {
+		return err
+	}
+	delta.Deleted.Items, err = syncIDs(tx, `SELECT DISTINCT entity_id FROM change_log WHERE household_id = ? AND entity = 'item' AND seq > ? AND seq <= ?
+		AND entity_id NOT IN (SELECT i.id FROM items i JOIN sections s ON s.id = i.section_id JOIN lists l ON l.id = s.list_id WHERE l.household_id = ?)`, householdID, since, delta.Cursor, householdID)
+	return err
+}
+
+func syncLists(tx *sql.Tx, query string, args ...interface{}) ([]List, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []List{}
+	for rows.Next() {
+		var l List
+		if err := rows.Scan(&l.ID, &l.Name, &l.Icon, &l.DuplicateMode, &l.SortOrder, &l.IsActive, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, rows.Err()
+}
+
+func syncSections(tx *sql.Tx, query string, args ...interface{}) ([]Section, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sections := []Section{}
+	for rows.Next() {
+		var s Section
+		if err := rows.Scan(&s.ID, &s.ListID, &s.Name, &s.SortOrder, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sections = append(sections, s)
+	}
+	return sections, rows.Err()
+}
+
+func syncItems(tx *sql.Tx, query string, args ...interface{}) ([]Item, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var i Item
+		if err := rows.Scan(&i.ID, &i.SectionID, &i.Name, &i.Description, &i.Quantity, &i.Unit, &i.Completed, &i.Uncertain, &i.SortOrder, &i.CreatedAt, &i.UpdatedAt, &i.RecurDays, &i.RecursAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func syncIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PruneChangeLog drops entries older than ChangeLogRetention and remembers
+// the highest dropped seq, so older cursors get a full snapshot
+func PruneChangeLog() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cutoff := time.Now().Add(-ChangeLogRetention).Unix()
+	var maxSeq sql.NullInt64
+	if err := tx.QueryRow(`SELECT MAX(seq) FROM change_log WHERE changed_at < ?`, cutoff).Scan(&maxSeq); err != nil {
+		return err
+	}
+	if !maxSeq.Valid {
+		return nil // Nothing to prune
+	}
+
+	if _, err := tx.Exec(`DELETE FROM change_log WHERE seq <= ?`, maxSeq.Int64); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, strftime('%s', 'now'))
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, SettingChangeLogPrunedSeq, strconv.FormatInt(maxSeq.Int64, 10))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package db
+
+import "testing"
+
+func TestScaleQuantity(t *testing.T) {
+	tests := []struct {
+		quantity   float64
+		unit       string
+		multiplier float64
+		want       float64
+	}{
+		{2, "", 0, 2}, // 0 means 1
+		{2, "", 1, 2},
+		{0, "g", 5, 0}, // no quantity stays none
+		// Counted units round up to whole pieces
+		{1.5, "", 8, 12},
+		{1.6, "can", 8, 13},
+		{0.3, "pcs", 3, 1},
+		{1, "pcs", 3, 3},
+		{2, "PCS", 1.5, 3},
+		// Measured amounts round to two decimals
+		{250, "g", 1.5, 375},
+		{0.333, "kg", 3, 1},
+		{0.125, "l", 3, 0.38},
+		{1, "ml", 0.5, 0.5},
+	}
+	for _, tt := range tests {
+		if got := ScaleQuantity(tt.quantity, tt.unit, tt.multiplier); got != tt.want {
+			t.Errorf("ScaleQuantity(%v, %q, %v) = %v, want %v", tt.quantity, tt.unit, tt.multiplier, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTemplatePicksItems(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Party", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := CreateTemplate(DefaultHouseholdID, "Per person", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eggs, err := AddTemplateItem(template.ID, "Dairy", "Eggs", "", 1.5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTemplateItem(template.ID, "Dairy", "Milk", "", 0.25, "l"); err != nil {
+		t.Fatal(err)
+	}
+	flour, err := AddTemplateItem(template.ID, "Bakery", "Flour", "", 120, "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ApplyTemplateOptions{Multiplier: 8, ItemIDs: []int64{eggs.ID, flour.ID}}
+	if err := ApplyTemplateToList(template.ID, list.ID, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := GetSectionsByList(list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, section := range sections {
+		for _, item := range section.Items {
+			got[section.Name+"/"+item.Name] = item.Quantity
+		}
+	}
+	want := map[string]float64{"Dairy/Eggs": 12, "Bakery/Flour": 960}
+	if len(got) != len(want) {
+		t.Fatalf("list items = %v, want %v", got, want)
+	}
+	for key, quantity := range want {
+		if got[key] != quantity {
+			t.Errorf("%s quantity = %v, want %v", key, got[key], quantity)
+		}
+	}
+}
+
+func TestApplyTemplateSectionNamesIgnoreCase(t *testing.T) {
+	setupTestDB(t)
+	list, err := CreateList(DefaultHouseholdID, "Weekly", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := CreateTemplate(DefaultHouseholdID, "Basics", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTemplateItem(template.ID, "Dairy", "Milk", "", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTemplateItem(template.ID, "dairy", "Butter", "", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyTemplateToList(template.ID, list.ID, ApplyTemplateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := GetSectionsByList(list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 1 || len(sections[0].Items) != 2 {
+		t.Fatalf("got %d sections, want one Dairy section with both items", len(sections))
+	}
+}
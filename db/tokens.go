@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// API token scopes
+const (
+	ScopeListsRead      = "lists:read"
+	ScopeListsWrite     = "lists:write"
+	ScopeSectionsRead   = "sections:read"
+	ScopeSectionsWrite  = "sections:write"
+	ScopeItemsRead      = "items:read"
+	ScopeItemsWrite     = "items:write"
+	ScopeHistoryRead    = "history:read"
+	ScopeHistoryWrite   = "history:write"
+	ScopeTemplatesRead  = "templates:read"
+	ScopeTemplatesWrite = "templates:write"
+	ScopeTokensManage   = "tokens:manage"
+	ScopeSettingsWrite  = "settings:write"
+	ScopeWebhooksManage = "webhooks:manage"
+)
+
+// AllScopes lists every scope a token can be granted, in display order
+var AllScopes = []string{
+	ScopeListsRead, ScopeListsWrite,
+	ScopeSectionsRead, ScopeSectionsWrite,
+	ScopeItemsRead, ScopeItemsWrite,
+	ScopeHistoryRead, ScopeHistoryWrite,
+	ScopeTemplatesRead, ScopeTemplatesWrite,
+	ScopeTokensManage, ScopeSettingsWrite,
+	ScopeWebhooksManage,
+}
+
+// IsValidScope reports whether scope is a known API token scope
+func IsValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken is a named API credential; only a hash of the secret is stored
+type APIToken struct {
+	ID          int64     `json:"id"`
+	HouseholdID int64     `json:"-"`
+	Name        string    `json:"name"`
+	Prefix      string    `json:"prefix"`
+	Scopes      []string  `json:"scopes"`
+	ListID      int64     `json:"list_id,omitempty"`   // 0 means all lists of the household
+	ListName    string    `json:"list_name,omitempty"` // name of the restricted list
+	ExpiresAt   int64     `json:"expires_at,omitempty"`
+	LastUsedAt  int64     `json:"last_used_at,omitempty"`
+	CreatedBy   int64     `json:"-"` // user who minted the token, 0 for the shared password
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the token has passed its expiry time
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != 0 && t.ExpiresAt <= time.Now().Unix()
+}
+
+// ==================== API TOKENS ====================
+
+const apiTokenColumns = `
+	t.id, t.household_id, t.name, t.token_prefix, t.scopes, COALESCE(t.list_id, 0), COALESCE(l.name, ''),
+	COALESCE(t.expires_at, 0), COALESCE(t.last_used_at, 0), COALESCE(t.created_by, 0), t.created_at
+	FROM api_tokens t LEFT JOIN lists l ON l.id = t.list_id`
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	err := row.Scan(&t.ID, &t.HouseholdID, &t.Name, &t.Prefix, &scopes, &t.ListID, &t.ListName, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedBy, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return &t, nil
+}
+
+// GetAPITokens returns the household's API tokens, newest first
+func GetAPITokens(householdID int64) ([]APIToken, error) {
+	rows, err := DB.Query(`SELECT `+apiTokenColumns+` WHERE t.household_id = ? ORDER BY t.id DESC`, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, nil
+}
+
+// GetAPITokenByID returns a single API token by ID
+func GetAPITokenByID(id int64) (*APIToken, error) {
+	return scanAPIToken(DB.QueryRow(`SELECT `+apiTokenColumns+` WHERE t.id = ?`, id))
+}
+
+// GetAPITokenByHash looks up the token whose secret hashes to hash
+func GetAPITokenByHash(hash string) (*APIToken, error) {
+	return scanAPIToken(DB.QueryRow(`SELECT `+apiTokenColumns+` WHERE t.token_hash = ?`, hash))
+}
+
+// CreateAPIToken stores a new token; listID and expiresAt are 0 when
+// unrestricted, createdBy is 0 for a token minted with the shared password
+func CreateAPIToken(householdID int64, name, hash, prefix string, scopes []string, listID, expiresAt, createdBy int64) (*APIToken, error) {
+	var list, expires, creator interface{}
+	if listID != 0 {
+		list = listID
+	}
+	if createdBy != 0 {
+		creator = createdBy
+	}
+	if expiresAt != 0 {
+		expires = expiresAt
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO api_tokens (household_id, name, token_hash, token_prefix, scopes, list_id, expires_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, householdID, name, hash, prefix, strings.Join(scopes, " "), list, expires, creator)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetAPITokenByID(id)
+}
+
+// DeleteAPIToken revokes one of the household's tokens; sql.ErrNoRows if there is none
+func DeleteAPIToken(householdID, id int64) error {
+	result, err := DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND household_id = ?`, id, householdID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchAPIToken records that a token was used, at most once a minute
+func TouchAPIToken(id int64) error {
+	now := time.Now().Unix()
+	_, err := DB.Exec(`
+		UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND COALESCE(last_used_at, 0) < ?
+	`, now, id, now-60)
+	return err
+}
@@ -0,0 +1,109 @@
+package db
+
+import "time"
+
+// User represents an account that can log in
+type User struct {
+	ID            int64     `json:"id"`
+	Username      string    `json:"username"`
+	PasswordHash  string    `json:"-"`
+	HouseholdID   int64     `json:"household_id"`
+	HouseholdName string    `json:"household_name"`
+	IsAdmin       bool      `json:"is_admin"`
+	Disabled      bool      `json:"disabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     int64     `json:"updated_at"`
+}
+
+// ==================== USERS ====================
+
+// GetAllUsers returns all user accounts
+func GetAllUsers() ([]User, error) {
+	rows, err := DB.Query(`
+		SELECT u.id, u.username, u.password_hash, COALESCE(u.household_id, 1), COALESCE(h.name, ''), u.is_admin, u.disabled, u.created_at, COALESCE(u.updated_at, 0)
+		FROM users u LEFT JOIN households h ON h.id = u.household_id
+		ORDER BY u.username COLLATE NOCASE ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.HouseholdID, &u.HouseholdName, &u.IsAdmin, &u.Disabled, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// GetUserByID returns a single user by ID
+func GetUserByID(id int64) (*User, error) {
+	var u User
+	err := DB.QueryRow(`
+		SELECT u.id, u.username, u.password_hash, COALESCE(u.household_id, 1), COALESCE(h.name, ''), u.is_admin, u.disabled, u.created_at, COALESCE(u.updated_at, 0)
+		FROM users u LEFT JOIN households h ON h.id = u.household_id WHERE u.id = ?
+	`, id).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.HouseholdID, &u.HouseholdName, &u.IsAdmin, &u.Disabled, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserByUsername returns a user by username (case-insensitive)
+func GetUserByUsername(username string) (*User, error) {
+	var u User
+	err := DB.QueryRow(`
+		SELECT u.id, u.username, u.password_hash, COALESCE(u.household_id, 1), COALESCE(h.name, ''), u.is_admin, u.disabled, u.created_at, COALESCE(u.updated_at, 0)
+		FROM users u LEFT JOIN households h ON h.id = u.household_id WHERE u.username = ?
+	`, username).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.HouseholdID, &u.HouseholdName, &u.IsAdmin, &u.Disabled, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateUser creates a user in a household with an already hashed password
+func CreateUser(householdID int64, username, passwordHash string, isAdmin bool) (*User, error) {
+	result, err := DB.Exec(`
+		INSERT INTO users (household_id, username, password_hash, is_admin) VALUES (?, ?, ?, ?)
+	`, householdID, username, passwordHash, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetUserByID(id)
+}
+
+// SetUserDisabled enables or disables a user; disabling also ends their sessions
+func SetUserDisabled(id int64, disabled bool) (*User, error) {
+	_, err := DB.Exec(`UPDATE users SET disabled = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, disabled, id)
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		if err := DeleteUserSessions(id); err != nil {
+			return nil, err
+		}
+	}
+	return GetUserByID(id)
+}
+
+// CountActiveUsers returns the number of enabled accounts
+func CountActiveUsers() int {
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM users WHERE disabled = FALSE").Scan(&count)
+	return count
+}
+
+// CountActiveAdmins returns the number of enabled admin accounts
+func CountActiveAdmins() int {
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM users WHERE disabled = FALSE AND is_admin = TRUE").Scan(&count)
+	return count
+}
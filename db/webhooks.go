@@ -0,0 +1,274 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // waiting for its first attempt or a retry
+	DeliverySucceeded = "succeeded" // the endpoint answered 2xx
+	DeliveryFailed    = "failed"    // out of retries
+)
+
+// WebhookDeliveryRetention is how long the delivery log is kept
+const WebhookDeliveryRetention = 7 * 24 * time.Hour
+
+// Webhook is an endpoint notified of the household's events. The secret signs
+// each delivery and is only shown when the webhook is created.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	HouseholdID int64     `json:"-"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	Events      []string  `json:"events"`            // event types to send, empty for all
+	ListID      int64     `json:"list_id,omitempty"` // only events of this list, 0 for all
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Matches reports whether the webhook wants an event of eventType about listID
+func (w *Webhook) Matches(listID int64, eventType string) bool {
+	if !w.Active || (w.ListID != 0 && w.ListID != listID) {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent (or to be sent) to a webhook
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"` // the event as sent
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"` // HTTP status of the last attempt
+	Error          string          `json:"error,omitempty"`           // why the last attempt failed
+	NextAttemptAt  int64           `json:"next_attempt_at,omitempty"`
+	DeliveredAt    int64           `json:"delivered_at,omitempty"`
+	CreatedAt      int64           `json:"created_at"`
+
+	// Target, filled in by GetDueWebhookDeliveries
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// ==================== WEBHOOKS ====================
+
+const webhookColumns = `id, household_id, url, secret, events, COALESCE(list_id, 0), active, created_at FROM webhooks`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*Webhook, error) {
+	var w Webhook
+	var events string
+	err := row.Scan(&w.ID, &w.HouseholdID, &w.URL, &w.Secret, &events, &w.ListID, &w.Active, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	w.Events = strings.Fields(events)
+	return &w, nil
+}
+
+// nullableID stores 0 as NULL
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// GetWebhooks returns the household's webhooks, oldest first
+func GetWebhooks(householdID int64) ([]Webhook, error) {
+	rows, err := DB.Query(`SELECT `+webhookColumns+` WHERE household_id = ? ORDER BY id ASC`, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhookByID returns a single webhook by ID
+func GetWebhookByID(id int64) (*Webhook, error) {
+	return scanWebhook(DB.QueryRow(`SELECT `+webhookColumns+` WHERE id = ?`, id))
+}
+
+// CreateWebhook stores a new webhook; listID is 0 for all lists
+func CreateWebhook(householdID int64, url, secret string, events []string, listID int64, active bool) (*Webhook, error) {
+	result, err := DB.Exec(`
+		INSERT INTO webhooks (household_id, url, secret, events, list_id, active)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, householdID, url, secret, strings.Join(events, " "), nullableID(listID), active)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetWebhookByID(id)
+}
+
+// UpdateWebhook replaces a webhook's target and filters; the secret is kept
+func UpdateWebhook(id int64, url string, events []string, listID int64, active bool) (*Webhook, error) {
+	_, err := DB.Exec(`
+		UPDATE webhooks SET url = ?, events = ?, list_id = ?, active = ? WHERE id = ?
+	`, url, strings.Join(events, " "), nullableID(listID), active, id)
+	if err != nil {
+		return nil, err
+	}
+	return GetWebhookByID(id)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(id int64) error {
+	_, err := DB.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	return err
+}
+
+// ==================== WEBHOOK DELIVERIES ====================
+
+// QueueWebhookDeliveries records a pending delivery of payload to each of the
+// household's active webhooks matching the event, and returns how many
+func QueueWebhookDeliveries(householdID, listID int64, eventType, payload string) (int, error) {
+	rows, err := DB.Query(`SELECT `+webhookColumns+` WHERE household_id = ? AND active = TRUE`, householdID)
+	if err != nil {
+		return 0, err
+	}
+	var targets []int64
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if w.Matches(listID, eventType) {
+			targets = append(targets, w.ID)
+		}
+	}
+	rows.Close()
+	if len(targets) == 0 {
+		return 0, nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, webhookID := range targets {
+		_, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, webhookID, eventType, payload, DeliveryPending, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(targets), tx.Commit()
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts,
+	COALESCE(d.response_status, 0), COALESCE(d.error, ''), COALESCE(d.next_attempt_at, 0),
+	COALESCE(d.delivered_at, 0), d.created_at`
+
+func scanDeliveries(rows *sql.Rows, withTarget bool) ([]WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		dest := []interface{}{&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.Error, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt}
+		if withTarget {
+			dest = append(dest, &d.URL, &d.Secret)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDeliveries returns a webhook's most recent deliveries, newest first
+func GetWebhookDeliveries(webhookID int64, limit int) ([]WebhookDelivery, error) {
+	rows, err := DB.Query(`
+		SELECT `+deliveryColumns+` FROM webhook_deliveries d
+		WHERE d.webhook_id = ? ORDER BY d.id DESC LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows, false)
+}
+
+// GetDueWebhookDeliveries returns up to limit pending deliveries whose next
+// attempt is due, oldest first, with their webhook's URL and secret.
+// Deliveries of a deactivated webhook wait until it is reactivated.
+func GetDueWebhookDeliveries(limit int) ([]WebhookDelivery, error) {
+	rows, err := DB.Query(`
+		SELECT `+deliveryColumns+`, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = TRUE
+		ORDER BY d.id ASC LIMIT ?
+	`, DeliveryPending, time.Now().Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows, true)
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. status is
+// DeliveryPending with nextAttemptAt set when it will be retried.
+func RecordWebhookAttempt(id int64, status string, responseStatus int, errMsg string, nextAttemptAt int64) error {
+	var delivered, next, response, errText interface{}
+	if status == DeliverySucceeded {
+		delivered = time.Now().Unix()
+	}
+	if nextAttemptAt != 0 {
+		next = nextAttemptAt
+	}
+	if responseStatus != 0 {
+		response = responseStatus
+	}
+	if errMsg != "" {
+		errText = errMsg
+	}
+
+	_, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, response_status = ?, error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?
+	`, status, response, errText, next, delivered, id)
+	return err
+}
+
+// PruneWebhookDeliveries drops log entries older than WebhookDeliveryRetention
+// that are no longer pending
+func PruneWebhookDeliveries() error {
+	cutoff := time.Now().Add(-WebhookDeliveryRetention).Unix()
+	_, err := DB.Exec(`DELETE FROM webhook_deliveries WHERE created_at < ? AND status != ?`, cutoff, DeliveryPending)
+	return err
+}
@@ -7,6 +7,7 @@ require (
 	github.com/gofiber/template/html/v2 v2.1.2
 	github.com/gofiber/websocket/v2 v2.2.1
 	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/crypto v0.17.0
 )
 
 require (
@@ -2,23 +2,51 @@ package handlers
 
 import (
 	"shopping-list/db"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// GetAllData returns all sections with items and stats for offline caching
+// GetAllData returns all sections with items and stats for offline caching.
+// The cursor is read first so a following /api/sync can't miss a change.
 func GetAllData(c *fiber.Ctx) error {
-	sections, err := db.GetAllSections()
+	cursor, err := db.GetSyncCursor()
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch data"})
 	}
 
-	stats := db.GetStats()
+	sections, err := db.GetAllSections(HouseholdID(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch data"})
+	}
+
+	stats := db.GetStats(HouseholdID(c))
 
 	return c.JSON(fiber.Map{
 		"sections":  sections,
 		"stats":     stats,
 		"timestamp": time.Now().Unix(),
+		"cursor":    cursor,
 	})
 }
+
+// GetSync returns the lists, sections and items changed since the cursor in
+// ?since=, with tombstones for deletions. Without a usable cursor the response
+// is a full snapshot with "reset": true.
+func GetSync(c *fiber.Ctx) error {
+	var since int64
+	if v := c.Query("since"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+		since = parsed
+	}
+
+	delta, err := db.GetSyncDelta(HouseholdID(c), since)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch changes"})
+	}
+	return c.JSON(delta)
+}
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
 	"log"
 	"os"
 	"shopping-list/db"
 	"shopping-list/i18n"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -17,6 +20,13 @@ const (
 	SessionDuration   = 7 * 24 * time.Hour // 7 days
 )
 
+// Keys under which AuthMiddleware stores the logged-in user in fiber.Ctx locals
+const (
+	LocalsUserID      = "user_id"
+	LocalsUser        = "user"
+	LocalsHouseholdID = "household_id"
+)
+
 func getAppPassword() string {
 	pass := os.Getenv("APP_PASSWORD")
 	if pass == "" {
@@ -25,6 +35,41 @@ func getAppPassword() string {
 	return pass
 }
 
+// InitAppPassword stores a hash of APP_PASSWORD on first start. From then on
+// the stored hash is used and the password is changed in Settings or via the API.
+func InitAppPassword() {
+	hash, err := db.GetSetting(db.SettingAppPasswordHash)
+	if err == nil {
+		if env := os.Getenv("APP_PASSWORD"); env != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(env)) != nil {
+			log.Println("APP_PASSWORD differs from the stored password and is ignored - change the password in Settings")
+		}
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Fatal("Failed to load app password:", err)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(getAppPassword()), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatal("Failed to hash APP_PASSWORD (max 72 bytes):", err)
+	}
+	if err := db.SetSetting(db.SettingAppPasswordHash, string(hashed)); err != nil {
+		log.Fatal("Failed to store app password:", err)
+	}
+	log.Println("App password initialized from APP_PASSWORD")
+}
+
+// checkAppPassword compares a password against the stored hash in constant time
+func checkAppPassword(password string) bool {
+	hash, err := db.GetSetting(db.SettingAppPasswordHash)
+	if err != nil {
+		log.Printf("[AUTH] Failed to load app password: %v", err)
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
 func isAuthDisabled() bool {
 	return os.Getenv("DISABLE_AUTH") == "true"
 }
@@ -48,6 +93,57 @@ func generateSessionID() string {
 	return hex.EncodeToString(bytes)
 }
 
+// CurrentUserID returns the ID of the logged-in user, or 0 when the session has no account
+func CurrentUserID(c *fiber.Ctx) int64 {
+	if id, ok := c.Locals(LocalsUserID).(int64); ok {
+		return id
+	}
+	return 0
+}
+
+// CurrentUser returns the logged-in user, or nil when the session has no account
+func CurrentUser(c *fiber.Ctx) *db.User {
+	if user, ok := c.Locals(LocalsUser).(*db.User); ok {
+		return user
+	}
+	return nil
+}
+
+// HouseholdID returns the caller's household. Requests without a user account
+// (shared password, DISABLE_AUTH, the API token) use the default household.
+func HouseholdID(c *fiber.Ctx) int64 {
+	if id, ok := c.Locals(LocalsHouseholdID).(int64); ok {
+		return id
+	}
+	return db.DefaultHouseholdID
+}
+
+// dummyPasswordHash is compared against when the username doesn't exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("koffan-dummy-password"), bcrypt.DefaultCost)
+
+// authenticate checks login credentials. Once user accounts exist, a username is
+// required; until then the shared app password is used. It returns the user ID
+// for the session (0 for the shared password) and whether the login succeeded.
+func authenticate(username, password string) (int64, bool) {
+	if db.CountActiveUsers() == 0 {
+		return 0, checkAppPassword(password)
+	}
+
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		// Compare anyway so unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return 0, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return 0, false
+	}
+	if user.Disabled {
+		return 0, false
+	}
+	return user.ID, true
+}
+
 // LoginPage renders the login page
 func LoginPage(c *fiber.Ctx) error {
 	// Check if already logged in
@@ -60,6 +156,7 @@ func LoginPage(c *fiber.Ctx) error {
 	}
 	return c.Render("login", fiber.Map{
 		"Error":        c.Query("error"),
+		"MultiUser":    db.CountActiveUsers() > 0,
 		"Translations": i18n.GetAllLocales(),
 		"Locales":      i18n.AvailableLocales(),
 		"DefaultLang":  i18n.GetDefaultLang(),
@@ -69,9 +166,11 @@ func LoginPage(c *fiber.Ctx) error {
 // Login handles login form submission
 func Login(c *fiber.Ctx) error {
 	ip := c.IP()
+	username := strings.TrimSpace(c.FormValue("username"))
 	password := c.FormValue("password")
 
-	if password != getAppPassword() {
+	userID, ok := authenticate(username, password)
+	if !ok {
 		// Record failed attempt
 		if loginLimiter != nil {
 			if loginLimiter.RecordAttempt(ip) {
@@ -87,15 +186,23 @@ func Login(c *fiber.Ctx) error {
 		loginLimiter.ResetAttempts(ip)
 	}
 
-	// Create session
+	if err := startSession(c, userID); err != nil {
+		return c.Status(500).SendString("Session creation failed")
+	}
+
+	return c.Redirect("/")
+}
+
+// startSession creates a session for userID (0 for the shared password) and sets its cookie
+func startSession(c *fiber.Ctx, userID int64) error {
 	sessionID := generateSessionID()
 	expiresAt := time.Now().Add(SessionDuration).Unix()
 
-	err := db.CreateSession(sessionID, expiresAt)
+	err := db.CreateSession(sessionID, expiresAt, userID)
 	if err != nil {
-		return c.Status(500).SendString("Session creation failed")
+		return err
 	}
-	log.Printf("[AUTH] New session created: %s... (expires: %d)", sessionID[:8], expiresAt)
+	log.Printf("[AUTH] New session created: %s... (user: %d, expires: %d)", sessionID[:8], userID, expiresAt)
 
 	// Set cookie
 	c.Cookie(&fiber.Cookie{
@@ -107,8 +214,7 @@ func Login(c *fiber.Ctx) error {
 		SameSite: "Lax",
 		Path:     "/",
 	})
-
-	return c.Redirect("/")
+	return nil
 }
 
 // Logout handles logout
@@ -202,5 +308,63 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		return c.Redirect("/login")
 	}
 
+	if session.UserID != 0 {
+		user, err := db.GetUserByID(session.UserID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("[AUTH] Database error loading user for %s %s: %v", c.Method(), path, err)
+			return c.Status(503).SendString("Database temporarily unavailable, please retry")
+		}
+		if err == sql.ErrNoRows || user.Disabled {
+			log.Printf("[AUTH] User %d missing or disabled for %s %s", session.UserID, c.Method(), path)
+			db.DeleteSession(sessionID)
+			return endSession(c)
+		}
+		c.Locals(LocalsUserID, user.ID)
+		c.Locals(LocalsUser, user)
+		c.Locals(LocalsHouseholdID, user.HouseholdID)
+	} else if db.CountActiveUsers() > 0 {
+		// Shared-password sessions stop working once accounts exist
+		log.Printf("[AUTH] Anonymous session rejected for %s %s - user accounts are enabled", c.Method(), path)
+		db.DeleteSession(sessionID)
+		return endSession(c)
+	}
+
 	return c.Next()
 }
+
+// endSession clears the session cookie and sends the client to the login page
+func endSession(c *fiber.Ctx) error {
+	c.Cookie(&fiber.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   isSecureConnection(c),
+		SameSite: "Lax",
+		Path:     "/",
+	})
+	if c.Get("HX-Request") == "true" {
+		c.Set("HX-Redirect", "/login")
+		return c.SendStatus(401)
+	}
+	return c.Redirect("/login")
+}
+
+// AdminMiddleware allows only admins to manage accounts. Before the first
+// account exists, anyone logged in with the shared password may create it.
+func AdminMiddleware(c *fiber.Ctx) error {
+	if canManageUsers(c) {
+		return c.Next()
+	}
+	return c.Status(403).SendString("Admin access required")
+}
+
+func canManageUsers(c *fiber.Ctx) bool {
+	if isAuthDisabled() {
+		return true
+	}
+	if user := CurrentUser(c); user != nil {
+		return user.IsAdmin
+	}
+	return db.CountActiveUsers() == 0
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"shopping-list/db"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EventSchemaVersion is sent as "v" in every event. It changes when an event
+// is removed or its payload changes incompatibly; new event types and new
+// payload fields don't bump it.
+const EventSchemaVersion = 1
+
+// Event types and their payloads. List-index events (marked "index") go to
+// clients subscribed to the index and, when they carry a list_id, to that
+// list's subscribers too; the others go to the subscribers of their list.
+const (
+	EventListCreated    = "list_created"    // db.List with stats (index)
+	EventListUpdated    = "list_updated"    // db.List with stats (index)
+	EventListDeleted    = "list_deleted"    // {"id"} (index)
+	EventListActivated  = "list_activated"  // db.List with stats (index)
+	EventListsReordered = "lists_reordered" // {"lists": []db.List in the new order} (index)
+
+	EventSectionCreated    = "section_created"    // db.Section
+	EventSectionUpdated    = "section_updated"    // db.Section
+	EventSectionDeleted    = "section_deleted"    // {"id", "list_id"}; its items are gone too
+	EventSectionsDeleted   = "sections_deleted"   // {"ids", "list_id"}
+	EventSectionsReordered = "sections_reordered" // {"list_id", "sections": []db.Section with items, in the new order}
+
+	EventItemCreated           = "item_created"            // db.Item
+	EventItemUpdated           = "item_updated"            // db.Item (also merged duplicates and uncertain toggles)
+	EventItemToggled           = "item_toggled"            // db.Item
+	EventItemMoved             = "item_moved"              // db.Item, section_id is the new section
+	EventItemDeleted           = "item_deleted"            // {"id", "section_id"}
+	EventItemsReordered        = "items_reordered"         // {"section_id", "items": []db.Item in the new order}
+	EventCompletedItemsDeleted = "completed_items_deleted" // {"ids", "count"}
+	EventBatchCreated          = "batch_created"           // {"list" (new list only), "sections": []db.Section with items, "items": []db.Item}
+
+	EventTemplateCreated     = "template_created"      // db.Template with items (index)
+	EventTemplateUpdated     = "template_updated"      // db.Template with items (index)
+	EventTemplateDeleted     = "template_deleted"      // {"id"} (index)
+	EventTemplateItemCreated = "template_item_created" // db.TemplateItem (index)
+	EventTemplateItemUpdated = "template_item_updated" // db.TemplateItem (index)
+	EventTemplateItemDeleted = "template_item_deleted" // {"id", "template_id"} (index)
+	EventTemplateApplied     = "template_applied"      // {"template_id", "schedule_id" (scheduled runs only), "list": db.List, "sections": []db.Section with items} (index)
+
+	EventHistoryCreated = "history_created" // db.HistoryItem (index)
+	EventHistoryDeleted = "history_deleted" // {"ids"} (index)
+)
+
+// EventTypes lists every event type, for validating webhook filters
+var EventTypes = []string{
+	EventListCreated, EventListUpdated, EventListDeleted, EventListActivated, EventListsReordered,
+	EventSectionCreated, EventSectionUpdated, EventSectionDeleted, EventSectionsDeleted, EventSectionsReordered,
+	EventItemCreated, EventItemUpdated, EventItemToggled, EventItemMoved, EventItemDeleted, EventItemsReordered,
+	EventCompletedItemsDeleted, EventBatchCreated,
+	EventTemplateCreated, EventTemplateUpdated, EventTemplateDeleted,
+	EventTemplateItemCreated, EventTemplateItemUpdated, EventTemplateItemDeleted, EventTemplateApplied,
+	EventHistoryCreated, EventHistoryDeleted,
+}
+
+// IsEventType reports whether t is a known event type
+func IsEventType(t string) bool {
+	for _, eventType := range EventTypes {
+		if eventType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the envelope of every message pushed to clients. Seq increases by
+// one per event of the household; together with Epoch it lets a client resume
+// after a disconnect (the "subscribe" message in ws.go, Last-Event-ID in sse.go).
+type Event struct {
+	Version int         `json:"v"`
+	Type    string      `json:"type"`
+	Seq     int64       `json:"seq"`
+	Epoch   int64       `json:"epoch"`
+	ListID  int64       `json:"list_id,omitempty"` // list the event belongs to; 0 for index-only events
+	Actor   EventActor  `json:"actor"`
+	Time    int64       `json:"ts"` // unix seconds
+	Data    interface{} `json:"data"`
+}
+
+// Actor types
+const (
+	ActorUser    = "user"    // a signed-in user account
+	ActorSession = "session" // the shared app password or DISABLE_AUTH
+	ActorToken   = "token"   // an API token
+	ActorSystem  = "system"  // the server itself
+)
+
+// EventActor identifies who made a change
+type EventActor struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// LocalsActor is the fiber.Ctx locals key for an EventActor set by
+// authentication that isn't a user session (API tokens)
+const LocalsActor = "actor"
+
+// SystemActor is the actor of changes the server makes on its own
+var SystemActor = EventActor{Type: ActorSystem}
+
+// ActorFromContext returns who is making the request
+func ActorFromContext(c *fiber.Ctx) EventActor {
+	if actor, ok := c.Locals(LocalsActor).(EventActor); ok {
+		return actor
+	}
+	if user := CurrentUser(c); user != nil {
+		return EventActor{Type: ActorUser, ID: user.ID, Name: user.Username}
+	}
+	return EventActor{Type: ActorSession}
+}
+
+// eventEpoch identifies this server run; sequence numbers restart with it
+var eventEpoch = time.Now().UnixMilli()
+
+// eventBufferSize is how many recent events are kept per household for
+// clients resuming after a disconnect
+const eventBufferSize = 256
+
+// householdEvents tracks a household's last sequence number, overall and per
+// channel, and its most recent events
+type householdEvents struct {
+	seq    int64
+	index  int64           // seq of the last index event
+	lists  map[int64]int64 // seq of the last event per list
+	recent []bufferedEvent // oldest first, at most eventBufferSize
+}
+
+// bufferedEvent is an encoded event kept for replay
+type bufferedEvent struct {
+	seq    int64
+	listID int64
+	index  bool
+	data   []byte
+}
+
+// Event sequence state, guarded by eventsMu. eventsMu is held from assigning
+// a seq until the event is queued, so clients receive events in seq order.
+var (
+	events   = make(map[int64]*householdEvents)
+	eventsMu sync.Mutex
+)
+
+// BroadcastUpdate sends an event about a list's contents (sections, items) to
+// the household's clients subscribed to that list
+func BroadcastUpdate(c *fiber.Ctx, listID int64, eventType string, data interface{}) {
+	PublishEvent(HouseholdID(c), ActorFromContext(c), listID, false, eventType, data)
+}
+
+// BroadcastIndexUpdate sends a list-index event (lists, templates, history) to
+// the household's clients subscribed to the index, and to the subscribers of
+// listID when the event concerns a single list
+func BroadcastIndexUpdate(c *fiber.Ctx, listID int64, eventType string, data interface{}) {
+	PublishEvent(HouseholdID(c), ActorFromContext(c), listID, true, eventType, data)
+}
+
+// BroadcastSectionUpdate is BroadcastUpdate for an event about a section
+func BroadcastSectionUpdate(c *fiber.Ctx, sectionID int64, eventType string, data interface{}) {
+	listID, _ := db.SectionListID(sectionID)
+	BroadcastUpdate(c, listID, eventType, data)
+}
+
+// PublishEvent numbers an event, delivers it to the subscribed clients and
+// queues it for the household's webhooks
+func PublishEvent(householdID int64, actor EventActor, listID int64, index bool, eventType string, data interface{}) {
+	if message := sendEvent(householdID, actor, listID, index, eventType, data); message != nil {
+		queueWebhooks(householdID, listID, eventType, message)
+	}
+}
+
+// sendEvent numbers an event and queues it for the subscribed clients,
+// returning the encoded event
+func sendEvent(householdID int64, actor EventActor, listID int64, index bool, eventType string, data interface{}) []byte {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	h := events[householdID]
+	if h == nil {
+		h = &householdEvents{lists: make(map[int64]int64)}
+		events[householdID] = h
+	}
+
+	event := Event{
+		Version: EventSchemaVersion,
+		Type:    eventType,
+		Seq:     h.seq + 1,
+		Epoch:   eventEpoch,
+		ListID:  listID,
+		Actor:   actor,
+		Time:    time.Now().Unix(),
+		Data:    data,
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal event %s: %v", eventType, err)
+		return nil
+	}
+
+	h.seq = event.Seq
+	if index {
+		h.index = event.Seq
+	}
+	if listID != 0 {
+		h.lists[listID] = event.Seq
+	}
+	if len(h.recent) == eventBufferSize {
+		h.recent = append(h.recent[:0], h.recent[1:]...)
+	}
+	h.recent = append(h.recent, bufferedEvent{seq: event.Seq, listID: listID, index: index, data: message})
+
+	broadcast(householdID, listID, index, eventType, event.Seq, message)
+	return message
+}
+
+// eventsSince returns the household's current seq and the buffered events on
+// the given channels after since. resync is set when they can't be replayed:
+// the epoch changed (the server restarted) or they are no longer buffered.
+// epoch 0 means a new client with nothing to catch up on. The caller holds
+// eventsMu, so it can queue the events before any newer one.
+func eventsSince(householdID, since, epoch int64, lists []int64, index bool) (seq int64, missed []queuedMessage, resync bool) {
+	h := events[householdID]
+	if h != nil {
+		seq = h.seq
+	}
+	if epoch == 0 {
+		return seq, nil, false
+	}
+	if epoch != eventEpoch || since > seq {
+		return seq, nil, true
+	}
+
+	subscribed := make(map[int64]bool, len(lists))
+	missedAny := index && h.index > since
+	for _, listID := range lists {
+		subscribed[listID] = true
+		if h.lists[listID] > since {
+			missedAny = true
+		}
+	}
+	if !missedAny {
+		return seq, nil, false
+	}
+	if len(h.recent) == 0 || h.recent[0].seq > since+1 {
+		return seq, nil, true
+	}
+
+	for _, event := range h.recent {
+		if event.seq > since && wantsEvent(subscribed, index, event.listID, event.index) {
+			missed = append(missed, queuedMessage{seq: event.seq, data: event.data})
+		}
+	}
+	return seq, missed, false
+}
+
+// BroadcastList sends a list-index event carrying the list with its stats
+func BroadcastList(c *fiber.Ctx, eventType string, list *db.List) {
+	list.Stats = db.GetListStats(list.ID)
+	BroadcastIndexUpdate(c, list.ID, eventType, list)
+}
+
+// BroadcastListsReordered sends the household's lists in their new order
+func BroadcastListsReordered(c *fiber.Ctx) {
+	lists, err := db.GetAllLists(HouseholdID(c))
+	if err != nil {
+		log.Printf("Failed to load lists for %s: %v", EventListsReordered, err)
+		return
+	}
+	BroadcastIndexUpdate(c, 0, EventListsReordered, fiber.Map{"lists": lists})
+}
+
+// BroadcastSectionsReordered sends the sections of a section's list in their new order
+func BroadcastSectionsReordered(c *fiber.Ctx, sectionID int64) {
+	listID, err := db.SectionListID(sectionID)
+	if err != nil {
+		return
+	}
+	sections, err := db.GetSectionsByList(listID)
+	if err != nil {
+		log.Printf("Failed to load sections for %s: %v", EventSectionsReordered, err)
+		return
+	}
+	BroadcastUpdate(c, listID, EventSectionsReordered, fiber.Map{"list_id": listID, "sections": sections})
+}
+
+// BroadcastItemsReordered sends a section's items in their new order
+func BroadcastItemsReordered(c *fiber.Ctx, sectionID int64) {
+	items, err := db.GetItemsBySection(sectionID)
+	if err != nil {
+		log.Printf("Failed to load items for %s: %v", EventItemsReordered, err)
+		return
+	}
+	BroadcastSectionUpdate(c, sectionID, EventItemsReordered, fiber.Map{"section_id": sectionID, "items": items})
+}
+
+// BroadcastTemplateApplied sends the list a template was applied to, with all its sections and items
+func BroadcastTemplateApplied(c *fiber.Ctx, templateID, listID int64) {
+	publishTemplateApplied(HouseholdID(c), ActorFromContext(c), templateID, 0, listID)
+}
+
+// publishTemplateApplied is BroadcastTemplateApplied outside a request, for
+// a run of schedule scheduleID (0 for none)
+func publishTemplateApplied(householdID int64, actor EventActor, templateID, scheduleID, listID int64) {
+	list, err := db.GetListByID(listID)
+	if err != nil {
+		return
+	}
+	list.Stats = db.GetListStats(listID)
+	sections, err := db.GetSectionsByList(listID)
+	if err != nil {
+		log.Printf("Failed to load sections for %s: %v", EventTemplateApplied, err)
+		return
+	}
+	data := fiber.Map{
+		"template_id": templateID,
+		"list":        list,
+		"sections":    sections,
+	}
+	if scheduleID != 0 {
+		data["schedule_id"] = scheduleID
+	}
+	PublishEvent(householdID, actor, listID, true, EventTemplateApplied, data)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"shopping-list/db"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ownedBy reports whether the row with the given ID belongs to the caller's household
+func ownedBy(c *fiber.Ctx, lookup func(int64) (int64, error), id int64) bool {
+	householdID, err := lookup(id)
+	return err == nil && householdID == HouseholdID(c)
+}
+
+// OwnsList reports whether a list belongs to the caller's household
+func OwnsList(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.ListHouseholdID, id)
+}
+
+// OwnsSection reports whether a section belongs to the caller's household
+func OwnsSection(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.SectionHouseholdID, id)
+}
+
+// OwnsItem reports whether an item belongs to the caller's household
+func OwnsItem(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.ItemHouseholdID, id)
+}
+
+// OwnsTemplate reports whether a template belongs to the caller's household
+func OwnsTemplate(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.TemplateHouseholdID, id)
+}
+
+// OwnsTemplateItem reports whether a template item belongs to the caller's household
+func OwnsTemplateItem(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.TemplateItemHouseholdID, id)
+}
+
+// OwnsTemplateSchedule reports whether a template schedule belongs to the caller's household
+func OwnsTemplateSchedule(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.TemplateScheduleHouseholdID, id)
+}
+
+// OwnsWebhook reports whether a webhook belongs to the caller's household
+func OwnsWebhook(c *fiber.Ctx, id int64) bool {
+	return ownedBy(c, db.WebhookHouseholdID, id)
+}
+
+// requireOwned builds route middleware that answers 404 when the row named by
+// the route parameter belongs to another household, so its existence isn't revealed
+func requireOwned(param string, owns func(*fiber.Ctx, int64) bool, notFound string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params(param), 10, 64)
+		if err != nil {
+			return c.Status(400).SendString("Invalid ID")
+		}
+		if !owns(c, id) {
+			return c.Status(404).SendString(notFound)
+		}
+		return c.Next()
+	}
+}
+
+// Route middleware restricting :id (and :itemId) routes to the caller's household
+var (
+	ListAccess         = requireOwned("id", OwnsList, "List not found")
+	SectionAccess      = requireOwned("id", OwnsSection, "Section not found")
+	ItemAccess         = requireOwned("id", OwnsItem, "Item not found")
+	TemplateAccess     = requireOwned("id", OwnsTemplate, "Template not found")
+	TemplateItemAccess = requireOwned("itemId", OwnsTemplateItem, "Template item not found")
+)
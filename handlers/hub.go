@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"shopping-list/db"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// eventSendBuffer is how many messages may wait for a client before it counts as slow
+const eventSendBuffer = 64
+
+// eventClient is a client receiving live updates over WebSocket or SSE, and
+// the channels it subscribed to. A client only receives events for the lists
+// it subscribed to, plus list-index events (lists created, renamed,
+// reordered...) when index is set. Messages are queued on send and written by
+// the connection's own goroutine, so a stalled connection never blocks a
+// broadcast.
+type eventClient struct {
+	householdID int64
+	access      EventAccess
+	sse         bool
+	lists       map[int64]bool // guarded by clientsMu
+	index       bool           // guarded by clientsMu
+	send        chan queuedMessage
+	done        chan struct{} // closed to stop the writer
+	closeOnce   sync.Once
+	onClose     func() // unblocks the connection's reader, if any
+}
+
+// EventAccess limits what a client may subscribe to; the zero value allows
+// everything in the household. API tokens connecting to the WebSocket set it
+// in LocalsEventAccess.
+type EventAccess struct {
+	ListID  int64 // the only list the client may follow, 0 for any
+	NoIndex bool  // the client may not follow list-index events
+}
+
+// LocalsEventAccess is the fiber.Ctx locals key for the EventAccess of a
+// WebSocket connection
+const LocalsEventAccess = "event_access"
+
+// queuedMessage is a message waiting to be written to a client
+type queuedMessage struct {
+	seq  int64 // event seq; 0 for replies that aren't events
+	data []byte
+}
+
+// Live update clients, guarded by clientsMu
+var (
+	clients   = make(map[*eventClient]bool)
+	clientsMu sync.RWMutex
+)
+
+// Counters reported by GetWebSocketStats
+var (
+	messagesSent    atomic.Int64
+	messagesDropped atomic.Int64
+	slowClients     atomic.Int64
+	idleClients     atomic.Int64
+)
+
+// WebSocketStats are the live update hub metrics
+type WebSocketStats struct {
+	ConnectedClients int   `json:"connected_clients"` // WebSocket and SSE
+	SSEClients       int   `json:"sse_clients"`
+	MessagesSent     int64 `json:"messages_sent"`
+	MessagesDropped  int64 `json:"messages_dropped"` // not queued because the client was too slow
+	SlowClients      int64 `json:"slow_clients"`     // clients disconnected for a full queue
+	IdleClients      int64 `json:"idle_clients"`     // clients evicted for missing pongs
+}
+
+func newEventClient(householdID int64) *eventClient {
+	return &eventClient{
+		householdID: householdID,
+		lists:       make(map[int64]bool),
+		send:        make(chan queuedMessage, eventSendBuffer),
+		done:        make(chan struct{}),
+	}
+}
+
+// registerClient adds a client to the hub and returns the number of clients
+func registerClient(client *eventClient) int {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	clients[client] = true
+	return len(clients)
+}
+
+// unregisterClient removes a client from the hub, stops its writer and
+// returns the number of clients left
+func unregisterClient(client *eventClient) int {
+	clientsMu.Lock()
+	delete(clients, client)
+	count := len(clients)
+	clientsMu.Unlock()
+	client.close()
+	return count
+}
+
+// close stops the writer and the reader; safe to call more than once
+func (client *eventClient) close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+		if client.onClose != nil {
+			client.onClose()
+		}
+	})
+}
+
+func (client *eventClient) closed() bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// queue hands a message to the writer without blocking. A client whose queue
+// is full can't keep up: the message is dropped and the client disconnected,
+// it resyncs after reconnecting.
+func (client *eventClient) queue(message queuedMessage) bool {
+	if client.closed() {
+		return false
+	}
+
+	select {
+	case client.send <- message:
+		return true
+	default:
+		messagesDropped.Add(1)
+		slowClients.Add(1)
+		log.Printf("Live update client too slow, disconnecting")
+		client.close()
+		return false
+	}
+}
+
+func (client *eventClient) queueJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return
+	}
+	client.queue(queuedMessage{data: data})
+}
+
+// subscribe adds (or with subscribe false, removes) list and index
+// subscriptions and returns the resulting ones
+func (client *eventClient) subscribe(subscribe bool, lists []int64, index bool) ([]int64, bool) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	for _, listID := range lists {
+		if subscribe {
+			client.lists[listID] = true
+		} else {
+			delete(client.lists, listID)
+		}
+	}
+	if index {
+		client.index = subscribe
+	}
+
+	subscribed := []int64{}
+	for listID := range client.lists {
+		subscribed = append(subscribed, listID)
+	}
+	return subscribed, client.index
+}
+
+// ownedLists filters list IDs down to the household's lists
+func ownedLists(householdID int64, lists []int64) []int64 {
+	owned := []int64{}
+	for _, listID := range lists {
+		if id, err := db.ListHouseholdID(listID); err == nil && id == householdID {
+			owned = append(owned, listID)
+		}
+	}
+	return owned
+}
+
+// allowedLists filters list IDs down to the ones the client may follow
+func (client *eventClient) allowedLists(lists []int64) []int64 {
+	allowed := []int64{}
+	for _, listID := range ownedLists(client.householdID, lists) {
+		if client.access.ListID == 0 || client.access.ListID == listID {
+			allowed = append(allowed, listID)
+		}
+	}
+	return allowed
+}
+
+// wantsEvent reports whether a subscription covers an event of listID
+// (index marks list-index events)
+func wantsEvent(lists map[int64]bool, subscribedIndex bool, listID int64, index bool) bool {
+	return (index && subscribedIndex) || (listID != 0 && lists[listID])
+}
+
+// broadcast queues an encoded event for the household's clients subscribed
+// to listID, or to the index when index is set
+func broadcast(householdID, listID int64, index bool, eventType string, seq int64, data []byte) {
+	clientsMu.RLock()
+	var recipients []*eventClient
+	for client := range clients {
+		if client.householdID == householdID && wantsEvent(client.lists, client.index, listID, index) {
+			recipients = append(recipients, client)
+		}
+	}
+	clientsMu.RUnlock()
+
+	queued := 0
+	for _, client := range recipients {
+		if client.queue(queuedMessage{seq: seq, data: data}) {
+			queued++
+		}
+	}
+
+	log.Printf("Broadcast %s (list %d): queued for %d/%d clients", eventType, listID, queued, len(recipients))
+}
+
+// GetWebSocketStats returns the live update hub metrics. They cover every
+// household, so only admins may see them.
+func GetWebSocketStats(c *fiber.Ctx) error {
+	clientsMu.RLock()
+	stats := WebSocketStats{ConnectedClients: len(clients)}
+	for client := range clients {
+		if client.sse {
+			stats.SSEClients++
+		}
+	}
+	clientsMu.RUnlock()
+
+	stats.MessagesSent = messagesSent.Load()
+	stats.MessagesDropped = messagesDropped.Load()
+	stats.SlowClients = slowClients.Load()
+	stats.IdleClients = idleClients.Load()
+	return c.JSON(stats)
+}
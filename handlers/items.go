@@ -36,6 +36,9 @@ func CreateItem(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).SendString("Invalid section ID")
 	}
+	if !OwnsSection(c, sectionID) {
+		return c.Status(404).SendString("Section not found")
+	}
 
 	name := c.FormValue("name")
 	if name == "" {
@@ -44,25 +47,45 @@ func CreateItem(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	item, err := db.CreateItem(sectionID, name, description)
+	quantity, unit, err := ParseQuantityFields(c.FormValue("quantity"), c.FormValue("unit"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	// No explicit quantity - try to extract it from the typed name ("3x milk", "500 g flour")
+	if quantity == 0 && unit == "" {
+		name, quantity, unit = ParseQuantity(name)
+		if name == "" {
+			return c.Status(400).SendString("Name is required")
+		}
+	}
+
+	item, merged, err := db.AddItem(sectionID, name, description, quantity, unit, 0)
+	if err == db.ErrDuplicateItem {
+		return c.Status(409).SendString("Item already on the list")
+	}
 	if err != nil {
 		return c.Status(500).SendString("Failed to create item")
 	}
 
 	// Save to item history for auto-completion
-	db.SaveItemHistory(name, sectionID)
+	db.SaveItemHistory(HouseholdID(c), name, sectionID)
 
-	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_created", item)
+	// Broadcast to WebSocket clients - a merged item was updated, not created
+	if merged {
+		BroadcastSectionUpdate(c, item.SectionID, EventItemUpdated, item)
+	} else {
+		BroadcastSectionUpdate(c, item.SectionID, EventItemCreated, item)
+	}
 
 	// Return the new item partial for HTMX
 	return c.Render("partials/item", fiber.Map{
 		"Item":     item,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
-// UpdateItem updates an item's name and description
+// UpdateItem updates an item's name, description, quantity and recurrence
 func UpdateItem(c *fiber.Ctx) error {
 	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
@@ -76,18 +99,28 @@ func UpdateItem(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	item, err := db.UpdateItem(id, name, description)
+	quantity, unit, err := ParseQuantityFields(c.FormValue("quantity"), c.FormValue("unit"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	recurDays, err := ParseRecurDays(c.FormValue("recur_days"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	item, err := db.UpdateItem(id, name, description, quantity, unit, recurDays)
 	if err != nil {
 		return c.Status(500).SendString("Failed to update item")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_updated", item)
+	BroadcastSectionUpdate(c, item.SectionID, EventItemUpdated, item)
 
 	// Return updated item partial
 	return c.Render("partials/item", fiber.Map{
 		"Item":     item,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
@@ -98,13 +131,17 @@ func DeleteItem(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Invalid ID")
 	}
 
+	var sectionID int64
+	if item, err := db.GetItemByID(id); err == nil {
+		sectionID = item.SectionID
+	}
 	err = db.DeleteItem(id)
 	if err != nil {
 		return c.Status(500).SendString("Failed to delete item")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_deleted", map[string]int64{"id": id})
+	BroadcastSectionUpdate(c, sectionID, EventItemDeleted, map[string]int64{"id": id, "section_id": sectionID})
 
 	// Return empty string (HTMX will remove the element)
 	return c.SendString("")
@@ -112,15 +149,20 @@ func DeleteItem(c *fiber.Ctx) error {
 
 // DeleteCompletedItems deletes all completed items
 func DeleteCompletedItems(c *fiber.Ctx) error {
-	count, err := db.DeleteCompletedItems()
+	activeList, err := db.GetActiveList(HouseholdID(c))
+	if err != nil {
+		return c.Status(500).SendString("No active list found")
+	}
+
+	ids, err := db.DeleteCompletedItems(activeList.ID)
 	if err != nil {
 		return c.Status(500).SendString("Failed to delete completed items")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("completed_items_deleted", map[string]int64{"count": count})
+	BroadcastUpdate(c, activeList.ID, EventCompletedItemsDeleted, fiber.Map{"ids": ids, "count": len(ids)})
 
-	return c.JSON(fiber.Map{"deleted": count})
+	return c.JSON(fiber.Map{"deleted": len(ids)})
 }
 
 // ToggleItem toggles the completed status of an item
@@ -136,18 +178,18 @@ func ToggleItem(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_toggled", item)
+	BroadcastSectionUpdate(c, item.SectionID, EventItemToggled, item)
 
 	// Return the appropriate item partial based on completed status
 	if item.Completed {
 		return c.Render("partials/item_completed", fiber.Map{
 			"Item":     item,
-			"Sections": getSectionsForDropdown(),
+			"Sections": getSectionsForDropdown(c),
 		}, "")
 	}
 	return c.Render("partials/item", fiber.Map{
 		"Item":     item,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
@@ -164,18 +206,18 @@ func ToggleUncertain(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_updated", item)
+	BroadcastSectionUpdate(c, item.SectionID, EventItemUpdated, item)
 
 	// Return the appropriate item partial based on completed status
 	if item.Completed {
 		return c.Render("partials/item_completed", fiber.Map{
 			"Item":     item,
-			"Sections": getSectionsForDropdown(),
+			"Sections": getSectionsForDropdown(c),
 		}, "")
 	}
 	return c.Render("partials/item", fiber.Map{
 		"Item":     item,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
@@ -191,6 +233,9 @@ func MoveItemToSection(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).SendString("Invalid section ID")
 	}
+	if !OwnsSection(c, newSectionID) {
+		return c.Status(404).SendString("Section not found")
+	}
 
 	var item *db.Item
 
@@ -217,7 +262,7 @@ func MoveItemToSection(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("item_moved", item)
+	BroadcastSectionUpdate(c, item.SectionID, EventItemMoved, item)
 
 	// Trigger full refresh for simplicity (item moved between sections)
 	c.Set("HX-Trigger", "refreshList")
@@ -239,7 +284,7 @@ func MoveItemUp(c *fiber.Ctx) error {
 	// Get the item's section and return all items in that section
 	item, _ := db.GetItemByID(id)
 	if item != nil {
-		BroadcastUpdate("items_reordered", map[string]int64{"section_id": item.SectionID})
+		BroadcastItemsReordered(c, item.SectionID)
 		return returnSectionItems(c, item.SectionID)
 	}
 
@@ -261,7 +306,7 @@ func MoveItemDown(c *fiber.Ctx) error {
 	// Get the item's section and return all items in that section
 	item, _ := db.GetItemByID(id)
 	if item != nil {
-		BroadcastUpdate("items_reordered", map[string]int64{"section_id": item.SectionID})
+		BroadcastItemsReordered(c, item.SectionID)
 		return returnSectionItems(c, item.SectionID)
 	}
 
@@ -277,13 +322,13 @@ func returnSectionItems(c *fiber.Ctx, sectionID int64) error {
 
 	return c.Render("partials/section", fiber.Map{
 		"Section":  section,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
 // GetStats returns current stats as JSON (for Alpine.js updates)
 func GetStats(c *fiber.Ctx) error {
-	stats := db.GetStats()
+	stats := db.GetStats(HouseholdID(c))
 	return c.JSON(stats)
 }
 
@@ -295,6 +340,9 @@ func GetItemVersion(c *fiber.Ctx) error {
 	}
 
 	item, err := db.GetItemByID(id)
+	if err == nil && !OwnsItem(c, id) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.Status(404).JSON(fiber.Map{"error": "Item not found"})
@@ -303,9 +351,11 @@ func GetItemVersion(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 	}
 
+	c.Set(fiber.HeaderETag, item.ETag())
 	return c.JSON(fiber.Map{
 		"id":         item.ID,
 		"updated_at": item.UpdatedAt,
 		"completed":  item.Completed,
+		"etag":       item.ETag(), // same version the REST API uses for If-Match
 	})
 }
@@ -21,19 +21,21 @@ const (
 
 // GetListsPage returns the homepage with all lists
 func GetListsPage(c *fiber.Ctx) error {
-	lists, err := db.GetAllLists()
+	lists, err := db.GetAllLists(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch lists")
 	}
 
-	templates, _ := db.GetAllTemplates()
+	templates, _ := db.GetAllTemplates(HouseholdID(c))
 
 	return c.Render("home", fiber.Map{
-		"Lists":        lists,
-		"Templates":    templates,
-		"Translations": i18n.GetAllLocales(),
-		"Locales":      i18n.AvailableLocales(),
-		"DefaultLang":  i18n.GetDefaultLang(),
+		"Lists":          lists,
+		"Templates":      templates,
+		"CurrentUser":    CurrentUser(c),
+		"CanManageUsers": canManageUsers(c),
+		"Translations":   i18n.GetAllLocales(),
+		"Locales":        i18n.AvailableLocales(),
+		"DefaultLang":    i18n.GetDefaultLang(),
 	})
 }
 
@@ -45,6 +47,9 @@ func GetListView(c *fiber.Ctx) error {
 	}
 
 	list, err := db.GetListByID(id)
+	if err == nil && !OwnsList(c, id) {
+		err = sql.ErrNoRows // Another household's list
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// List not found - redirect to home
@@ -64,22 +69,24 @@ func GetListView(c *fiber.Ctx) error {
 	}
 
 	stats := db.GetListStats(id)
-	lists, _ := db.GetAllLists()
+	lists, _ := db.GetAllLists(HouseholdID(c))
 
 	return c.Render("list", fiber.Map{
-		"List":         list,
-		"Lists":        lists,
-		"Sections":     sections,
-		"Stats":        stats,
-		"Translations": i18n.GetAllLocales(),
-		"Locales":      i18n.AvailableLocales(),
-		"DefaultLang":  i18n.GetDefaultLang(),
+		"List":           list,
+		"Lists":          lists,
+		"Sections":       sections,
+		"Stats":          stats,
+		"CurrentUser":    CurrentUser(c),
+		"CanManageUsers": canManageUsers(c),
+		"Translations":   i18n.GetAllLocales(),
+		"Locales":        i18n.AvailableLocales(),
+		"DefaultLang":    i18n.GetDefaultLang(),
 	})
 }
 
 // GetLists returns all lists (JSON API)
 func GetLists(c *fiber.Ctx) error {
-	lists, err := db.GetAllLists()
+	lists, err := db.GetAllLists(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch lists")
 	}
@@ -111,13 +118,25 @@ func CreateList(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Icon too long")
 	}
 
-	list, err := db.CreateList(name, icon)
+	duplicateMode := c.FormValue("duplicate_mode")
+	if duplicateMode != "" && !db.IsValidDuplicateMode(duplicateMode) {
+		return c.Status(400).SendString("Invalid duplicate mode")
+	}
+
+	list, err := db.CreateList(HouseholdID(c), name, icon)
 	if err != nil {
 		return c.Status(500).SendString("Failed to create list")
 	}
 
+	if duplicateMode != "" && duplicateMode != list.DuplicateMode {
+		list, err = db.SetListDuplicateMode(list.ID, duplicateMode)
+		if err != nil {
+			return c.Status(500).SendString("Failed to create list")
+		}
+	}
+
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("list_created", list)
+	BroadcastList(c, EventListCreated, list)
 
 	// Return the new list item partial for HTMX
 	return c.Render("partials/list_item", fiber.Map{
@@ -145,13 +164,25 @@ func UpdateList(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Icon too long")
 	}
 
+	duplicateMode := c.FormValue("duplicate_mode")
+	if duplicateMode != "" && !db.IsValidDuplicateMode(duplicateMode) {
+		return c.Status(400).SendString("Invalid duplicate mode")
+	}
+
 	list, err := db.UpdateList(id, name, icon)
 	if err != nil {
 		return c.Status(500).SendString("Failed to update list")
 	}
 
+	if duplicateMode != "" && duplicateMode != list.DuplicateMode {
+		list, err = db.SetListDuplicateMode(id, duplicateMode)
+		if err != nil {
+			return c.Status(500).SendString("Failed to update list")
+		}
+	}
+
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("list_updated", list)
+	BroadcastList(c, EventListUpdated, list)
 
 	// Return updated list item partial
 	return c.Render("partials/list_item", fiber.Map{
@@ -172,7 +203,7 @@ func DeleteList(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("list_deleted", map[string]int64{"id": id})
+	BroadcastIndexUpdate(c, id, EventListDeleted, map[string]int64{"id": id})
 
 	// Return empty string (HTMX will remove the element)
 	return c.SendString("")
@@ -191,7 +222,9 @@ func SetActiveList(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("list_activated", map[string]int64{"id": id})
+	if list, err := db.GetListByID(id); err == nil {
+		BroadcastList(c, EventListActivated, list)
+	}
 
 	// Check if this is from the main page (needs redirect) or lists page
 	if c.Get("HX-Current-URL") != "" && !contains(c.Get("HX-Current-URL"), "/lists") {
@@ -216,7 +249,7 @@ func MoveListUp(c *fiber.Ctx) error {
 	}
 
 	// Broadcast and return full lists
-	BroadcastUpdate("lists_reordered", nil)
+	BroadcastListsReordered(c)
 	return returnAllLists(c)
 }
 
@@ -233,18 +266,18 @@ func MoveListDown(c *fiber.Ctx) error {
 	}
 
 	// Broadcast and return full lists
-	BroadcastUpdate("lists_reordered", nil)
+	BroadcastListsReordered(c)
 	return returnAllLists(c)
 }
 
 // Helper to return all lists as HTML partials
 func returnAllLists(c *fiber.Ctx) error {
-	lists, err := db.GetAllLists()
+	lists, err := db.GetAllLists(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch lists")
 	}
 
-	activeList, _ := db.GetActiveList()
+	activeList, _ := db.GetActiveList(HouseholdID(c))
 
 	return c.Render("partials/lists_container", fiber.Map{
 		"Lists":      lists,
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"shopping-list/db"
+	"shopping-list/i18n"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrWrongPassword is returned when the current app password doesn't match
+var ErrWrongPassword = errors.New("current password is incorrect")
+
+// ErrTooManyAttempts is returned while the login rate limiter locks out the caller
+var ErrTooManyAttempts = errors.New("too many failed attempts")
+
+// ValidatePassword checks a new password against the length limits
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("Password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("Password too long (max %d characters)", MaxPasswordLength)
+	}
+	return nil
+}
+
+// ChangeAppPassword replaces the shared login password after verifying the
+// current one. All sessions are deleted, so everyone has to log in again.
+// A wrong current password counts as a failed login from ip.
+func ChangeAppPassword(ip, current, next string) error {
+	if loginLimiter != nil {
+		if blocked, _ := loginLimiter.IsBlocked(ip); blocked {
+			return ErrTooManyAttempts
+		}
+	}
+	if !checkAppPassword(current) {
+		if loginLimiter != nil && loginLimiter.RecordAttempt(ip) {
+			return ErrTooManyAttempts
+		}
+		return ErrWrongPassword
+	}
+	if loginLimiter != nil {
+		loginLimiter.ResetAttempts(ip)
+	}
+	if err := ValidatePassword(next); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := db.SetAppPasswordHash(string(hash)); err != nil {
+		return err
+	}
+	log.Println("[AUTH] App password changed - all sessions invalidated")
+	return nil
+}
+
+// GetPasswordPage renders the page for changing the shared app password
+func GetPasswordPage(c *fiber.Ctx) error {
+	return c.Render("password", fiber.Map{
+		"MultiUser":    db.CountActiveUsers() > 0,
+		"Translations": i18n.GetAllLocales(),
+		"Locales":      i18n.AvailableLocales(),
+		"DefaultLang":  i18n.GetDefaultLang(),
+	})
+}
+
+// ChangePassword changes the shared app password. The caller gets a fresh
+// session so they stay logged in while every other session ends.
+func ChangePassword(c *fiber.Ctx) error {
+	next := c.FormValue("new_password")
+	if err := ValidatePassword(next); err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	if next != c.FormValue("confirm_password") {
+		return c.Status(400).SendString("Passwords don't match")
+	}
+
+	err := ChangeAppPassword(c.IP(), c.FormValue("current_password"), next)
+	if err == ErrWrongPassword {
+		return c.Status(403).SendString("Current password is incorrect")
+	}
+	if err == ErrTooManyAttempts {
+		return c.Status(429).SendString("Too many failed attempts, try again later")
+	}
+	if err != nil {
+		return c.Status(500).SendString("Failed to change password")
+	}
+
+	if !isAuthDisabled() {
+		if err := startSession(c, CurrentUserID(c)); err != nil {
+			return c.Status(500).SendString("Session creation failed")
+		}
+	}
+
+	return c.SendString("")
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Input limits for quantities
+const (
+	MaxUnitLength = 20
+	MaxQuantity   = 100000
+)
+
+// unitAliases maps recognized unit spellings (lowercase) to their canonical form
+var unitAliases = map[string]string{
+	"g":       "g",
+	"gr":      "g",
+	"gram":    "g",
+	"grams":   "g",
+	"kg":      "kg",
+	"mg":      "mg",
+	"l":       "l",
+	"ltr":     "l",
+	"liter":   "l",
+	"liters":  "l",
+	"litre":   "l",
+	"litres":  "l",
+	"ml":      "ml",
+	"cl":      "cl",
+	"dl":      "dl",
+	"oz":      "oz",
+	"lb":      "lb",
+	"lbs":     "lb",
+	"pc":      "pcs",
+	"pcs":     "pcs",
+	"szt":     "szt",
+	"stk":     "stk",
+	"st":      "st",
+	"pack":    "pack",
+	"packs":   "pack",
+	"pkg":     "pack",
+	"can":     "can",
+	"cans":    "can",
+	"bottle":  "bottle",
+	"bottles": "bottle",
+	"dozen":   "dozen",
+	"tbsp":    "tbsp",
+	"tsp":     "tsp",
+	"cup":     "cup",
+	"cups":    "cup",
+}
+
+var (
+	// "3x milk", "3 x milk", "3× milk"
+	quantityMultiplierPrefix = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*[xX×]\s+(.+)$`)
+	// "500 g flour", "500g flour", "2 milk"
+	quantityUnitPrefix = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*(\pL+\.?)?\s+(.+)$`)
+	// "milk x3", "milk x 3"
+	quantityMultiplierSuffix = regexp.MustCompile(`^(.+?)\s+[xX×]\s*(\d+(?:[.,]\d+)?)$`)
+	// "flour 500g", "flour 500 g"
+	quantityUnitSuffix = regexp.MustCompile(`^(.+?)\s+(\d+(?:[.,]\d+)?)\s*(\pL+)\.?$`)
+)
+
+// ParseQuantity extracts a leading or trailing quantity/unit from typed input
+// like "3x milk", "500 g flour" or "milk x2". It returns the remaining name and
+// a zero quantity with an empty unit when nothing was recognized.
+func ParseQuantity(input string) (name string, quantity float64, unit string) {
+	input = strings.TrimSpace(input)
+
+	if m := quantityMultiplierPrefix.FindStringSubmatch(input); m != nil {
+		if q, ok := parseDecimal(m[1]); ok {
+			return strings.TrimSpace(m[2]), q, ""
+		}
+	}
+
+	if m := quantityUnitPrefix.FindStringSubmatch(input); m != nil {
+		if q, ok := parseDecimal(m[1]); ok {
+			if m[2] == "" {
+				return strings.TrimSpace(m[3]), q, ""
+			}
+			if u, known := normalizeUnit(m[2]); known {
+				return strings.TrimSpace(m[3]), q, u
+			}
+			// Unknown word after the number belongs to the name ("2 apples")
+			return strings.TrimSpace(m[2] + " " + m[3]), q, ""
+		}
+	}
+
+	if m := quantityMultiplierSuffix.FindStringSubmatch(input); m != nil {
+		if q, ok := parseDecimal(m[2]); ok {
+			return strings.TrimSpace(m[1]), q, ""
+		}
+	}
+
+	if m := quantityUnitSuffix.FindStringSubmatch(input); m != nil {
+		if u, known := normalizeUnit(m[3]); known {
+			if q, ok := parseDecimal(m[2]); ok {
+				return strings.TrimSpace(m[1]), q, u
+			}
+		}
+	}
+
+	return input, 0, ""
+}
+
+// ParseQuantityFields validates quantity and unit form values, accepting
+// comma as decimal separator. Empty quantity means "no quantity".
+func ParseQuantityFields(quantityStr, unit string) (float64, string, error) {
+	unit = strings.TrimSpace(unit)
+	if len(unit) > MaxUnitLength {
+		return 0, "", fmt.Errorf("unit too long (max %d characters)", MaxUnitLength)
+	}
+	if u, known := normalizeUnit(unit); known {
+		unit = u
+	}
+
+	quantityStr = strings.TrimSpace(quantityStr)
+	if quantityStr == "" {
+		return 0, unit, nil
+	}
+	quantity, err := strconv.ParseFloat(strings.Replace(quantityStr, ",", ".", 1), 64)
+	if err != nil || !ValidQuantity(quantity) {
+		return 0, "", fmt.Errorf("invalid quantity")
+	}
+	return quantity, unit, nil
+}
+
+// ValidQuantity reports whether a quantity is a number between 0 (no
+// quantity) and MaxQuantity. ParseFloat accepts "NaN", which no range
+// check catches.
+func ValidQuantity(quantity float64) bool {
+	return !math.IsNaN(quantity) && !math.IsInf(quantity, 0) && quantity >= 0 && quantity <= MaxQuantity
+}
+
+func normalizeUnit(unit string) (string, bool) {
+	u, ok := unitAliases[strings.TrimSuffix(strings.ToLower(unit), ".")]
+	return u, ok
+}
+
+func parseDecimal(s string) (float64, bool) {
+	q, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || q <= 0 || q > MaxQuantity {
+		return 0, false
+	}
+	return q, true
+}
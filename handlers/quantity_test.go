@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		quantity float64
+		unit     string
+	}{
+		{"milk", "milk", 0, ""},
+		{"3x milk", "milk", 3, ""},
+		{"3 x milk", "milk", 3, ""},
+		{"3× milk", "milk", 3, ""},
+		{"  3X  milk ", "milk", 3, ""},
+		{"500 g flour", "flour", 500, "g"},
+		{"500g flour", "flour", 500, "g"},
+		{"1,5 kg potatoes", "potatoes", 1.5, "kg"},
+		{"1.5l water", "water", 1.5, "l"},
+		{"2 Pcs. bread", "bread", 2, "pcs"},
+		{"2 milk", "milk", 2, ""},
+		{"2 green apples", "green apples", 2, ""},
+		{"milk x3", "milk", 3, ""},
+		{"milk x 3", "milk", 3, ""},
+		{"flour 500g", "flour", 500, "g"},
+		{"coca cola 2 l", "coca cola", 2, "l"},
+		{"eggs 2 dozen", "eggs", 2, "dozen"},
+		// A bare leading number is always a count, even in a product name
+		{"7 up", "up", 7, ""},
+		{"7up", "7up", 0, ""},
+		// Without a unit or an x, a trailing number stays in the name
+		{"milk 3", "milk 3", 0, ""},
+		{"vitamin b12", "vitamin b12", 0, ""},
+		{"x3", "x3", 0, ""},
+		// Zero and out-of-range quantities aren't extracted
+		{"0 milk", "0 milk", 0, ""},
+		{"200000 g sugar", "200000 g sugar", 0, ""},
+	}
+	for _, tt := range tests {
+		name, quantity, unit := ParseQuantity(tt.input)
+		if name != tt.name || quantity != tt.quantity || unit != tt.unit {
+			t.Errorf("ParseQuantity(%q) = %q, %v, %q; want %q, %v, %q",
+				tt.input, name, quantity, unit, tt.name, tt.quantity, tt.unit)
+		}
+	}
+}
+
+func TestParseQuantityFields(t *testing.T) {
+	tests := []struct {
+		quantity string
+		unit     string
+		want     float64
+		wantUnit string
+		wantErr  bool
+	}{
+		{"", "", 0, "", false},
+		{"2", "", 2, "", false},
+		{" 1,5 ", "KG", 1.5, "kg", false},
+		{"2", "handful", 2, "handful", false},
+		{"100000", "g", 100000, "g", false},
+		{"100001", "g", 0, "", true},
+		{"-1", "", 0, "", true},
+		{"abc", "", 0, "", true},
+		{"NaN", "", 0, "", true},
+		{"Inf", "", 0, "", true},
+		{"-Inf", "", 0, "", true},
+		{"1", strings.Repeat("u", MaxUnitLength+1), 0, "", true},
+	}
+	for _, tt := range tests {
+		got, unit, err := ParseQuantityFields(tt.quantity, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuantityFields(%q, %q) error = %v, want error %v", tt.quantity, tt.unit, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || unit != tt.wantUnit {
+			t.Errorf("ParseQuantityFields(%q, %q) = %v, %q; want %v, %q", tt.quantity, tt.unit, got, unit, tt.want, tt.wantUnit)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"shopping-list/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recurring item settings
+const (
+	MaxRecurDays               = 365
+	recurringItemsPollInterval = time.Minute // how often due items are brought back
+)
+
+var recurringItemsStartOnce sync.Once
+
+// ParseRecurDays validates a recurrence interval form value in days. Empty
+// means the item doesn't recur.
+func ParseRecurDays(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 || days > MaxRecurDays {
+		return 0, fmt.Errorf("recurrence must be between 0 and %d days", MaxRecurDays)
+	}
+	return days, nil
+}
+
+// StartRecurringItems starts bringing back recurring items in the
+// background. Items that came due while the server was down return on
+// startup.
+func StartRecurringItems() {
+	recurringItemsStartOnce.Do(func() {
+		go recurringItemsWorker()
+	})
+}
+
+func recurringItemsWorker() {
+	poll := time.NewTicker(recurringItemsPollInterval)
+	defer poll.Stop()
+
+	for {
+		reviveDueItems()
+		<-poll.C
+	}
+}
+
+// reviveDueItems brings back the recurring items that are due and broadcasts
+// each change
+func reviveDueItems() {
+	revived, err := db.ReviveDueItems(time.Now())
+	if err != nil {
+		log.Printf("Failed to bring back recurring items: %v", err)
+		return
+	}
+	for i := range revived.Reopened {
+		publishItem(EventItemToggled, &revived.Reopened[i])
+	}
+	for i := range revived.Added {
+		publishItem(EventItemCreated, &revived.Added[i])
+	}
+	for i := range revived.Updated {
+		publishItem(EventItemUpdated, &revived.Updated[i])
+	}
+}
+
+// publishItem is BroadcastSectionUpdate outside a request, for a change the
+// server made on its own
+func publishItem(eventType string, item *db.Item) {
+	listID, err := db.SectionListID(item.SectionID)
+	if err != nil {
+		return
+	}
+	householdID, err := db.ListHouseholdID(listID)
+	if err != nil {
+		return
+	}
+	PublishEvent(householdID, SystemActor, listID, false, eventType, item)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"shopping-list/db"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Template schedule settings
+const (
+	MaxScheduleEveryDays  = 365
+	schedulerPollInterval = 30 * time.Second // how often due schedules are picked up
+)
+
+var schedulerStartOnce sync.Once
+
+// ValidateScheduleInput checks a template schedule from the API and
+// normalizes its weekdays to lowercase names in week order
+func ValidateScheduleInput(householdID int64, s *db.TemplateSchedule) error {
+	if s.TemplateID == 0 {
+		return fmt.Errorf("template_id is required")
+	}
+	if id, err := db.TemplateHouseholdID(s.TemplateID); err != nil || id != householdID {
+		return fmt.Errorf("Template not found")
+	}
+	if s.ListID == 0 {
+		return fmt.Errorf("list_id is required")
+	}
+	if id, err := db.ListHouseholdID(s.ListID); err != nil || id != householdID {
+		return fmt.Errorf("List not found")
+	}
+
+	if (s.EveryDays == 0) == (len(s.Weekdays) == 0) {
+		return fmt.Errorf("Set either every_days or weekdays")
+	}
+	if s.EveryDays < 0 || s.EveryDays > MaxScheduleEveryDays {
+		return fmt.Errorf("every_days must be between 1 and %d", MaxScheduleEveryDays)
+	}
+	days := make(map[string]bool)
+	for _, name := range s.Weekdays {
+		day := strings.ToLower(strings.TrimSpace(name))
+		if len(day) > 3 {
+			day = day[:3] // "friday" -> "fri"
+		}
+		if !isWeekday(day) {
+			return fmt.Errorf("Unknown weekday: %s", name)
+		}
+		days[day] = true
+	}
+	weekdays := []string{}
+	for _, day := range db.Weekdays {
+		if days[day] {
+			weekdays = append(weekdays, day)
+		}
+	}
+	s.Weekdays = weekdays
+
+	if s.Time == "" {
+		return fmt.Errorf("time is required")
+	}
+	at, err := time.Parse(db.ScheduleTimeLayout, s.Time)
+	if err != nil {
+		return fmt.Errorf("time must be HH:MM")
+	}
+	s.Time = at.Format(db.ScheduleTimeLayout)
+	return nil
+}
+
+func isWeekday(day string) bool {
+	for _, name := range db.Weekdays {
+		if name == day {
+			return true
+		}
+	}
+	return false
+}
+
+// StartScheduler starts applying scheduled templates in the background.
+// Schedules due while the server was down run once on startup.
+func StartScheduler() {
+	schedulerStartOnce.Do(func() {
+		go scheduler()
+	})
+}
+
+func scheduler() {
+	poll := time.NewTicker(schedulerPollInterval)
+	defer poll.Stop()
+
+	for {
+		runDueSchedules()
+		<-poll.C
+	}
+}
+
+// runDueSchedules runs every schedule that is due, one at a time
+func runDueSchedules() {
+	schedules, err := db.GetDueTemplateSchedules(time.Now())
+	if err != nil {
+		log.Printf("Failed to load due template schedules: %v", err)
+		return
+	}
+	for i := range schedules {
+		RunTemplateSchedule(&schedules[i], SystemActor, false)
+	}
+}
+
+// RunTemplateSchedule applies a schedule's template to its list, skipping
+// items already open there, records the run and broadcasts the list when
+// items were added. A manual run leaves the next due time alone; a scheduled
+// one moves it to the following occurrence, unless another run already did.
+// When even the run can't be recorded nothing was added, and the schedule
+// stays due for the next poll.
+func RunTemplateSchedule(s *db.TemplateSchedule, actor EventActor, manual bool) (*db.ScheduleRun, error) {
+	var next int64
+	if !manual {
+		next = s.NextRun(s.NextRunAt, time.Now())
+	}
+
+	run, err := db.ApplyTemplateSchedule(s, manual, next)
+	if err == db.ErrScheduleNotDue {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to run template schedule %d: %v", s.ID, err)
+		return nil, err
+	}
+	if run.Status == db.RunFailed {
+		log.Printf("Template schedule %d failed: %s", s.ID, run.Error)
+	}
+
+	if run.Status == db.RunApplied {
+		publishTemplateApplied(s.HouseholdID, actor, s.TemplateID, s.ID, s.ListID)
+	}
+	return run, nil
+}
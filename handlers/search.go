@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"shopping-list/db"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Search limits
+const (
+	MaxSearchQueryLength = 200
+	DefaultSearchLimit   = 20  // matches of each kind
+	MaxSearchLimit       = 100 // matches of each kind
+)
+
+// Search finds items, sections, lists and templates matching ?q= for the
+// search box, grouped by list
+func Search(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if len(query) > MaxSearchQueryLength {
+		query = query[:MaxSearchQueryLength]
+	}
+
+	limit := c.QueryInt("limit", DefaultSearchLimit)
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	} else if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	results, err := db.Search(HouseholdID(c), 0, query, true, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Search failed"})
+	}
+	return c.JSON(results)
+}
@@ -10,25 +10,27 @@ import (
 
 // GetSections returns all sections with items (for full page render)
 func GetSections(c *fiber.Ctx) error {
-	sections, err := db.GetAllSections()
+	sections, err := db.GetAllSections(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch sections")
 	}
 
-	stats := db.GetStats()
+	stats := db.GetStats(HouseholdID(c))
 
 	// Get lists for dropdown
-	lists, _ := db.GetAllLists()
-	activeList, _ := db.GetActiveList()
+	lists, _ := db.GetAllLists(HouseholdID(c))
+	activeList, _ := db.GetActiveList(HouseholdID(c))
 
 	return c.Render("list", fiber.Map{
-		"Sections":     sections,
-		"Stats":        stats,
-		"Lists":        lists,
-		"ActiveList":   activeList,
-		"Translations": i18n.GetAllLocales(),
-		"Locales":      i18n.AvailableLocales(),
-		"DefaultLang":  i18n.GetDefaultLang(),
+		"Sections":       sections,
+		"Stats":          stats,
+		"Lists":          lists,
+		"ActiveList":     activeList,
+		"CurrentUser":    CurrentUser(c),
+		"CanManageUsers": canManageUsers(c),
+		"Translations":   i18n.GetAllLocales(),
+		"Locales":        i18n.AvailableLocales(),
+		"DefaultLang":    i18n.GetDefaultLang(),
 	})
 }
 
@@ -42,18 +44,18 @@ func CreateSection(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Name too long (max 100 characters)")
 	}
 
-	section, err := db.CreateSection(name)
+	section, err := db.CreateSection(HouseholdID(c), name)
 	if err != nil {
 		return c.Status(500).SendString("Failed to create section")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("section_created", section)
+	BroadcastUpdate(c, section.ListID, EventSectionCreated, section)
 
 	// Return the new section partial for HTMX
 	return c.Render("partials/section", fiber.Map{
 		"Section":  section,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
@@ -78,12 +80,12 @@ func UpdateSection(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("section_updated", section)
+	BroadcastUpdate(c, section.ListID, EventSectionUpdated, section)
 
 	// Return updated section partial
 	return c.Render("partials/section", fiber.Map{
 		"Section":  section,
-		"Sections": getSectionsForDropdown(),
+		"Sections": getSectionsForDropdown(c),
 	}, "")
 }
 
@@ -94,13 +96,14 @@ func DeleteSection(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Invalid ID")
 	}
 
+	listID, _ := db.SectionListID(id)
 	err = db.DeleteSection(id)
 	if err != nil {
 		return c.Status(500).SendString("Failed to delete section")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("section_deleted", map[string]int64{"id": id})
+	BroadcastUpdate(c, listID, EventSectionDeleted, map[string]int64{"id": id, "list_id": listID})
 
 	// Return empty string (HTMX will remove the element)
 	return c.SendString("")
@@ -119,7 +122,7 @@ func MoveSectionUp(c *fiber.Ctx) error {
 	}
 
 	// Broadcast and return full sections list
-	BroadcastUpdate("sections_reordered", nil)
+	BroadcastSectionsReordered(c, id)
 	return returnAllSections(c)
 }
 
@@ -136,13 +139,13 @@ func MoveSectionDown(c *fiber.Ctx) error {
 	}
 
 	// Broadcast and return full sections list
-	BroadcastUpdate("sections_reordered", nil)
+	BroadcastSectionsReordered(c, id)
 	return returnAllSections(c)
 }
 
 // Helper to return all sections as HTML partials
 func returnAllSections(c *fiber.Ctx) error {
-	sections, err := db.GetAllSections()
+	sections, err := db.GetAllSections(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch sections")
 	}
@@ -153,8 +156,8 @@ func returnAllSections(c *fiber.Ctx) error {
 }
 
 // Helper to get sections for dropdown
-func getSectionsForDropdown() []db.Section {
-	sections, _ := db.GetAllSections()
+func getSectionsForDropdown(c *fiber.Ctx) []db.Section {
+	sections, _ := db.GetAllSections(HouseholdID(c))
 	return sections
 }
 
@@ -180,13 +183,23 @@ func BatchDeleteSections(c *fiber.Ctx) error {
 		return c.Status(400).SendString("No valid IDs provided")
 	}
 
-	err := db.DeleteSections(ids)
+	// Group the IDs by list before deleting, so each list's subscribers are told
+	idsByList := make(map[int64][]int64)
+	for _, id := range ids {
+		if listID, err := db.SectionListID(id); err == nil {
+			idsByList[listID] = append(idsByList[listID], id)
+		}
+	}
+
+	err := db.DeleteSections(HouseholdID(c), ids)
 	if err != nil {
 		return c.Status(500).SendString("Failed to delete sections")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("sections_deleted", map[string]interface{}{"ids": ids})
+	for listID, listIDs := range idsByList {
+		BroadcastUpdate(c, listID, EventSectionsDeleted, map[string]interface{}{"ids": listIDs, "list_id": listID})
+	}
 
 	// Return updated sections list for modal
 	return returnSectionsForModal(c)
@@ -232,7 +245,7 @@ func trimSpace(s string) string {
 
 // Helper to return sections for modal
 func returnSectionsForModal(c *fiber.Ctx) error {
-	sections, err := db.GetAllSections()
+	sections, err := db.GetAllSections(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch sections")
 	}
@@ -246,7 +259,7 @@ func returnSectionsForModal(c *fiber.Ctx) error {
 func GetSectionsListForModal(c *fiber.Ctx) error {
 	// Check if JSON format is requested
 	if c.Query("format") == "json" {
-		sections, err := db.GetAllSections()
+		sections, err := db.GetAllSections(HouseholdID(c))
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch sections"})
 		}
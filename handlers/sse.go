@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SSE stream timing
+const (
+	sseKeepAlive = 25 * time.Second // comment sent on idle streams so proxies keep them open
+	sseRetry     = 3000             // reconnect delay suggested to clients, in milliseconds
+)
+
+// ParseEventSubscription reads the channels of a stream request:
+// ?lists=1,2 for list events and ?index=true for list-index events
+func ParseEventSubscription(c *fiber.Ctx) ([]int64, bool, error) {
+	var lists []int64
+	if v := c.Query("lists"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("Invalid list ID: %s", part)
+			}
+			lists = append(lists, id)
+		}
+	}
+	index := c.QueryBool("index")
+	if len(lists) == 0 && !index {
+		return nil, false, fmt.Errorf("Subscribe to at least one list or the index")
+	}
+	return lists, index, nil
+}
+
+// parseLastEventID reads the "<epoch>-<seq>" ID of the last event a
+// reconnecting client saw, from the Last-Event-ID header or ?last_event_id=
+func parseLastEventID(c *fiber.Ctx) (since, epoch int64) {
+	id := c.Get("Last-Event-ID")
+	if id == "" {
+		id = c.Query("last_event_id")
+	}
+	epochStr, seqStr, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0
+	}
+	epoch, _ = strconv.ParseInt(epochStr, 10, 64)
+	since, _ = strconv.ParseInt(seqStr, 10, 64)
+	return since, epoch
+}
+
+// GetEvents streams the household's events over Server-Sent Events, for
+// networks where the WebSocket upgrade doesn't get through
+func GetEvents(c *fiber.Ctx) error {
+	lists, index, err := ParseEventSubscription(c)
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	return StreamEvents(c, HouseholdID(c), ownedLists(HouseholdID(c), lists), index)
+}
+
+// StreamEvents streams a household's events on the given channels as SSE.
+// The first message is the same "subscribed" message the WebSocket sends;
+// a client resuming with Last-Event-ID then gets the events it missed, or
+// resync set when they are no longer buffered. Lists must already be
+// checked against the caller.
+func StreamEvents(c *fiber.Ctx, householdID int64, lists []int64, index bool) error {
+	since, epoch := parseLastEventID(c)
+
+	client := newEventClient(householdID)
+	client.sse = true
+	subscribed, index := client.subscribe(true, lists, index)
+
+	// Register under eventsMu so no event falls between the replay and the live stream
+	eventsMu.Lock()
+	seq, missed, resync := eventsSince(householdID, since, epoch, subscribed, index)
+	count := registerClient(client)
+	eventsMu.Unlock()
+
+	log.Printf("SSE client connected. Total clients: %d", count)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+
+	hello, _ := json.Marshal(fiber.Map{
+		"type":   "subscribed",
+		"lists":  subscribed,
+		"index":  index,
+		"seq":    seq,
+		"epoch":  eventEpoch,
+		"resync": resync,
+	})
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			count := unregisterClient(client)
+			log.Printf("SSE client disconnected. Total clients: %d", count)
+		}()
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+		// Until the missed events are written, the client has only seen up to since
+		helloSeq := seq
+		if len(missed) > 0 {
+			helloSeq = since
+		}
+		writeSSE(w, queuedMessage{seq: helloSeq, data: hello})
+		for _, event := range missed {
+			writeSSE(w, event)
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case message := <-client.send:
+				writeSSE(w, message)
+				if w.Flush() != nil {
+					return
+				}
+				messagesSent.Add(1)
+			case <-ticker.C:
+				w.WriteString(": keepalive\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			case <-client.done:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// writeSSE writes one message. Its ID is the last seq the client has seen,
+// which EventSource sends back as Last-Event-ID when reconnecting.
+func writeSSE(w *bufio.Writer, message queuedMessage) {
+	fmt.Fprintf(w, "id: %d-%d\ndata: %s\n\n", eventEpoch, message.seq, message.data)
+}
@@ -0,0 +1,535 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"shopping-list/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Sync settings
+const (
+	MaxSyncOperations      = 500       // queued operations one push may replay
+	changeLogPruneInterval = time.Hour // how often old change log entries are dropped
+)
+
+var changeLogPrunerStartOnce sync.Once
+
+// StartChangeLogPruner starts dropping change log entries older than
+// db.ChangeLogRetention in the background, once on startup and then hourly
+func StartChangeLogPruner() {
+	changeLogPrunerStartOnce.Do(func() {
+		go changeLogPruner()
+	})
+}
+
+func changeLogPruner() {
+	prune := time.NewTicker(changeLogPruneInterval)
+	defer prune.Stop()
+
+	for {
+		if err := db.PruneChangeLog(); err != nil {
+			log.Printf("Failed to prune change log: %v", err)
+		}
+		<-prune.C
+	}
+}
+
+// Operations accepted by POST /api/sync/push
+const (
+	SyncOpCreateSection   = "create_section"
+	SyncOpUpdateSection   = "update_section"
+	SyncOpDeleteSection   = "delete_section"
+	SyncOpCreateItem      = "create_item"
+	SyncOpUpdateItem      = "update_item"
+	SyncOpToggleItem      = "toggle_item"
+	SyncOpToggleUncertain = "toggle_uncertain"
+	SyncOpMoveItem        = "move_item"
+	SyncOpDeleteItem      = "delete_item"
+)
+
+// Per-operation result statuses
+const (
+	SyncStatusApplied   = "applied"
+	SyncStatusMerged    = "merged"    // create_item folded into an existing open item
+	SyncStatusDuplicate = "duplicate" // create_item refused by the list's duplicate mode
+	SyncStatusConflict  = "conflict"  // the server row changed after the client's timestamp
+	SyncStatusNotFound  = "not_found"
+	SyncStatusInvalid   = "invalid"
+	SyncStatusError     = "error" // server failure, worth retrying
+)
+
+// SyncRef identifies a row either by its ID or by the temporary ID a client
+// gave it when creating it offline ("offline-3")
+type SyncRef struct {
+	ID     int64
+	TempID string
+}
+
+// UnmarshalJSON accepts a number, a numeric string or a temporary ID string
+func (r *SyncRef) UnmarshalJSON(data []byte) error {
+	var id int64
+	if err := json.Unmarshal(data, &id); err == nil {
+		r.ID = id
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("id must be a number or a string")
+	}
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		r.ID = id
+	} else {
+		r.TempID = s
+	}
+	return nil
+}
+
+// SyncOperation is one queued client change. Optional fields left out of an
+// update keep their current value; toggles without a value flip the flag.
+type SyncOperation struct {
+	Op          string   `json:"op"`
+	TempID      string   `json:"temp_id,omitempty"`    // create_*: client ID later operations may reference
+	ID          SyncRef  `json:"id,omitempty"`         // row the operation applies to
+	ListID      int64    `json:"list_id,omitempty"`    // create_section: defaults to the active list
+	SectionID   SyncRef  `json:"section_id,omitempty"` // create_item, move_item
+	Name        string   `json:"name,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Quantity    *float64 `json:"quantity,omitempty"`
+	Unit        *string  `json:"unit,omitempty"`
+	Completed   *bool    `json:"completed,omitempty"`
+	Uncertain   *bool    `json:"uncertain,omitempty"`
+	Timestamp   int64    `json:"timestamp,omitempty"` // when the change was made; a newer server row wins
+}
+
+// SyncPushRequest is the body of POST /api/sync/push
+type SyncPushRequest struct {
+	Operations []SyncOperation `json:"operations"`
+}
+
+// SyncResult reports the outcome of one operation
+type SyncResult struct {
+	Index   int         `json:"index"`
+	Op      string      `json:"op"`
+	Status  string      `json:"status"`
+	TempID  string      `json:"temp_id,omitempty"`
+	ID      int64       `json:"id,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Item    *db.Item    `json:"item,omitempty"`    // current server state
+	Section *db.Section `json:"section,omitempty"` // current server state
+}
+
+// SyncPushResponse is the reply to POST /api/sync/push
+type SyncPushResponse struct {
+	Results []SyncResult     `json:"results"`
+	IDMap   map[string]int64 `json:"id_map"`
+	Cursor  int64            `json:"cursor"`
+}
+
+// syncOpError carries the result status of a failed operation
+type syncOpError struct {
+	status string
+	msg    string
+}
+
+func (e *syncOpError) Error() string { return e.msg }
+
+func syncInvalid(format string, args ...interface{}) error {
+	return &syncOpError{SyncStatusInvalid, fmt.Sprintf(format, args...)}
+}
+
+var errSyncNotFound = &syncOpError{SyncStatusNotFound, "not found"}
+
+// syncReplay applies operations for one household inside a transaction
+type syncReplay struct {
+	c           *fiber.Ctx
+	tx          *sql.Tx
+	householdID int64
+	idMap       map[string]int64
+	// Rows this replay created or changed. Their updated_at is the push's own
+	// write, so later operations on them aren't checked for conflicts.
+	changedSections map[int64]bool
+	changedItems    map[int64]bool
+	opEvents        []syncEvent // events of the operation being applied
+	events          []syncEvent // events of the applied operations, sent after commit
+}
+
+// syncEvent is an event held back until the replay is committed
+type syncEvent struct {
+	listID    int64
+	eventType string
+	data      interface{}
+}
+
+// SyncPush replays an ordered batch of offline operations in one transaction.
+// Each operation runs in its own savepoint, so a failing one doesn't undo the
+// others; the results report per-operation status, temp ID mapping and the
+// server state on conflicts.
+func SyncPush(c *fiber.Ctx) error {
+	var req SyncPushRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(req.Operations) > MaxSyncOperations {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Too many operations (max %d)", MaxSyncOperations)})
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	replay := &syncReplay{
+		c:               c,
+		tx:              tx,
+		householdID:     HouseholdID(c),
+		idMap:           map[string]int64{},
+		changedSections: map[int64]bool{},
+		changedItems:    map[int64]bool{},
+	}
+	results := make([]SyncResult, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		result := replay.apply(op)
+		result.Index = i
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to commit changes"})
+	}
+
+	for _, event := range replay.events {
+		BroadcastUpdate(c, event.listID, event.eventType, event.data)
+	}
+
+	cursor, _ := db.GetSyncCursor()
+	return c.JSON(SyncPushResponse{Results: results, IDMap: replay.idMap, Cursor: cursor})
+}
+
+// apply runs one operation in a savepoint and turns its outcome into a result
+func (r *syncReplay) apply(op SyncOperation) SyncResult {
+	result := SyncResult{Op: op.Op, TempID: op.TempID}
+	r.opEvents = nil
+
+	if _, err := r.tx.Exec(`SAVEPOINT sync_op`); err != nil {
+		result.Status, result.Error = SyncStatusError, "Failed to start operation"
+		return result
+	}
+
+	err := r.run(op, &result)
+	if err != nil {
+		r.tx.Exec(`ROLLBACK TO sync_op`)
+		var opErr *syncOpError
+		if errors.As(err, &opErr) {
+			result.Status, result.Error = opErr.status, opErr.msg
+		} else {
+			log.Printf("[SYNC] %s failed: %v", op.Op, err)
+			result.Status, result.Error = SyncStatusError, "Failed to apply operation"
+		}
+	}
+	r.tx.Exec(`RELEASE sync_op`)
+
+	if result.Status == SyncStatusApplied || result.Status == SyncStatusMerged {
+		r.events = append(r.events, r.opEvents...)
+	}
+	return result
+}
+
+func (r *syncReplay) run(op SyncOperation, result *SyncResult) error {
+	switch op.Op {
+	case SyncOpCreateSection:
+		return r.createSection(op, result)
+	case SyncOpUpdateSection, SyncOpDeleteSection:
+		return r.changeSection(op, result)
+	case SyncOpCreateItem:
+		return r.createItem(op, result)
+	case SyncOpUpdateItem, SyncOpToggleItem, SyncOpToggleUncertain, SyncOpMoveItem, SyncOpDeleteItem:
+		return r.changeItem(op, result)
+	}
+	return syncInvalid("Unknown operation: %s", op.Op)
+}
+
+// resolve maps a reference to a row ID, looking temporary IDs up in the
+// mapping built by earlier create operations
+func (r *syncReplay) resolve(ref SyncRef) (int64, error) {
+	if ref.TempID != "" {
+		id, ok := r.idMap[ref.TempID]
+		if !ok {
+			return 0, syncInvalid("Unknown temporary ID: %s", ref.TempID)
+		}
+		return id, nil
+	}
+	if ref.ID == 0 {
+		return 0, syncInvalid("ID is required")
+	}
+	return ref.ID, nil
+}
+
+// ownedSection resolves a section reference and checks it belongs to the household
+func (r *syncReplay) ownedSection(ref SyncRef) (int64, error) {
+	id, err := r.resolve(ref)
+	if err != nil {
+		return 0, err
+	}
+	householdID, err := db.SectionHouseholdIDTx(r.tx, id)
+	if err == sql.ErrNoRows || (err == nil && householdID != r.householdID) {
+		return 0, errSyncNotFound
+	}
+	return id, err
+}
+
+// emit records an event for the current operation
+func (r *syncReplay) emit(listID int64, eventType string, data interface{}) {
+	r.opEvents = append(r.opEvents, syncEvent{listID, eventType, data})
+}
+
+// emitForSection records an event for the current operation on a section's list
+func (r *syncReplay) emitForSection(sectionID int64, eventType string, data interface{}) {
+	listID, err := db.SectionListIDTx(r.tx, sectionID)
+	if err != nil {
+		return
+	}
+	r.emit(listID, eventType, data)
+}
+
+func (r *syncReplay) mapTempID(tempID string, id int64) {
+	if tempID != "" {
+		r.idMap[tempID] = id
+	}
+}
+
+func validateSectionName(name string) error {
+	if name == "" {
+		return syncInvalid("Name is required")
+	}
+	if len(name) > MaxSectionNameLength {
+		return syncInvalid("Name too long (max %d characters)", MaxSectionNameLength)
+	}
+	return nil
+}
+
+func (r *syncReplay) createSection(op SyncOperation, result *SyncResult) error {
+	name := strings.TrimSpace(op.Name)
+	if err := validateSectionName(name); err != nil {
+		return err
+	}
+
+	listID := op.ListID
+	if listID == 0 {
+		list, err := db.GetActiveList(r.householdID)
+		if err != nil {
+			return syncInvalid("No active list")
+		}
+		listID = list.ID
+	} else if !OwnsList(r.c, listID) {
+		return errSyncNotFound
+	}
+
+	section, err := db.CreateSectionForListTx(r.tx, listID, name, db.GetMaxSectionOrderTx(r.tx, listID)+1)
+	if err != nil {
+		return err
+	}
+	r.mapTempID(op.TempID, section.ID)
+	r.changedSections[section.ID] = true
+	result.Status, result.ID, result.Section = SyncStatusApplied, section.ID, section
+	r.emit(listID, EventSectionCreated, section)
+	return nil
+}
+
+func (r *syncReplay) changeSection(op SyncOperation, result *SyncResult) error {
+	id, err := r.ownedSection(op.ID)
+	if err != nil {
+		return err
+	}
+	result.ID = id
+
+	section, err := db.GetSectionTx(r.tx, id)
+	if err != nil {
+		return err
+	}
+	if op.Timestamp > 0 && section.UpdatedAt > op.Timestamp && !r.changedSections[id] {
+		result.Status, result.Section = SyncStatusConflict, section
+		return nil
+	}
+
+	if op.Op == SyncOpDeleteSection {
+		if err := db.DeleteSectionTx(r.tx, id); err != nil {
+			return err
+		}
+		result.Status = SyncStatusApplied
+		r.emit(section.ListID, EventSectionDeleted, map[string]int64{"id": id, "list_id": section.ListID})
+		return nil
+	}
+
+	name := strings.TrimSpace(op.Name)
+	if err := validateSectionName(name); err != nil {
+		return err
+	}
+	if section, err = db.UpdateSectionTx(r.tx, id, name); err != nil {
+		return err
+	}
+	r.changedSections[id] = true
+	result.Status, result.Section = SyncStatusApplied, section
+	r.emit(section.ListID, EventSectionUpdated, section)
+	return nil
+}
+
+// itemFields validates an item's editable fields, starting from the current
+// values (or zero values for a new item)
+func itemFields(op SyncOperation, name, description string, quantity float64, unit string) (string, string, float64, string, error) {
+	if op.Name != "" {
+		name = strings.TrimSpace(op.Name)
+	}
+	if op.Description != nil {
+		description = *op.Description
+	}
+	if op.Quantity != nil {
+		quantity = *op.Quantity
+	}
+	if op.Unit != nil {
+		unit = *op.Unit
+	}
+
+	if name == "" {
+		return "", "", 0, "", syncInvalid("Name is required")
+	}
+	if len(name) > MaxItemNameLength {
+		return "", "", 0, "", syncInvalid("Name too long (max %d characters)", MaxItemNameLength)
+	}
+	if len(description) > MaxDescriptionLength {
+		return "", "", 0, "", syncInvalid("Description too long (max %d characters)", MaxDescriptionLength)
+	}
+	quantity, unit, err := ParseQuantityFields(strconv.FormatFloat(quantity, 'f', -1, 64), unit)
+	if err != nil {
+		return "", "", 0, "", syncInvalid("%s", err.Error())
+	}
+	return name, description, quantity, unit, nil
+}
+
+func (r *syncReplay) createItem(op SyncOperation, result *SyncResult) error {
+	sectionID, err := r.ownedSection(op.SectionID)
+	if err != nil {
+		return err
+	}
+
+	name, description, quantity, unit, err := itemFields(op, "", "", 0, "")
+	if err != nil {
+		return err
+	}
+	// No explicit quantity - try to extract it from the typed name, like the form does
+	if quantity == 0 && unit == "" {
+		name, quantity, unit = ParseQuantity(name)
+		if name == "" {
+			return syncInvalid("Name is required")
+		}
+	}
+
+	item, merged, err := db.AddItemTx(r.tx, sectionID, name, description, quantity, unit, db.GetMaxItemOrderTx(r.tx, sectionID)+1, 0)
+	if err == db.ErrDuplicateItem {
+		// Later operations on the offline copy apply to the existing item
+		r.mapTempID(op.TempID, item.ID)
+		result.Status, result.ID, result.Item = SyncStatusDuplicate, item.ID, item
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	db.SaveItemHistoryTx(r.tx, r.householdID, name, sectionID)
+
+	r.mapTempID(op.TempID, item.ID)
+	r.changedItems[item.ID] = true
+	result.Status, result.ID, result.Item = SyncStatusApplied, item.ID, item
+	if merged {
+		result.Status = SyncStatusMerged
+		r.emitForSection(sectionID, EventItemUpdated, item)
+	} else {
+		r.emitForSection(sectionID, EventItemCreated, item)
+	}
+	return nil
+}
+
+func (r *syncReplay) changeItem(op SyncOperation, result *SyncResult) error {
+	id, err := r.resolve(op.ID)
+	if err != nil {
+		return err
+	}
+	result.ID = id
+
+	householdID, err := db.ItemHouseholdIDTx(r.tx, id)
+	if err == sql.ErrNoRows || (err == nil && householdID != r.householdID) {
+		return errSyncNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	item, err := db.GetItemTx(r.tx, id)
+	if err != nil {
+		return err
+	}
+	if op.Timestamp > 0 && item.UpdatedAt > op.Timestamp && !r.changedItems[id] {
+		result.Status, result.Item = SyncStatusConflict, item
+		return nil
+	}
+
+	eventType := EventItemUpdated
+	switch op.Op {
+	case SyncOpUpdateItem:
+		name, description, quantity, unit, err := itemFields(op, item.Name, item.Description, item.Quantity, item.Unit)
+		if err != nil {
+			return err
+		}
+		item, err = db.UpdateItemTx(r.tx, id, name, description, quantity, unit)
+		if err != nil {
+			return err
+		}
+	case SyncOpToggleItem:
+		completed := !item.Completed
+		if op.Completed != nil {
+			completed = *op.Completed
+		}
+		if item, err = db.SetItemCompletedTx(r.tx, id, completed); err != nil {
+			return err
+		}
+		eventType = EventItemToggled
+	case SyncOpToggleUncertain:
+		uncertain := !item.Uncertain
+		if op.Uncertain != nil {
+			uncertain = *op.Uncertain
+		}
+		if item, err = db.SetItemUncertainTx(r.tx, id, uncertain); err != nil {
+			return err
+		}
+	case SyncOpMoveItem:
+		sectionID, err := r.ownedSection(op.SectionID)
+		if err != nil {
+			return err
+		}
+		if item, err = db.MoveItemToSectionTx(r.tx, id, sectionID); err != nil {
+			return err
+		}
+		eventType = EventItemMoved
+	case SyncOpDeleteItem:
+		// Look the list up while the item still exists
+		r.emitForSection(item.SectionID, EventItemDeleted, map[string]int64{"id": id, "section_id": item.SectionID})
+		if err := db.DeleteItemTx(r.tx, id); err != nil {
+			return err
+		}
+		result.Status = SyncStatusApplied
+		return nil
+	}
+
+	r.changedItems[id] = true
+	result.Status, result.Item = SyncStatusApplied, item
+	r.emitForSection(item.SectionID, eventType, item)
+	return nil
+}
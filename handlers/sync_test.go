@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"shopping-list/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setupTestDB points the db package at a fresh database for one test
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	db.Init()
+	t.Cleanup(db.Close)
+}
+
+func syncPush(t *testing.T, body string) SyncPushResponse {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/api/sync/push", SyncPush)
+
+	req := httptest.NewRequest("POST", "/api/sync/push", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("push returned %d", resp.StatusCode)
+	}
+	var out SyncPushResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSyncPushChangesRowsCreatedInSamePush(t *testing.T) {
+	setupTestDB(t)
+	list, err := db.CreateList(db.DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := db.CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client queued these offline, a while before the server writes them
+	queued := time.Now().Add(-time.Hour).Unix()
+	res := syncPush(t, fmt.Sprintf(`{"operations": [
+		{"op": "create_item", "temp_id": "offline-1", "section_id": %[1]d, "name": "Milk", "timestamp": %[3]d},
+		{"op": "update_item", "id": "offline-1", "name": "Oat milk", "timestamp": %[3]d},
+		{"op": "toggle_item", "id": "offline-1", "completed": true, "timestamp": %[3]d},
+		{"op": "create_section", "temp_id": "offline-2", "list_id": %[2]d, "name": "Bakery", "timestamp": %[3]d},
+		{"op": "update_section", "id": "offline-2", "name": "Bread", "timestamp": %[3]d}
+	]}`, section.ID, list.ID, queued))
+
+	for _, r := range res.Results {
+		if r.Status != SyncStatusApplied {
+			t.Fatalf("operation %d (%s): status %q, want %q", r.Index, r.Op, r.Status, SyncStatusApplied)
+		}
+	}
+	item, err := db.GetItemByID(res.IDMap["offline-1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "Oat milk" || !item.Completed {
+		t.Errorf("item = %q completed=%v, want \"Oat milk\" completed", item.Name, item.Completed)
+	}
+}
+
+func TestSyncPushConflictWithNewerServerRow(t *testing.T) {
+	setupTestDB(t)
+	list, err := db.CreateList(db.DefaultHouseholdID, "Groceries", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := db.CreateSectionForList(list.ID, "Dairy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := db.CreateItem(section.ID, "Milk", "", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := syncPush(t, fmt.Sprintf(`{"operations": [{"op": "toggle_item", "id": %d, "timestamp": %d}]}`,
+		item.ID, time.Now().Add(-time.Hour).Unix()))
+	if got := res.Results[0].Status; got != SyncStatusConflict {
+		t.Errorf("status %q, want %q", got, SyncStatusConflict)
+	}
+}
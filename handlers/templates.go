@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
 	"shopping-list/db"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// MaxApplyMultiplier is the largest quantity multiplier a template is applied with
+const MaxApplyMultiplier = 100
+
 // GetTemplates returns all templates
 func GetTemplates(c *fiber.Ctx) error {
-	templates, err := db.GetAllTemplates()
+	templates, err := db.GetAllTemplates(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("Failed to fetch templates")
 	}
@@ -55,13 +61,13 @@ func CreateTemplate(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	template, err := db.CreateTemplate(name, description)
+	template, err := db.CreateTemplate(HouseholdID(c), name, description)
 	if err != nil {
 		return c.Status(500).SendString("Failed to create template")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("template_created", template)
+	BroadcastIndexUpdate(c, 0, EventTemplateCreated, template)
 
 	// Return the new template partial
 	return c.Render("partials/template_item", fiber.Map{
@@ -89,7 +95,7 @@ func UpdateTemplate(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("template_updated", template)
+	BroadcastIndexUpdate(c, 0, EventTemplateUpdated, template)
 
 	// Return updated template partial
 	return c.Render("partials/template_item", fiber.Map{
@@ -110,7 +116,7 @@ func DeleteTemplate(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("template_deleted", map[string]int64{"id": id})
+	BroadcastIndexUpdate(c, 0, EventTemplateDeleted, map[string]int64{"id": id})
 
 	return c.SendString("")
 }
@@ -134,11 +140,19 @@ func AddTemplateItem(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	item, err := db.AddTemplateItem(templateID, sectionName, name, description)
+	quantity, unit, err := ParseQuantityFields(c.FormValue("quantity"), c.FormValue("unit"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	item, err := db.AddTemplateItem(templateID, sectionName, name, description, quantity, unit)
 	if err != nil {
 		return c.Status(500).SendString("Failed to add item to template")
 	}
 
+	// Broadcast to WebSocket clients
+	BroadcastIndexUpdate(c, 0, EventTemplateItemCreated, item)
+
 	// Return the template item partial
 	return c.Render("partials/template_item_row", fiber.Map{
 		"Item": item,
@@ -164,11 +178,19 @@ func UpdateTemplateItem(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	item, err := db.UpdateTemplateItem(itemID, sectionName, name, description)
+	quantity, unit, err := ParseQuantityFields(c.FormValue("quantity"), c.FormValue("unit"))
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	item, err := db.UpdateTemplateItem(itemID, sectionName, name, description, quantity, unit)
 	if err != nil {
 		return c.Status(500).SendString("Failed to update template item")
 	}
 
+	// Broadcast to WebSocket clients
+	BroadcastIndexUpdate(c, 0, EventTemplateItemUpdated, item)
+
 	return c.Render("partials/template_item_row", fiber.Map{
 		"Item": item,
 	}, "")
@@ -181,11 +203,18 @@ func DeleteTemplateItem(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Invalid item ID")
 	}
 
+	var templateID int64
+	if item, err := db.GetTemplateItemByID(itemID); err == nil {
+		templateID = item.TemplateID
+	}
 	err = db.DeleteTemplateItem(itemID)
 	if err != nil {
 		return c.Status(500).SendString("Failed to delete template item")
 	}
 
+	// Broadcast to WebSocket clients
+	BroadcastIndexUpdate(c, 0, EventTemplateItemDeleted, map[string]int64{"id": itemID, "template_id": templateID})
+
 	return c.SendString("")
 }
 
@@ -196,27 +225,85 @@ func ApplyTemplate(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Invalid template ID")
 	}
 
-	activeList, err := db.GetActiveList()
+	opts, err := parseApplyOptions(c.FormValue("multiplier"), c.FormValue("item_ids"))
+	if err == nil {
+		err = ValidateApplyOptions(templateID, opts)
+	}
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	activeList, err := db.GetActiveList(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("No active list found")
 	}
 
-	err = db.ApplyTemplateToList(templateID, activeList.ID)
+	err = db.ApplyTemplateToList(templateID, activeList.ID, opts)
 	if err != nil {
 		return c.Status(500).SendString("Failed to apply template")
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("template_applied", map[string]interface{}{
-		"template_id": templateID,
-		"list_id":     activeList.ID,
-	})
+	BroadcastTemplateApplied(c, templateID, activeList.ID)
 
 	// Trigger a full refresh
 	c.Set("HX-Trigger", "refreshList, refresh")
 	return c.SendString("")
 }
 
+// parseApplyOptions reads the multiplier and comma-separated item_ids form
+// values of a template apply; both are optional
+func parseApplyOptions(multiplier, itemIDs string) (db.ApplyTemplateOptions, error) {
+	var opts db.ApplyTemplateOptions
+	if multiplier = strings.TrimSpace(multiplier); multiplier != "" {
+		m, err := strconv.ParseFloat(strings.Replace(multiplier, ",", ".", 1), 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid multiplier")
+		}
+		opts.Multiplier = m
+	}
+	if itemIDs != "" {
+		opts.ItemIDs = []int64{}
+		for _, idStr := range splitAndTrim(itemIDs, ",") {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				return opts, fmt.Errorf("invalid item ID: %s", idStr)
+			}
+			opts.ItemIDs = append(opts.ItemIDs, id)
+		}
+	}
+	return opts, nil
+}
+
+// ValidateApplyOptions checks a template apply's multiplier and that its
+// picked items, if any, belong to the template
+func ValidateApplyOptions(templateID int64, opts db.ApplyTemplateOptions) error {
+	if math.IsNaN(opts.Multiplier) || opts.Multiplier < 0 || opts.Multiplier > MaxApplyMultiplier {
+		return fmt.Errorf("multiplier must be between 0 and %d", MaxApplyMultiplier)
+	}
+	if opts.ItemIDs == nil {
+		return nil
+	}
+	if len(opts.ItemIDs) == 0 {
+		return fmt.Errorf("Pick at least one item")
+	}
+
+	template, err := db.GetTemplateByID(templateID)
+	if err != nil {
+		return fmt.Errorf("Template not found")
+	}
+	inTemplate := make(map[int64]bool)
+	for _, item := range template.Items {
+		inTemplate[item.ID] = true
+	}
+	for _, id := range opts.ItemIDs {
+		if !inTemplate[id] {
+			return fmt.Errorf("Item %d is not in this template", id)
+		}
+	}
+	return nil
+}
+
 // CreateTemplateFromList creates a template from the active list
 func CreateTemplateFromList(c *fiber.Ctx) error {
 	name := c.FormValue("name")
@@ -226,7 +313,7 @@ func CreateTemplateFromList(c *fiber.Ctx) error {
 
 	description := c.FormValue("description")
 
-	activeList, err := db.GetActiveList()
+	activeList, err := db.GetActiveList(HouseholdID(c))
 	if err != nil {
 		return c.Status(500).SendString("No active list found")
 	}
@@ -237,7 +324,7 @@ func CreateTemplateFromList(c *fiber.Ctx) error {
 	}
 
 	// Broadcast to WebSocket clients
-	BroadcastUpdate("template_created", template)
+	BroadcastIndexUpdate(c, 0, EventTemplateCreated, template)
 
 	// Return the new template partial
 	return c.Render("partials/template_item", fiber.Map{
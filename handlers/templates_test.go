@@ -0,0 +1,29 @@
+package handlers
+
+import "testing"
+
+func TestApplyOptionsMultiplier(t *testing.T) {
+	tests := []struct {
+		multiplier string
+		wantErr    bool
+	}{
+		{"", false},
+		{"2", false},
+		{"1,5", false},
+		{"100", false},
+		{"101", true},
+		{"-1", true},
+		{"abc", true},
+		{"NaN", true},
+		{"Inf", true},
+	}
+	for _, tt := range tests {
+		opts, err := parseApplyOptions(tt.multiplier, "")
+		if err == nil {
+			err = ValidateApplyOptions(0, opts)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("multiplier %q: error = %v, want error %v", tt.multiplier, err, tt.wantErr)
+		}
+	}
+}
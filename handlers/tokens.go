@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"shopping-list/db"
+	"shopping-list/i18n"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// API token limits
+const (
+	APITokenPrefix        = "kft_" // marks Koffan tokens in configs and secret scanners
+	MaxTokenNameLength    = 100
+	MaxTokenLifetimeDays  = 3650
+	apiTokenDisplayLength = 12 // characters of the secret kept for recognizing a token
+)
+
+// AdminOnlyScopes can only be granted by an admin. settings:write changes the
+// instance-wide app password, which the UI leaves to admins.
+var AdminOnlyScopes = []string{db.ScopeSettingsWrite}
+
+// AdminOnlyScope returns the first of scopes that only an admin may grant, or
+// "" if there is none
+func AdminOnlyScope(scopes []string) string {
+	for _, scope := range scopes {
+		for _, s := range AdminOnlyScopes {
+			if scope == s {
+				return scope
+			}
+		}
+	}
+	return ""
+}
+
+// TokenCreatorIsAdmin reports whether the user who minted a token may still
+// manage the instance. A token minted with the shared password (userID 0)
+// counts only until the first account exists, like canManageUsers.
+func TokenCreatorIsAdmin(userID int64) bool {
+	if isAuthDisabled() {
+		return true
+	}
+	if userID == 0 {
+		return db.CountActiveUsers() == 0
+	}
+	user, err := db.GetUserByID(userID)
+	return err == nil && user.IsAdmin && !user.Disabled
+}
+
+// HashAPIToken returns the stored form of a token secret. Tokens are random,
+// so a fast hash is enough and keeps lookups by hash possible.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateAPITokenInput checks a token request from the UI or the API
+func ValidateAPITokenInput(c *fiber.Ctx, name string, scopes []string, listID int64, expiresInDays int) error {
+	if name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if len(name) > MaxTokenNameLength {
+		return fmt.Errorf("Name too long (max %d characters)", MaxTokenNameLength)
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("At least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !db.IsValidScope(scope) {
+			return fmt.Errorf("Unknown scope: %s", scope)
+		}
+	}
+	if listID != 0 && !OwnsList(c, listID) {
+		return fmt.Errorf("List not found")
+	}
+	if expiresInDays < 0 || expiresInDays > MaxTokenLifetimeDays {
+		return fmt.Errorf("Expiry must be between 0 (never) and %d days", MaxTokenLifetimeDays)
+	}
+	return nil
+}
+
+// MintAPIToken creates a token for the household on behalf of createdBy (0 for
+// the shared password) and returns it together with its secret. The secret is
+// not stored and can't be shown again.
+func MintAPIToken(householdID int64, name string, scopes []string, listID int64, expiresInDays int, createdBy int64) (*db.APIToken, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	secret := APITokenPrefix + hex.EncodeToString(bytes)
+
+	var expiresAt int64
+	if expiresInDays > 0 {
+		expiresAt = time.Now().Add(time.Duration(expiresInDays) * 24 * time.Hour).Unix()
+	}
+
+	token, err := db.CreateAPIToken(householdID, name, HashAPIToken(secret), secret[:apiTokenDisplayLength], normalizeScopes(scopes), listID, expiresAt, createdBy)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// normalizeScopes drops duplicates and orders scopes like db.AllScopes
+func normalizeScopes(scopes []string) []string {
+	var result []string
+	for _, scope := range db.AllScopes {
+		for _, s := range scopes {
+			if s == scope {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// GetTokensPage renders the API token management page
+func GetTokensPage(c *fiber.Ctx) error {
+	tokens, err := db.GetAPITokens(HouseholdID(c))
+	if err != nil {
+		return c.Status(500).SendString("Failed to fetch tokens")
+	}
+	lists, _ := db.GetAllLists(HouseholdID(c))
+
+	scopes := db.AllScopes
+	if !canManageUsers(c) {
+		scopes = nil
+		for _, scope := range db.AllScopes {
+			if AdminOnlyScope([]string{scope}) == "" {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return c.Render("tokens", fiber.Map{
+		"Tokens":       tokens,
+		"Lists":        lists,
+		"Scopes":       scopes,
+		"Translations": i18n.GetAllLocales(),
+		"Locales":      i18n.AvailableLocales(),
+		"DefaultLang":  i18n.GetDefaultLang(),
+	})
+}
+
+// CreateToken mints a token from the management page and shows its secret once
+func CreateToken(c *fiber.Ctx) error {
+	name := strings.TrimSpace(c.FormValue("name"))
+
+	var scopes []string
+	for _, scope := range c.Request().PostArgs().PeekMulti("scopes") {
+		scopes = append(scopes, string(scope))
+	}
+
+	var listID int64
+	if v := c.FormValue("list_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c.Status(400).SendString("Invalid list ID")
+		}
+		listID = id
+	}
+
+	expiresInDays := 0
+	if v := c.FormValue("expires_in_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil {
+			return c.Status(400).SendString("Invalid expiry")
+		}
+		expiresInDays = days
+	}
+
+	if err := ValidateAPITokenInput(c, name, scopes, listID, expiresInDays); err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	if scope := AdminOnlyScope(scopes); scope != "" && !canManageUsers(c) {
+		return c.Status(403).SendString("Only an admin can grant " + scope)
+	}
+
+	token, secret, err := MintAPIToken(HouseholdID(c), name, scopes, listID, expiresInDays, CurrentUserID(c))
+	if err != nil {
+		return c.Status(500).SendString("Failed to create token")
+	}
+
+	return c.Render("partials/token_row", fiber.Map{
+		"Token":  token,
+		"Secret": secret,
+	}, "")
+}
+
+// RevokeToken deletes one of the household's tokens
+func RevokeToken(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).SendString("Invalid ID")
+	}
+
+	err = db.DeleteAPIToken(HouseholdID(c), id)
+	if err == sql.ErrNoRows {
+		return c.Status(404).SendString("Token not found")
+	}
+	if err != nil {
+		return c.Status(500).SendString("Failed to revoke token")
+	}
+
+	return c.SendString("")
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"shopping-list/db"
+	"shopping-list/i18n"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Account limits
+const (
+	MinUsernameLength = 2
+	MaxUsernameLength = 50
+	MinPasswordLength = 8
+	MaxPasswordLength = 72 // bcrypt ignores anything longer
+
+	MaxHouseholdNameLength = 100
+)
+
+// GetUsersPage renders the user management page
+func GetUsersPage(c *fiber.Ctx) error {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return c.Status(500).SendString("Failed to fetch users")
+	}
+
+	households, err := db.GetAllHouseholds()
+	if err != nil {
+		return c.Status(500).SendString("Failed to fetch households")
+	}
+
+	return c.Render("users", fiber.Map{
+		"Users":        users,
+		"Households":   households,
+		"HouseholdID":  HouseholdID(c),
+		"CurrentUser":  CurrentUser(c),
+		"Translations": i18n.GetAllLocales(),
+		"Locales":      i18n.AvailableLocales(),
+		"DefaultLang":  i18n.GetDefaultLang(),
+	})
+}
+
+// CreateUser creates a new user account
+func CreateUser(c *fiber.Ctx) error {
+	username := strings.TrimSpace(c.FormValue("username"))
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return c.Status(400).SendString("Username must be 2-50 characters")
+	}
+
+	password := c.FormValue("password")
+	if err := ValidatePassword(password); err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+
+	if _, err := db.GetUserByUsername(username); err == nil {
+		return c.Status(409).SendString("Username already taken")
+	}
+
+	// The first account must be able to manage the others
+	isAdmin := c.FormValue("is_admin") == "on" || c.FormValue("is_admin") == "true" || db.CountActiveAdmins() == 0
+
+	householdID, ferr := resolveNewUserHousehold(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).SendString(ferr.Message)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(500).SendString("Failed to hash password")
+	}
+
+	user, err := db.CreateUser(householdID, username, string(hash), isAdmin)
+	if err != nil {
+		return c.Status(500).SendString("Failed to create user")
+	}
+
+	return c.Render("partials/user_row", fiber.Map{
+		"User":        user,
+		"CurrentUser": CurrentUser(c),
+	}, "")
+}
+
+// resolveNewUserHousehold returns the household picked in the user form: an
+// existing one, a new one ("new" plus household_name), or the admin's own
+func resolveNewUserHousehold(c *fiber.Ctx) (int64, *fiber.Error) {
+	value := c.FormValue("household_id")
+	if value == "" {
+		return HouseholdID(c), nil
+	}
+
+	if value == "new" {
+		name := strings.TrimSpace(c.FormValue("household_name"))
+		if name == "" {
+			return 0, fiber.NewError(400, "Household name is required")
+		}
+		if len(name) > MaxHouseholdNameLength {
+			return 0, fiber.NewError(400, "Household name too long (max 100 characters)")
+		}
+		household, err := db.CreateHousehold(name)
+		if err != nil {
+			return 0, fiber.NewError(500, "Failed to create household")
+		}
+		return household.ID, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fiber.NewError(400, "Invalid household")
+	}
+	if _, err := db.GetHouseholdByID(id); err != nil {
+		return 0, fiber.NewError(404, "Household not found")
+	}
+	return id, nil
+}
+
+// DisableUser disables an account and logs it out everywhere
+func DisableUser(c *fiber.Ctx) error {
+	return setUserDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled account
+func EnableUser(c *fiber.Ctx) error {
+	return setUserDisabled(c, false)
+}
+
+func setUserDisabled(c *fiber.Ctx, disabled bool) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).SendString("Invalid ID")
+	}
+
+	user, err := db.GetUserByID(id)
+	if err != nil {
+		return c.Status(404).SendString("User not found")
+	}
+
+	if disabled {
+		if id == CurrentUserID(c) {
+			return c.Status(400).SendString("You can't disable your own account")
+		}
+		if user.IsAdmin && !user.Disabled && db.CountActiveAdmins() <= 1 {
+			return c.Status(400).SendString("Can't disable the last admin")
+		}
+	}
+
+	user, err = db.SetUserDisabled(id, disabled)
+	if err != nil {
+		return c.Status(500).SendString("Failed to update user")
+	}
+
+	return c.Render("partials/user_row", fiber.Map{
+		"User":        user,
+		"CurrentUser": CurrentUser(c),
+	}, "")
+}
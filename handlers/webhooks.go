@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"shopping-list/db"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Webhook delivery settings
+const (
+	WebhookSecretPrefix   = "whsec_"
+	MaxWebhookURLLength   = 2048
+	webhookTimeout        = 10 * time.Second // per delivery attempt
+	webhookPollInterval   = 5 * time.Second  // how often due retries are picked up
+	webhookPruneInterval  = time.Hour
+	webhookBatchSize      = 20  // deliveries attempted at once
+	webhookMaxErrorLength = 200 // characters of an error kept in the delivery log
+)
+
+// webhookRetryDelays is the wait before each retry of a failed delivery; a
+// delivery still failing after the last one is marked failed
+var webhookRetryDelays = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+}
+
+var (
+	webhookClient = &http.Client{
+		Timeout: webhookTimeout,
+		// Deliveries go straight to the webhook, and the address checked is
+		// the one dialed, so a DNS answer can't swap in an internal one
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: webhookTimeout,
+				Control: webhookDialControl,
+			}).DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        webhookBatchSize,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// A redirect counts as a failed delivery rather than being followed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	webhookWake      = make(chan struct{}, 1)
+	webhookStartOnce sync.Once
+)
+
+// ValidateWebhookInput checks a webhook request from the API
+func ValidateWebhookInput(householdID int64, rawURL string, events []string, listID int64) error {
+	if rawURL == "" {
+		return fmt.Errorf("URL is required")
+	}
+	if len(rawURL) > MaxWebhookURLLength {
+		return fmt.Errorf("URL too long (max %d characters)", MaxWebhookURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must be an absolute http or https URL")
+	}
+	if err := checkWebhookHost(u.Hostname()); err != nil {
+		return err
+	}
+	for _, eventType := range events {
+		if !IsEventType(eventType) {
+			return fmt.Errorf("Unknown event type: %s", eventType)
+		}
+	}
+	if listID != 0 {
+		if id, err := db.ListHouseholdID(listID); err != nil || id != householdID {
+			return fmt.Errorf("List not found")
+		}
+	}
+	return nil
+}
+
+// webhookPrivateTargetsAllowed reports whether webhooks may be delivered to
+// loopback and private network addresses, for servers whose integrations run
+// on the same machine or LAN
+func webhookPrivateTargetsAllowed() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+}
+
+// isPrivateAddress reports whether ip is loopback, link-local, private or
+// unspecified: addresses a webhook could use to reach the server's own network
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// checkWebhookHost refuses a webhook host on a private network. Names that
+// don't resolve yet are accepted; every delivery checks the dialed address.
+func checkWebhookHost(host string) error {
+	if webhookPrivateTargetsAllowed() {
+		return nil
+	}
+	errPrivate := fmt.Errorf("URL must not point to a private network address")
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivateAddress(ip) {
+			return errPrivate
+		}
+		return nil
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return errPrivate
+	}
+	ips, _ := net.LookupIP(host)
+	for _, ip := range ips {
+		if isPrivateAddress(ip) {
+			return errPrivate
+		}
+	}
+	return nil
+}
+
+// webhookDialControl refuses connections to private network addresses
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	if webhookPrivateTargetsAllowed() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+		return fmt.Errorf("webhook address %s is on a private network", host)
+	}
+	return nil
+}
+
+// GenerateWebhookSecret returns a new random signing secret
+func GenerateWebhookSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return WebhookSecretPrefix + hex.EncodeToString(bytes), nil
+}
+
+// SignWebhookPayload returns the X-Koffan-Signature of a delivery: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// queueWebhooks records deliveries of an encoded event to the matching
+// webhooks and wakes the worker
+func queueWebhooks(householdID, listID int64, eventType string, message []byte) {
+	n, err := db.QueueWebhookDeliveries(householdID, listID, eventType, string(message))
+	if err != nil {
+		log.Printf("Failed to queue webhooks for %s: %v", eventType, err)
+		return
+	}
+	if n > 0 {
+		select {
+		case webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StartWebhookWorker starts delivering queued webhook events in the
+// background. Deliveries live in the database, so retries survive restarts.
+func StartWebhookWorker() {
+	webhookStartOnce.Do(func() {
+		go webhookWorker()
+	})
+}
+
+func webhookWorker() {
+	poll := time.NewTicker(webhookPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(webhookPruneInterval)
+	defer prune.Stop()
+
+	pruneWebhookDeliveries()
+	for {
+		deliverDueWebhooks()
+		select {
+		case <-webhookWake:
+		case <-poll.C:
+		case <-prune.C:
+			pruneWebhookDeliveries()
+		}
+	}
+}
+
+func pruneWebhookDeliveries() {
+	if err := db.PruneWebhookDeliveries(); err != nil {
+		log.Printf("Failed to prune webhook deliveries: %v", err)
+	}
+}
+
+// deliverDueWebhooks attempts the due deliveries, a batch at a time, until
+// none are left
+func deliverDueWebhooks() {
+	for {
+		deliveries, err := db.GetDueWebhookDeliveries(webhookBatchSize)
+		if err != nil {
+			log.Printf("Failed to load webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(d *db.WebhookDelivery) {
+				defer wg.Done()
+				deliverWebhook(d)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+	}
+}
+
+// deliverWebhook makes one attempt and records its outcome, scheduling a
+// retry when the endpoint failed and retries are left
+func deliverWebhook(d *db.WebhookDelivery) {
+	responseStatus, err := postWebhook(d)
+	if err == nil {
+		if err := db.RecordWebhookAttempt(d.ID, db.DeliverySucceeded, responseStatus, "", 0); err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+		}
+		return
+	}
+
+	errMsg := err.Error()
+	if len(errMsg) > webhookMaxErrorLength {
+		errMsg = errMsg[:webhookMaxErrorLength]
+	}
+	status, next := db.DeliveryFailed, int64(0)
+	if d.Attempts < len(webhookRetryDelays) {
+		status = db.DeliveryPending
+		next = time.Now().Add(webhookRetryDelays[d.Attempts]).Unix()
+	}
+	log.Printf("Webhook delivery %d to %s failed (attempt %d): %s", d.ID, d.URL, d.Attempts+1, errMsg)
+	if err := db.RecordWebhookAttempt(d.ID, status, responseStatus, errMsg, next); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// postWebhook sends a delivery. Any response other than 2xx is an error.
+func postWebhook(d *db.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Koffan-Webhook")
+	req.Header.Set("X-Koffan-Event", d.EventType)
+	req.Header.Set("X-Koffan-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Koffan-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Koffan-Signature", SignWebhookPayload(d.Secret, timestamp, d.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
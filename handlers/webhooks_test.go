@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCheckWebhookHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"localhost", true},
+		{"api.LOCALHOST.", true},
+		{"169.254.169.254", true},
+		{"10.0.0.5", true},
+		{"172.16.3.4", true},
+		{"192.168.1.10", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		err := checkWebhookHost(tt.host)
+		if (err != nil) != tt.private {
+			t.Errorf("checkWebhookHost(%q) = %v, want private=%v", tt.host, err, tt.private)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "true")
+	if err := checkWebhookHost("192.168.1.10"); err != nil {
+		t.Errorf("with WEBHOOK_ALLOW_PRIVATE: %v", err)
+	}
+}
+
+func TestWebhookDialControl(t *testing.T) {
+	if err := webhookDialControl("tcp", net.JoinHostPort("127.0.0.1", "80"), nil); err == nil {
+		t.Error("dial to loopback allowed")
+	}
+	if err := webhookDialControl("tcp", net.JoinHostPort("93.184.216.34", "443"), nil); err != nil {
+		t.Errorf("dial to public address refused: %v", err)
+	}
+}
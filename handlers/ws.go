@@ -3,93 +3,186 @@ package handlers
 import (
 	"encoding/json"
 	"log"
-	"sync"
+	"shopping-list/db"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
-// WebSocket client connections
-var (
-	clients   = make(map[*websocket.Conn]bool)
-	clientsMu sync.RWMutex
+// WebSocket connection limits
+const (
+	wsWriteWait      = 10 * time.Second // time allowed to write one message
+	wsPongWait       = 60 * time.Second // a client silent for this long is evicted
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 4096 // largest message accepted from a client
 )
 
-// WebSocketMessage represents a message sent to clients
-type WebSocketMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+// wsClientMessage is a message received from a client:
+//
+//	{"type": "ping"}
+//	{"type": "subscribe", "lists": [1, 2], "index": true, "since": 41, "epoch": 1700000000000}
+//	{"type": "unsubscribe", "lists": [2], "index": true}
+//
+// since and epoch are the seq and epoch of the last event the client saw. The
+// events it missed on its channels follow the "subscribed" reply, which sets
+// resync instead when they are no longer available.
+type wsClientMessage struct {
+	Type  string  `json:"type"`
+	Lists []int64 `json:"lists"`
+	Index bool    `json:"index"`
+	Since int64   `json:"since"`
+	Epoch int64   `json:"epoch"`
 }
 
 // WebSocketHandler handles WebSocket connections
 func WebSocketHandler(c *websocket.Conn) {
-	// Register client
-	clientsMu.Lock()
-	clients[c] = true
-	clientsMu.Unlock()
+	// Register client under the household resolved by AuthMiddleware
+	householdID, ok := c.Locals(LocalsHouseholdID).(int64)
+	if !ok {
+		householdID = db.DefaultHouseholdID
+	}
+	client := newEventClient(householdID)
+	if access, ok := c.Locals(LocalsEventAccess).(EventAccess); ok {
+		client.access = access
+	}
+	client.onClose = func() {
+		// Unblock the reader so the handler can return
+		c.SetReadDeadline(time.Now())
+	}
+	count := registerClient(client)
 
-	log.Printf("WebSocket client connected. Total clients: %d", len(clients))
+	log.Printf("WebSocket client connected. Total clients: %d", count)
+
+	stopped := make(chan struct{})
+	go func() {
+		wsWritePump(c, client)
+		close(stopped)
+	}()
 
 	defer func() {
-		// Unregister client
-		clientsMu.Lock()
-		delete(clients, c)
-		clientsMu.Unlock()
+		// Unregister client; the connection must not be used after the handler returns
+		count := unregisterClient(client)
+		<-stopped
 		c.Close()
-		log.Printf("WebSocket client disconnected. Total clients: %d", len(clients))
+		log.Printf("WebSocket client disconnected. Total clients: %d", count)
 	}()
 
-	// Keep connection alive and handle incoming messages
+	wsReadPump(c, client)
+}
+
+// wsReadPump handles incoming messages until the connection fails or goes
+// idle. Any message or pong from the client extends the read deadline.
+func wsReadPump(c *websocket.Conn, client *eventClient) {
+	c.SetReadLimit(wsMaxMessageSize)
+	c.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
 	for {
 		messageType, msg, err := c.ReadMessage()
+		if client.closed() {
+			return
+		}
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if isTimeout(err) {
+				idleClients.Add(1)
+				log.Printf("WebSocket client idle, evicting")
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
-			break
+			return
+		}
+		c.SetReadDeadline(time.Now().Add(wsPongWait))
+		if messageType != websocket.TextMessage {
+			continue
 		}
 
-		// Handle ping/pong
-		if messageType == websocket.TextMessage {
-			var message map[string]string
-			if err := json.Unmarshal(msg, &message); err == nil {
-				if message["type"] == "ping" {
-					c.WriteJSON(map[string]string{"type": "pong"})
-				}
-			}
+		var message wsClientMessage
+		if err := json.Unmarshal(msg, &message); err != nil {
+			continue
+		}
+
+		switch message.Type {
+		case "ping":
+			client.queueJSON(fiber.Map{"type": "pong"})
+		case "subscribe", "unsubscribe":
+			updateSubscriptions(client, message)
 		}
 	}
 }
 
-// BroadcastUpdate sends an update to all connected WebSocket clients
-func BroadcastUpdate(eventType string, data interface{}) {
-	message := WebSocketMessage{
-		Type: eventType,
-		Data: data,
-	}
+// wsWritePump writes queued messages and pings the client until it is closed
+func wsWritePump(c *websocket.Conn, client *eventClient) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
 
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal WebSocket message: %v", err)
-		return
+	for {
+		select {
+		case message := <-client.send:
+			c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.WriteMessage(websocket.TextMessage, message.data); err != nil {
+				log.Printf("Failed to send WebSocket message to client: %v", err)
+				client.close()
+				return
+			}
+			messagesSent.Add(1)
+		case <-ticker.C:
+			c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
+				client.close()
+				return
+			}
+		case <-client.done:
+			c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
 	}
+}
 
-	clientsMu.RLock()
-	clientCount := len(clients)
-	log.Printf("Broadcasting %s to %d clients", eventType, clientCount)
+func isTimeout(err error) bool {
+	netErr, ok := err.(interface{ Timeout() bool })
+	return ok && netErr.Timeout()
+}
 
-	successCount := 0
-	for client := range clients {
-		err := client.WriteMessage(websocket.TextMessage, messageBytes)
-		if err != nil {
-			log.Printf("Failed to send WebSocket message to client: %v", err)
-			// Don't remove client here, let the read loop handle it
-		} else {
-			successCount++
+// updateSubscriptions applies a subscribe or unsubscribe message and queues
+// the "subscribed" reply, followed by the events missed since message.Since.
+// Lists of other households, and channels outside the client's EventAccess,
+// are ignored.
+func updateSubscriptions(client *eventClient, message wsClientMessage) {
+	subscribe := message.Type == "subscribe"
+	lists := message.Lists
+	if subscribe {
+		lists = client.allowedLists(lists)
+		if client.access.NoIndex {
+			message.Index = false
 		}
 	}
-	clientsMu.RUnlock()
 
-	log.Printf("Broadcast %s completed: %d/%d clients received", eventType, successCount, clientCount)
+	// Hold eventsMu so no live event is queued between the reply and the replay
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	subscribed, index := client.subscribe(subscribe, lists, message.Index)
+	seq, missed, resync := eventsSince(client.householdID, message.Since, message.Epoch, subscribed, index)
+	if len(missed) > eventSendBuffer/2 {
+		// Too many to queue at once; a refresh is cheaper anyway
+		missed, resync = nil, true
+	}
+
+	client.queueJSON(fiber.Map{
+		"type":   "subscribed",
+		"lists":  subscribed,
+		"index":  index,
+		"seq":    seq,
+		"epoch":  eventEpoch,
+		"resync": resync,
+	})
+	for _, event := range missed {
+		client.queue(event)
+	}
 }
 
 // WebSocketUpgrade middleware to upgrade HTTP to WebSocket
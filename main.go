@@ -35,9 +35,24 @@ func main() {
 	// Clean expired sessions on startup
 	db.CleanExpiredSessions()
 
+	// Seed the hashed app password from APP_PASSWORD on first start
+	handlers.InitAppPassword()
+
 	// Initialize login rate limiter
 	handlers.InitLoginRateLimiter()
 
+	// Deliver queued webhook events, including retries left from a previous run
+	handlers.StartWebhookWorker()
+
+	// Apply scheduled templates, catching up on runs missed while stopped
+	handlers.StartScheduler()
+
+	// Bring back recurring items once their interval has passed
+	handlers.StartRecurringItems()
+
+	// Drop change log entries older than the sync retention window
+	handlers.StartChangeLogPruner()
+
 	// Initialize template engine
 	engine := html.New("./templates", ".html")
 	engine.Reload(os.Getenv("APP_ENV") != "production")
@@ -135,59 +150,69 @@ func main() {
 	// WebSocket endpoint
 	app.Get("/ws", websocket.New(handlers.WebSocketHandler))
 
+	// Server-Sent Events, for networks that break the WebSocket upgrade
+	app.Get("/events", handlers.GetEvents)
+	app.Get("/api/ws/stats", handlers.AdminMiddleware, handlers.GetWebSocketStats) // instance-wide connected clients and dropped messages
+
 	// Main page - shows all lists
 	app.Get("/", handlers.GetListsPage)
 
 	// Single list view - shows items
 	app.Get("/lists/:id", handlers.GetListView)
 
+	// Routes taking an :id are limited to the caller's household by the
+	// handlers.*Access middleware
+
 	// Sections API
 	app.Get("/sections/list", handlers.GetSectionsListForModal)
 	app.Post("/sections", handlers.CreateSection)
-	app.Put("/sections/:id", handlers.UpdateSection)
-	app.Delete("/sections/:id", handlers.DeleteSection)
-	app.Post("/sections/:id/move-up", handlers.MoveSectionUp)
-	app.Post("/sections/:id/move-down", handlers.MoveSectionDown)
+	app.Put("/sections/:id", handlers.SectionAccess, handlers.UpdateSection)
+	app.Delete("/sections/:id", handlers.SectionAccess, handlers.DeleteSection)
+	app.Post("/sections/:id/move-up", handlers.SectionAccess, handlers.MoveSectionUp)
+	app.Post("/sections/:id/move-down", handlers.SectionAccess, handlers.MoveSectionDown)
 
 	// Lists API
 	app.Get("/lists", handlers.GetLists)
 	app.Post("/lists", handlers.CreateList)
-	app.Put("/lists/:id", handlers.UpdateList)
-	app.Delete("/lists/:id", handlers.DeleteList)
-	app.Post("/lists/:id/activate", handlers.SetActiveList)
-	app.Post("/lists/:id/move-up", handlers.MoveListUp)
-	app.Post("/lists/:id/move-down", handlers.MoveListDown)
+	app.Put("/lists/:id", handlers.ListAccess, handlers.UpdateList)
+	app.Delete("/lists/:id", handlers.ListAccess, handlers.DeleteList)
+	app.Post("/lists/:id/activate", handlers.ListAccess, handlers.SetActiveList)
+	app.Post("/lists/:id/move-up", handlers.ListAccess, handlers.MoveListUp)
+	app.Post("/lists/:id/move-down", handlers.ListAccess, handlers.MoveListDown)
 
 	// Templates API
 	app.Get("/templates", handlers.GetTemplates)
-	app.Get("/templates/:id", handlers.GetTemplate)
+	app.Get("/templates/:id", handlers.TemplateAccess, handlers.GetTemplate)
 	app.Post("/templates", handlers.CreateTemplate)
-	app.Put("/templates/:id", handlers.UpdateTemplate)
-	app.Delete("/templates/:id", handlers.DeleteTemplate)
-	app.Post("/templates/:id/items", handlers.AddTemplateItem)
-	app.Put("/templates/:id/items/:itemId", handlers.UpdateTemplateItem)
-	app.Delete("/templates/:id/items/:itemId", handlers.DeleteTemplateItem)
-	app.Post("/templates/:id/apply", handlers.ApplyTemplate)
+	app.Put("/templates/:id", handlers.TemplateAccess, handlers.UpdateTemplate)
+	app.Delete("/templates/:id", handlers.TemplateAccess, handlers.DeleteTemplate)
+	app.Post("/templates/:id/items", handlers.TemplateAccess, handlers.AddTemplateItem)
+	app.Put("/templates/:id/items/:itemId", handlers.TemplateAccess, handlers.TemplateItemAccess, handlers.UpdateTemplateItem)
+	app.Delete("/templates/:id/items/:itemId", handlers.TemplateAccess, handlers.TemplateItemAccess, handlers.DeleteTemplateItem)
+	app.Post("/templates/:id/apply", handlers.TemplateAccess, handlers.ApplyTemplate)
 	app.Post("/templates/from-list", handlers.CreateTemplateFromList)
 
 	// Items API
 	app.Post("/items", handlers.CreateItem)
 	app.Post("/items/delete-completed", handlers.DeleteCompletedItems)
-	app.Put("/items/:id", handlers.UpdateItem)
-	app.Delete("/items/:id", handlers.DeleteItem)
-	app.Post("/items/:id/toggle", handlers.ToggleItem)
-	app.Post("/items/:id/uncertain", handlers.ToggleUncertain)
-	app.Post("/items/:id/move", handlers.MoveItemToSection)
-	app.Post("/items/:id/move-up", handlers.MoveItemUp)
-	app.Post("/items/:id/move-down", handlers.MoveItemDown)
+	app.Put("/items/:id", handlers.ItemAccess, handlers.UpdateItem)
+	app.Delete("/items/:id", handlers.ItemAccess, handlers.DeleteItem)
+	app.Post("/items/:id/toggle", handlers.ItemAccess, handlers.ToggleItem)
+	app.Post("/items/:id/uncertain", handlers.ItemAccess, handlers.ToggleUncertain)
+	app.Post("/items/:id/move", handlers.ItemAccess, handlers.MoveItemToSection)
+	app.Post("/items/:id/move-up", handlers.ItemAccess, handlers.MoveItemUp)
+	app.Post("/items/:id/move-down", handlers.ItemAccess, handlers.MoveItemDown)
 
 	// Stats API
 	app.Get("/stats", handlers.GetStats)
 
 	// Offline data API
 	app.Get("/api/data", handlers.GetAllData)
+	app.Get("/api/sync", handlers.GetSync)
+	app.Post("/api/sync/push", handlers.SyncPush)
 	app.Get("/api/item/:id/version", handlers.GetItemVersion)
 	app.Get("/api/suggestions", handlers.GetSuggestions)
+	app.Get("/api/search", handlers.Search)
 
 	// History management API
 	app.Get("/api/history", handlers.GetHistory)
@@ -197,6 +222,20 @@ func main() {
 	// Batch operations
 	app.Post("/sections/batch-delete", handlers.BatchDeleteSections)
 
+	// API tokens (scoped to the caller's household)
+	app.Get("/tokens", handlers.GetTokensPage)
+	app.Post("/tokens", handlers.CreateToken)
+	app.Delete("/tokens/:id", handlers.RevokeToken)
+
+	// User management and app password (admins only)
+	admin := app.Group("/admin", handlers.AdminMiddleware)
+	admin.Get("/users", handlers.GetUsersPage)
+	admin.Post("/users", handlers.CreateUser)
+	admin.Post("/users/:id/disable", handlers.DisableUser)
+	admin.Post("/users/:id/enable", handlers.EnableUser)
+	admin.Get("/password", handlers.GetPasswordPage)
+	admin.Post("/password", handlers.ChangePassword)
+
 	// Get port from env or default to 3000
 	port := os.Getenv("PORT")
 	if port == "" {
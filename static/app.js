@@ -57,6 +57,8 @@ function shoppingList() {
         connected: false,
         reconnectAttempts: 0,
         maxReconnectAttempts: 5,
+        eventSeq: 0,    // seq of the last event received
+        eventEpoch: 0,  // server run the seq belongs to
 
         // Offline support
         isOnline: navigator.onLine,
@@ -96,6 +98,9 @@ function shoppingList() {
         editingItem: null,
         editItemName: '',
         editItemDescription: '',
+        editItemQuantity: '',
+        editItemUnit: '',
+        editItemRecurDays: '',
 
         // Auto-completion
         suggestions: [],
@@ -282,11 +287,27 @@ function shoppingList() {
             if (!this.offlineStorageReady) return;
 
             try {
+                // Patch the cache with what changed since the last sync when possible
+                const cursor = await window.offlineStorage.getSyncCursor();
+                if (cursor) {
+                    const deltaResponse = await fetch('/api/sync?since=' + cursor);
+                    if (deltaResponse.ok) {
+                        const delta = await deltaResponse.json();
+                        if (await window.offlineStorage.applyDelta(delta)) {
+                            await window.offlineStorage.setSyncCursor(delta.cursor);
+                            await window.offlineStorage.setLastSyncTimestamp(Math.floor(Date.now() / 1000));
+                            console.log('[App] Offline cache updated from delta');
+                            return;
+                        }
+                    }
+                }
+
                 const response = await fetch('/api/data');
                 if (response.ok) {
                     const data = await response.json();
                     await window.offlineStorage.saveSections(data.sections || []);
                     await window.offlineStorage.setLastSyncTimestamp(data.timestamp);
+                    await window.offlineStorage.setSyncCursor(data.cursor);
                     console.log('[App] Data cached for offline use');
                 }
             } catch (error) {
@@ -321,45 +342,37 @@ function shoppingList() {
 
                 console.log('[App] Processing', actions.length, 'queued actions');
 
-                for (const action of actions) {
-                    try {
-                        // For all modifying actions - check server version (Last Write Wins)
-                        if (action.type === 'toggle_item' || action.type === 'update_item' || action.type === 'edit_item') {
-                            const itemId = this.extractItemId(action.url);
-                            if (itemId) {
-                                const serverVersion = await this.getItemVersion(itemId);
-                                if (serverVersion && serverVersion.updated_at > action.timestamp) {
-                                    // Server has newer version - skip offline action
-                                    console.log('[Sync] Server version newer, skipping:', action.type,
-                                        'server:', serverVersion.updated_at, 'offline:', action.timestamp);
-                                    await window.offlineStorage.clearAction(action.id);
-                                    continue;
-                                }
-                            }
-                        }
-
-                        const fetchOptions = {
-                            method: action.method,
-                            headers: action.headers || {}
-                        };
-
-                        if (action.body) {
-                            fetchOptions.body = action.body;
-                        }
-
-                        const response = await fetch(action.url, fetchOptions);
+                // Replay the whole queue in one request - the server applies it in a
+                // transaction and reports every operation separately (Last Write Wins
+                // via each action's timestamp)
+                let results;
+                try {
+                    const response = await fetch('/api/sync/push', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ operations: actions.map(action => this.toSyncOperation(action)) })
+                    });
+                    if (!response.ok) {
+                        console.error('[App] Failed to push offline queue:', response.status);
+                        return false;
+                    }
+                    results = (await response.json()).results;
+                } catch (error) {
+                    console.error('[App] Error pushing offline queue:', error);
+                    return false; // Keep queue for retry
+                }
 
-                        if (response.ok || response.status === 404) {
-                            // Success or item no longer exists - remove from queue
-                            await window.offlineStorage.clearAction(action.id);
-                            console.log('[App] Synced action:', action.type);
-                        } else {
-                            console.error('[App] Failed to sync action:', action.type, response.status);
-                        }
-                    } catch (error) {
-                        console.error('[App] Error syncing action:', action.type, error);
-                        // Keep in queue for retry
+                for (const result of results) {
+                    const action = actions[result.index];
+                    if (result.status === 'error') {
+                        console.error('[App] Failed to sync action:', action.type, result.error);
+                        continue; // Keep in queue for retry
+                    }
+                    if (result.status === 'conflict') {
+                        console.log('[Sync] Server version newer, skipping:', action.type);
                     }
+                    // Applied, conflicting, gone or already on the list - remove from queue
+                    await window.offlineStorage.clearAction(action.id);
                 }
 
                 // Refresh data after sync - small delay to ensure server processed all changes
@@ -390,23 +403,36 @@ function shoppingList() {
             }
         },
 
-        // Get item version from server for conflict resolution
-        async getItemVersion(itemId) {
-            try {
-                const response = await fetch(`/api/item/${itemId}/version`);
-                if (response.ok) {
-                    return await response.json();
-                }
-            } catch (e) {
-                console.error('[Sync] Failed to get item version:', e);
-            }
-            return null;
-        },
+        // Convert a queued HTMX request into a /api/sync/push operation
+        toSyncOperation(action) {
+            const params = new URLSearchParams(action.body || '');
+            // Numeric ID or the temporary ID of an item created offline - the server accepts both
+            const match = (action.url || '').match(/\/items\/([^/]+)/);
+            const id = match ? match[1] : null;
+            const quantity = parseFloat((params.get('quantity') || '').replace(',', '.'));
+            const fields = {
+                name: params.get('name') || '',
+                description: params.get('description') || '',
+                quantity: isNaN(quantity) ? 0 : quantity,
+                unit: params.get('unit') || ''
+            };
 
-        // Extract item ID from URL like /items/123/toggle
-        extractItemId(url) {
-            const match = url.match(/\/items\/(\d+)/);
-            return match ? match[1] : null;
+            switch (action.type) {
+                case 'create_item':
+                    return { op: 'create_item', temp_id: action.tempId, section_id: params.get('section_id'), ...fields };
+                case 'edit_item':
+                case 'update_item':
+                    return { op: 'update_item', id, timestamp: action.timestamp, ...fields };
+                case 'toggle_item':
+                    return { op: 'toggle_item', id, timestamp: action.timestamp };
+                case 'toggle_uncertain':
+                    return { op: 'toggle_uncertain', id };
+                case 'move_item':
+                    return { op: 'move_item', id, section_id: params.get('section_id') };
+                case 'delete_item':
+                    return { op: 'delete_item', id };
+            }
+            return { op: action.type };
         },
 
         async fullRefresh() {
@@ -475,6 +501,7 @@ function shoppingList() {
                     console.log('WebSocket connected');
                     this.connected = true;
                     this.reconnectAttempts = 0;
+                    this.subscribe();
                 };
 
                 this.ws.onclose = () => {
@@ -498,6 +525,19 @@ function shoppingList() {
             }
         },
 
+        // Only events of subscribed lists are delivered; resubscribe after every reconnect.
+        // The index channel carries other members' list changes.
+        // since/epoch let the server tell whether events were missed while disconnected.
+        subscribe() {
+            this.ws.send(JSON.stringify({
+                type: 'subscribe',
+                lists: window.currentListId ? [window.currentListId] : [],
+                index: true,
+                since: this.eventSeq,
+                epoch: this.eventEpoch
+            }));
+        },
+
         scheduleReconnect() {
             if (this.reconnectAttempts >= this.maxReconnectAttempts) {
                 console.log('Max reconnection attempts reached');
@@ -522,6 +562,11 @@ function shoppingList() {
                 const message = JSON.parse(data);
                 console.log('WebSocket message:', message.type);
 
+                if (message.epoch) {
+                    this.eventSeq = message.seq;
+                    this.eventEpoch = message.epoch;
+                }
+
                 switch (message.type) {
                     case 'section_created':
                     case 'section_updated':
@@ -574,6 +619,35 @@ function shoppingList() {
                         this.refreshList();
                         this.refreshStats();
                         break;
+                    case 'batch_created':
+                    case 'template_applied':
+                        // Sections and items were added in bulk
+                        this.refreshSectionsAndSelects();
+                        this.refreshList();
+                        this.refreshStats();
+                        break;
+                    case 'list_deleted':
+                        // The list shown on this page is gone
+                        if (message.data && message.data.id === window.currentListId) {
+                            window.location.href = '/';
+                        }
+                        break;
+                    case 'subscribed':
+                        if (message.resync) {
+                            // Events were missed while disconnected
+                            this.fullRefresh();
+                        }
+                        break;
+                    case 'list_created':
+                        // The page has no list to show yet - show the new one
+                        if (!window.currentListId) {
+                            window.location.reload();
+                        }
+                        break;
+                    case 'list_updated':
+                    case 'list_activated':
+                    case 'lists_reordered':
+                        break;
                     case 'pong':
                         break;
                     default:
@@ -796,6 +870,9 @@ function shoppingList() {
                 id: item.id,
                 name: item.name,
                 description: item.description || '',
+                quantity: item.quantity || '',
+                unit: item.unit || '',
+                recur_days: item.recur_days || '',
                 section_id: item.section_id,
                 uncertain: item.uncertain
             };
@@ -1277,6 +1354,10 @@ function shoppingList() {
                 this.editItemDescription = item.description || '';
             }
 
+            this.editItemQuantity = item.quantity || '';
+            this.editItemUnit = item.unit || '';
+            this.editItemRecurDays = item.recur_days || '';
+
             this.$nextTick(() => {
                 const input = document.querySelector('[x-model="editItemName"]');
                 if (input) input.focus();
@@ -1289,11 +1370,19 @@ function shoppingList() {
             const itemId = this.editingItem.id;
             const name = this.editItemName.trim();
             const description = this.editItemDescription.trim();
-            const body = `name=${encodeURIComponent(name)}&description=${encodeURIComponent(description)}`;
+            const quantity = String(this.editItemQuantity).trim();
+            const unit = this.editItemUnit.trim();
+            const recurDays = String(this.editItemRecurDays).trim();
+            const body = `name=${encodeURIComponent(name)}&description=${encodeURIComponent(description)}` +
+                `&quantity=${encodeURIComponent(quantity)}&unit=${encodeURIComponent(unit)}` +
+                `&recur_days=${encodeURIComponent(recurDays)}`;
 
             this.editingItem = null;
             this.editItemName = '';
             this.editItemDescription = '';
+            this.editItemQuantity = '';
+            this.editItemUnit = '';
+            this.editItemRecurDays = '';
 
             // If offline, do optimistic UI update
             if (!this.isOnline) {
@@ -1472,6 +1561,25 @@ function shoppingList() {
             }
         },
 
+        // Fill quantity/unit fields from typed name ("3x milk", "500 g flour")
+        applyQuantityFromName(form) {
+            if (!form) return;
+            const nameInput = form.querySelector('[name=name]');
+            const quantityInput = form.querySelector('[name=quantity]');
+            const unitInput = form.querySelector('[name=unit]');
+            if (!nameInput || !quantityInput || !unitInput || quantityInput.value.trim() !== '') return;
+
+            const parsed = window.parseQuantityInput(nameInput.value);
+            if (!parsed.quantity) return;
+
+            nameInput.value = parsed.name;
+            this.itemNameInput = parsed.name;
+            quantityInput.value = parsed.quantity;
+            if (parsed.unit) {
+                unitInput.value = parsed.unit;
+            }
+        },
+
         // Quick add to section - sets section and focuses input
         quickAddToSection(sectionId) {
             const isMobile = window.innerWidth < 768;
@@ -1692,6 +1800,8 @@ document.addEventListener('DOMContentLoaded', function() {
             const sectionId = formData.get('section_id');
             const name = formData.get('name');
             const description = formData.get('description') || '';
+            const quantity = formData.get('quantity') || '';
+            const unit = formData.get('unit') || '';
 
             if (!sectionId || !name) return;
 
@@ -1699,7 +1809,7 @@ document.addEventListener('DOMContentLoaded', function() {
             const tempId = 'offline-' + (++offlineItemCounter);
 
             // Create optimistic item HTML
-            const itemHtml = createOfflineItemHtml(tempId, name, description, sectionId);
+            const itemHtml = createOfflineItemHtml(tempId, name, description, sectionId, quantity, unit);
 
             // Find the section by exact ID and add item to it
             const sectionEl = document.getElementById(`section-${sectionId}`);
@@ -1744,7 +1854,8 @@ document.addEventListener('DOMContentLoaded', function() {
                 url: '/items',
                 method: 'POST',
                 headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
-                body: `section_id=${sectionId}&name=${encodeURIComponent(name)}&description=${encodeURIComponent(description)}`,
+                body: `section_id=${sectionId}&name=${encodeURIComponent(name)}&description=${encodeURIComponent(description)}` +
+                    `&quantity=${encodeURIComponent(quantity)}&unit=${encodeURIComponent(unit)}`,
                 tempId: tempId
             }).then(() => {
                 console.log('[Offline] Item queued:', name);
@@ -1759,9 +1870,11 @@ document.addEventListener('DOMContentLoaded', function() {
                     form.reset();
                     alpineData.showAddItem = false;
                 } else {
-                    // Keep modal open, clear only name and description
+                    // Keep modal open, clear only name, description and quantity
                     form.querySelector('[name=name]').value = '';
                     form.querySelector('[name=description]').value = '';
+                    form.querySelector('[name=quantity]').value = '';
+                    form.querySelector('[name=unit]').value = '';
                     setTimeout(() => {
                         const nameInput = form.querySelector('[name=name]');
                         if (nameInput) nameInput.focus();
@@ -1979,6 +2092,10 @@ document.addEventListener('DOMContentLoaded', function() {
         if (event.detail.xhr.status === 401) {
             window.location.href = '/login';
         }
+        // List rejects duplicates - item is already there
+        if (event.detail.xhr.status === 409 && event.detail.pathInfo?.requestPath?.startsWith('/items')) {
+            window.Toast.show(t('items.already_on_list'), 'warning');
+        }
     });
 
     document.body.addEventListener('htmx:beforeSwap', function(event) {
@@ -2021,10 +2138,14 @@ document.addEventListener('DOMContentLoaded', function() {
 });
 
 // Create HTML for offline item (simplified version without all actions)
-function createOfflineItemHtml(id, name, description, sectionId) {
+function createOfflineItemHtml(id, name, description, sectionId, quantity = '', unit = '') {
     const descHtml = description
         ? `<p class="text-xs text-stone-400 dark:text-stone-500 truncate mt-0.5">${escapeHtml(description)}</p>`
         : '';
+    const quantityLabel = formatQuantity(quantity, unit);
+    const quantityHtml = quantityLabel
+        ? ` <span class="text-xs font-medium text-pink-500 dark:text-pink-400">${escapeHtml(quantityLabel)}</span>`
+        : '';
 
     return `
 <div id="item-${id}" class="px-4 py-3 flex items-center gap-3 hover:bg-stone-50 dark:hover:bg-stone-700 transition-all group bg-rose-50/40 dark:bg-rose-900/30 border-l-2 border-rose-400 pending-sync" data-pending-sync="true">
@@ -2033,7 +2154,7 @@ function createOfflineItemHtml(id, name, description, sectionId) {
 
     <!-- Content -->
     <div class="flex-1 min-w-0">
-        <p class="text-sm text-stone-700 dark:text-stone-200 truncate">${escapeHtml(name)}</p>
+        <p class="text-sm text-stone-700 dark:text-stone-200 truncate">${escapeHtml(name)}${quantityHtml}</p>
         ${descHtml}
     </div>
 
@@ -2047,6 +2168,53 @@ function createOfflineItemHtml(id, name, description, sectionId) {
 </div>`;
 }
 
+// Units recognized by the quick-add parser (mirrors handlers.ParseQuantity)
+const UNIT_ALIASES = {
+    g: 'g', gr: 'g', gram: 'g', grams: 'g', kg: 'kg', mg: 'mg',
+    l: 'l', ltr: 'l', liter: 'l', liters: 'l', litre: 'l', litres: 'l',
+    ml: 'ml', cl: 'cl', dl: 'dl', oz: 'oz', lb: 'lb', lbs: 'lb',
+    pc: 'pcs', pcs: 'pcs', szt: 'szt', stk: 'stk', st: 'st',
+    pack: 'pack', packs: 'pack', pkg: 'pack', can: 'can', cans: 'can',
+    bottle: 'bottle', bottles: 'bottle', dozen: 'dozen',
+    tbsp: 'tbsp', tsp: 'tsp', cup: 'cup', cups: 'cup'
+};
+
+// Extract quantity and unit from typed input like "3x milk", "500 g flour" or "milk x2"
+window.parseQuantityInput = function(input) {
+    const text = (input || '').trim();
+    const toNumber = (s) => parseFloat(s.replace(',', '.'));
+    const unitOf = (u) => UNIT_ALIASES[u.toLowerCase().replace(/\.$/, '')];
+    let m;
+
+    if ((m = text.match(/^(\d+(?:[.,]\d+)?)\s*[xX×]\s+(.+)$/))) {
+        return { name: m[2].trim(), quantity: toNumber(m[1]), unit: '' };
+    }
+    if ((m = text.match(/^(\d+(?:[.,]\d+)?)\s*(\p{L}+\.?)?\s+(.+)$/u))) {
+        if (!m[2]) {
+            return { name: m[3].trim(), quantity: toNumber(m[1]), unit: '' };
+        }
+        const unit = unitOf(m[2]);
+        if (unit) {
+            return { name: m[3].trim(), quantity: toNumber(m[1]), unit: unit };
+        }
+        return { name: (m[2] + ' ' + m[3]).trim(), quantity: toNumber(m[1]), unit: '' };
+    }
+    if ((m = text.match(/^(.+?)\s+[xX×]\s*(\d+(?:[.,]\d+)?)$/))) {
+        return { name: m[1].trim(), quantity: toNumber(m[2]), unit: '' };
+    }
+    if ((m = text.match(/^(.+?)\s+(\d+(?:[.,]\d+)?)\s*(\p{L}+)\.?$/u)) && unitOf(m[3])) {
+        return { name: m[1].trim(), quantity: toNumber(m[2]), unit: unitOf(m[3]) };
+    }
+    return { name: text, quantity: 0, unit: '' };
+};
+
+// Format quantity for display (mirrors db.FormatQuantity)
+function formatQuantity(quantity, unit) {
+    const q = parseFloat(String(quantity || '').replace(',', '.'));
+    if (!q || q <= 0) return unit || '';
+    return unit ? `${q} ${unit}` : `${q}x`;
+}
+
 // Escape HTML to prevent XSS
 function escapeHtml(text) {
     const div = document.createElement('div');
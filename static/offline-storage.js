@@ -204,6 +204,57 @@ class OfflineStorage {
         return this.setMetadata('last_sync', timestamp);
     }
 
+    async getSyncCursor() {
+        return this.getMetadata('sync_cursor');
+    }
+
+    async setSyncCursor(cursor) {
+        return this.setMetadata('sync_cursor', cursor);
+    }
+
+    // Merge a /api/sync delta into the cached sections of the active list.
+    // Returns false when the cache can't be patched (full snapshot, empty cache
+    // or another list became active) and has to be reloaded from /api/data.
+    async applyDelta(delta) {
+        if (delta.reset) return false;
+
+        const sections = await this.getSections();
+        if (sections.length === 0) return false;
+        const listId = sections[0].list_id;
+        if (delta.deleted.lists.includes(listId)) return false;
+        if (delta.lists.some(list => list.is_active && list.id !== listId)) return false;
+
+        const byId = new Map(sections.map(section => [section.id, section]));
+        for (const id of delta.deleted.sections) {
+            byId.delete(id);
+        }
+        for (const section of delta.sections) {
+            const cached = byId.get(section.id);
+            if (section.list_id !== listId) {
+                byId.delete(section.id);
+                continue;
+            }
+            byId.set(section.id, { ...section, items: cached ? cached.items || [] : [] });
+        }
+
+        // Drop deleted and changed items, then re-add changed ones where they now live
+        const deletedItems = new Set(delta.deleted.items);
+        const changedItems = new Map(delta.items.map(item => [item.id, item]));
+        for (const section of byId.values()) {
+            section.items = (section.items || []).filter(item => !deletedItems.has(item.id) && !changedItems.has(item.id));
+        }
+        for (const item of changedItems.values()) {
+            const section = byId.get(item.section_id);
+            if (section) section.items.push(item);
+        }
+        for (const section of byId.values()) {
+            section.items.sort((a, b) => (a.completed - b.completed) || (a.sort_order - b.sort_order));
+        }
+
+        await this.saveSections([...byId.values()]);
+        return true;
+    }
+
     // ===== SUGGESTIONS CACHE METHODS =====
 
     async saveSuggestions(suggestions) {
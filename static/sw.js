@@ -1,5 +1,5 @@
 // Koffan Service Worker - Offline Support
-const CACHE_VERSION = 'koffan-v3';
+const CACHE_VERSION = 'koffan-v11';
 const STATIC_CACHE = CACHE_VERSION + '-static';
 const DYNAMIC_CACHE = CACHE_VERSION + '-dynamic';
 
@@ -93,6 +93,16 @@ self.addEventListener('fetch', (event) => {
         return;
     }
 
+    // Skip delta sync - a cached delta would be replayed against a newer cursor
+    if (url.pathname === '/api/sync') {
+        return;
+    }
+
+    // Skip search - results go stale and every query would fill the cache
+    if (url.pathname === '/api/search') {
+        return;
+    }
+
     // Skip API data endpoint - always fetch fresh when online
     if (url.pathname === '/api/data') {
         event.respondWith(networkFirst(event.request));